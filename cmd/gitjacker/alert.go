@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/alert"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+)
+
+var alertSinks []string
+var alertTemplate string
+
+// alertDispatcher builds a dispatcher for the sinks given with --alert, returning nil if there are none
+func alertDispatcher() *alert.Dispatcher {
+
+	if len(alertSinks) == 0 {
+		return nil
+	}
+
+	var sinks []alert.Sink
+	for _, spec := range alertSinks {
+		sink, err := alert.Parse(spec)
+		if err != nil {
+			fail("%s", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	var messageTemplate string
+	if alertTemplate != "" {
+		data, err := ioutil.ReadFile(alertTemplate)
+		if err != nil {
+			fail("Failed to read alert template: %s", err)
+		}
+		messageTemplate = string(data)
+	}
+
+	dispatcher, err := alert.NewDispatcher(sinks, messageTemplate)
+	if err != nil {
+		fail("%s", err)
+	}
+	return dispatcher
+}
+
+// alertTimeout bounds sending an alert to every sink, so that an unresponsive sink cannot hold up a run
+const alertTimeout = time.Minute
+
+// sendAlert sends an alert to every sink, giving up when ctx is cancelled, e.g. by Ctrl+C
+func sendAlert(ctx context.Context, dispatcher *alert.Dispatcher, target string, event alert.Event, summary *gitjacker.Summary) {
+	if dispatcher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, alertTimeout)
+	defer cancel()
+	if err := dispatcher.Send(ctx, alert.New(target, event, summary)); err != nil {
+		_ = tml.Printf("<red>%s</red>\n", err)
+	}
+}
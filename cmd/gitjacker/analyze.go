@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/results"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var listObjects bool
+
+func init() {
+	analyzeCmd.Flags().BoolVar(&listObjects, "list-objects", listObjects, "List the hashes of all present and missing objects")
+}
+
+var analyzeCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "analyze [dir]",
+	Short:        "Analyse an already downloaded .git directory without any network access",
+	Long: `Analyse an already downloaded .git directory, such as a partial dump from a wget mirror, without any network access.
+The directory may be either the .git directory itself or the repository root containing it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		eventOpts, stopEvents := startEvents()
+		defer stopEvents()
+		writeReport := prepareReport()
+
+		printBanner()
+
+		gitVersion := prepare()
+
+		_ = tml.Printf(`
+Source:     <yellow>%s</yellow>
+Local Git:  %s
+Output Dir: %s
+`, args[0], gitVersion, outputDir)
+
+		retriever, err := gitjacker.NewLocal(args[0], outputDir, append(analysisOptions(), eventOpts...)...)
+		if err != nil {
+			if errors.Is(err, gitjacker.ErrNoGitDirectory) {
+				fail("The provided directory does not contain a .git directory.\n\nError: %s", err)
+			}
+			fail("Analysis failed: %s", err)
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		started := time.Now()
+		summary, err := retriever.Run(ctx)
+		if err != nil {
+			fail("Analysis failed: %s", err)
+		}
+
+		printSummary(summary)
+		source, err := filepath.Abs(args[0])
+		if err != nil {
+			source = args[0]
+		}
+		writeReport([]string{source}, summary)
+		printResultID(saveResult(openResults(), results.KindAnalyze, []string{source}, started, summary))
+
+		if listObjects {
+			for _, hash := range summary.FoundObjects {
+				_ = tml.Printf("<green>present</green> %s\n", hash)
+			}
+			for _, hash := range summary.MissingObjects {
+				_ = tml.Printf("<red>missing</red> %s\n", hash)
+			}
+			fmt.Println()
+		}
+	},
+}
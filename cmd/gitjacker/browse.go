@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/liamg/gitjacker/internal/pkg/browse"
+	"github.com/spf13/cobra"
+)
+
+var browseCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "browse [dir]",
+	Short:        "Explore a retrieved repository in a full-screen terminal interface",
+	Long: `Explore a retrieved repository in a full-screen terminal interface, with panes for refs, commits, the file tree and a file viewer.
+Unlike plain git commands, this works on partially retrieved repositories, marking anything which could not be recovered.
+The directory may be either the .git directory itself or the repository root containing it. Press ? for help once open.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		repo := openRepository(args[0])
+		defer func() { _ = repo.Close() }()
+
+		findings, err := browse.ConfigFindings(repo)
+		if err != nil {
+			fail("Cannot read git config: %s", err)
+		}
+		if !noAnalysis {
+			analysed, err := browse.AnalysisFindings(context.Background(), repo)
+			if err != nil {
+				fail("Cannot scan repository for secrets: %s", err)
+			}
+			findings = append(findings, analysed...)
+		}
+
+		browser, err := browse.New(repo, findings)
+		if err != nil {
+			fail("Cannot read repository: %s", err)
+		}
+
+		if err := browse.Run(browser, os.Stdin, os.Stdout); err != nil {
+			fail("Browse failed: %s", err)
+		}
+	},
+}
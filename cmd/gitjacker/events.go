@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/spf13/cobra"
+)
+
+var eventsFormat string
+var eventsFile string
+
+func addEventFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&eventsFormat, "events", eventsFormat, "Write every retrieval event to stdout in the given format - only ndjson is supported")
+	cmd.Flags().StringVar(&eventsFile, "events-file", eventsFile, "Write events to a file instead of stdout - implies --events ndjson")
+}
+
+// startEvents streams events as requested by the command line flags, returning the option which feeds the stream and a function to close it.
+// It must be called before anything else is printed, as other output is moved to stderr when events are written to stdout.
+func startEvents() ([]gitjacker.Option, func()) {
+
+	if eventsFormat == "" && eventsFile == "" {
+		return nil, func() {}
+	}
+	if eventsFormat != "" && eventsFormat != "ndjson" {
+		fail("Unsupported event format %q - only ndjson is supported", eventsFormat)
+	}
+
+	var w io.Writer
+	closeFile := func() {}
+	if eventsFile == "" || eventsFile == "-" {
+		w = claimStdout("--events")
+	} else {
+		f, err := os.Create(eventsFile)
+		if err != nil {
+			fail("Failed to create events file: %s", err)
+		}
+		w = f
+		closeFile = func() { _ = f.Close() }
+	}
+
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	handler := func(event gitjacker.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = encoder.Encode(event)
+	}
+
+	return []gitjacker.Option{gitjacker.WithEventHandler(handler)}, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		closeFile()
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/internal/pkg/progress"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var repoDir = "."
+var logAll bool
+var logOneline bool
+var logLimit int
+var catExact bool
+
+func init() {
+	for _, cmd := range []*cobra.Command{logCmd, showCmd, catCmd} {
+		cmd.Flags().StringVarP(&repoDir, "repo", "C", repoDir, "Retrieved repository to read, either the .git directory or the directory containing it")
+	}
+	logCmd.Flags().BoolVar(&logAll, "all", logAll, "Start from every ref and every commit named in the reflogs, rather than HEAD")
+	logCmd.Flags().BoolVar(&logOneline, "oneline", logOneline, "Show each commit on a single line")
+	logCmd.Flags().IntVarP(&logLimit, "max-count", "n", 0, "Limit the number of commits shown")
+	catCmd.Flags().BoolVar(&catExact, "exact", catExact, "Fail rather than show an earlier version when the file was not recovered at the given ref")
+}
+
+// openRepository opens a retrieved repository, reading its loose objects and packs without git and carrying on
+// when objects are missing
+func openRepository(dir string) *gitrepo.Repository {
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		if errors.Is(err, gitrepo.ErrNoRepository) {
+			fail("The provided directory does not contain a .git directory.\n\nError: %s", err)
+		}
+		fail("Cannot open repository: %s", err)
+	}
+	if !progress.IsTerminal(os.Stdout) {
+		tml.DisableFormatting()
+	}
+	return repo
+}
+
+func resolveRevision(repo *gitrepo.Repository, rev string) string {
+	hash, err := repo.ResolveRevision(rev)
+	if err != nil {
+		fail("Cannot resolve %s: %s", rev, err)
+	}
+	return hash
+}
+
+func printGap(format string, args ...interface{}) {
+	_ = tml.Printf("<red>[%s]</red>\n", fmt.Sprintf(format, args...))
+}
+
+var logCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "log [revision...]",
+	Short:        "Show commit history of a retrieved repository, marking commits which could not be recovered",
+	Long: `Show commit history of a retrieved repository, starting from HEAD or the given revisions.
+Unlike git log, missing commits do not stop the walk: gaps are marked inline and history reachable any other way is still shown.
+Use --all to also start from every ref and every commit named in the reflogs, which often leads around a gap.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		repo := openRepository(repoDir)
+		defer func() { _ = repo.Close() }()
+
+		if len(args) == 0 {
+			args = []string{"HEAD"}
+		}
+
+		// names lists the refs pointing at each commit, for decoration
+		names := make(map[string][]string)
+		var starts []string
+		for _, rev := range args {
+			hash := resolveRevision(repo, rev)
+			starts = append(starts, hash)
+			names[hash] = append(names[hash], rev)
+		}
+		if logAll {
+			refs, err := repo.Refs()
+			if err != nil {
+				fail("Cannot read refs: %s", err)
+			}
+			for _, ref := range refs {
+				hash, err := repo.ResolveRevision(ref.Hash)
+				if err != nil {
+					continue
+				}
+				names[hash] = append(names[hash], strings.TrimPrefix(strings.TrimPrefix(ref.Name, "refs/heads/"), "refs/"))
+			}
+			tips, err := repo.Tips()
+			if err != nil {
+				fail("Cannot read refs: %s", err)
+			}
+			starts = append(starts, tips...)
+		}
+
+		for _, hash := range unique(starts) {
+			exists, err := repo.Exists(hash)
+			if err != nil {
+				fail("Cannot read %s: %s", hash, err)
+			}
+			if !exists && len(names[hash]) > 0 {
+				printGap("commit %s (%s) was not recovered", hash, strings.Join(unique(names[hash]), ", "))
+			}
+		}
+
+		commits, err := repo.Log(starts, logLimit)
+		if err != nil {
+			fail("Cannot read history: %s", err)
+		}
+
+		for _, commit := range commits {
+			decoration := ""
+			if refs := unique(names[commit.Hash]); len(refs) > 0 {
+				decoration = " (" + strings.Join(refs, ", ") + ")"
+			}
+			missing, err := repo.MissingParents(commit)
+			if err != nil {
+				fail("Cannot read %s: %s", commit.Hash, err)
+			}
+
+			if logOneline {
+				_ = tml.Printf("<yellow>%s</yellow>%s %s", short(commit.Hash), decoration, commit.Subject())
+				for _, parent := range missing {
+					_ = tml.Printf(" <red>[parent %s not recovered]</red>", short(parent))
+				}
+				fmt.Println()
+				continue
+			}
+
+			printCommitHeader(commit, decoration)
+			for _, parent := range missing {
+				printGap("parent %s was not recovered, so earlier history on this line is missing", parent)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+func printCommitHeader(commit *gitrepo.Commit, decoration string) {
+	_ = tml.Printf("<yellow>commit %s</yellow>%s\n", commit.Hash, decoration)
+	if len(commit.Parents) > 1 {
+		var parents []string
+		for _, parent := range commit.Parents {
+			parents = append(parents, short(parent))
+		}
+		fmt.Printf("Merge: %s\n", strings.Join(parents, " "))
+	}
+	fmt.Printf("Author: %s\n", commit.Author)
+	fmt.Printf("Date:   %s\n\n", commit.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	for _, line := range strings.Split(strings.TrimRight(commit.Message, "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+var showCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "show [revision]",
+	Short:        "Show a commit and its changes from a retrieved repository, marking content which could not be recovered",
+	Long: `Show a commit and its changes compared with its first parent, defaulting to HEAD.
+Files, directories and parents which could not be recovered are marked inline rather than aborting the output.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		repo := openRepository(repoDir)
+		defer func() { _ = repo.Close() }()
+
+		rev := "HEAD"
+		if len(args) > 0 {
+			rev = args[0]
+		}
+		hash := resolveRevision(repo, rev)
+
+		commit, err := repo.Commit(hash)
+		if errors.Is(err, gitrepo.ErrMissingObject) {
+			fail("Commit %s was not recovered.", hash)
+		} else if err != nil {
+			fail("Cannot read %s: %s", hash, err)
+		}
+
+		printCommitHeader(commit, "")
+		fmt.Println()
+
+		changes, err := repo.DiffCommit(commit)
+		if errors.Is(err, gitrepo.ErrMissingObject) {
+			printGap("parent %s was not recovered, so only the files in this commit can be listed", commit.Parents[0])
+			fmt.Println()
+			printFiles(repo, commit.Tree)
+			return
+		} else if err != nil {
+			fail("Cannot compare %s with its parent: %s", hash, err)
+		}
+
+		for _, change := range changes {
+			printChange(change)
+		}
+	},
+}
+
+func printFiles(repo *gitrepo.Repository, tree string) {
+	files, missingDirs, err := repo.Files(tree)
+	if err != nil {
+		fail("Cannot read tree %s: %s", tree, err)
+	}
+	for _, dir := range missingDirs {
+		if dir == "" {
+			printGap("tree %s was not recovered", tree)
+			continue
+		}
+		printGap("directory %s/ was not recovered", dir)
+	}
+	for _, file := range files {
+		exists, err := repo.Exists(file.Hash)
+		if err != nil {
+			fail("Cannot read %s: %s", file.Hash, err)
+		}
+		if exists || file.Mode == "160000" {
+			fmt.Println(file.Path)
+		} else {
+			_ = tml.Printf("%s <red>[not recovered]</red>\n", file.Path)
+		}
+	}
+}
+
+func printChange(change gitrepo.Change) {
+
+	if change.Status == gitrepo.StatusUnknown {
+		dir := "directory " + change.Path + "/"
+		if change.Path == "." {
+			dir = "the root directory"
+		}
+		printGap("%s was not recovered in one of the commits, so changes within it are unknown", dir)
+		fmt.Println()
+		return
+	}
+
+	_ = tml.Printf("<bold>diff --git a/%s b/%s</bold>\n", change.Path, change.Path)
+	oldPath, newPath := "a/"+change.Path, "b/"+change.Path
+	switch change.Status {
+	case gitrepo.StatusAdded:
+		fmt.Println("new file")
+		oldPath = "/dev/null"
+	case gitrepo.StatusDeleted:
+		fmt.Println("deleted file")
+		newPath = "/dev/null"
+	}
+
+	switch {
+	case change.Missing:
+		printGap("content was not recovered")
+		return
+	case change.Binary:
+		fmt.Printf("Binary files %s and %s differ\n", oldPath, newPath)
+		return
+	case change.TooLarge:
+		printGap("file is too large to compare")
+		return
+	}
+
+	_ = tml.Printf("<bold>--- %s</bold>\n<bold>+++ %s</bold>\n", oldPath, newPath)
+	for _, hunk := range change.Hunks {
+		_ = tml.Printf("<cyan>@@ -%d,%d +%d,%d @@</cyan>\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+		for _, line := range hunk.Lines {
+			switch line.Op {
+			case gitrepo.OpAdd:
+				_ = tml.Printf("<green>+%s</green>\n", line.Text)
+			case gitrepo.OpDelete:
+				_ = tml.Printf("<red>-%s</red>\n", line.Text)
+			default:
+				fmt.Printf(" %s\n", line.Text)
+			}
+		}
+	}
+}
+
+var catCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "cat <path>[@revision]",
+	Short:        "Print a file from a retrieved repository, falling back to the newest recovered version",
+	Long: `Print a file, or list a directory, as of a revision of a retrieved repository, defaulting to HEAD.
+If the file was not recovered at that revision, the newest earlier version which was recovered is printed instead, with a note on stderr. Use --exact to disable this.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		repo := openRepository(repoDir)
+		defer func() { _ = repo.Close() }()
+
+		path, rev := args[0], "HEAD"
+		if at := strings.LastIndex(path, "@"); at >= 0 {
+			path, rev = path[:at], path[at+1:]
+		}
+		path = strings.Trim(path, "/")
+		hash := resolveRevision(repo, rev)
+
+		commit, err := repo.Commit(hash)
+		if err == nil {
+			entry, err := repo.Lookup(commit.Tree, path)
+			if err == nil {
+				err = printEntry(repo, entry, path)
+			}
+			if !errors.Is(err, gitrepo.ErrMissingObject) {
+				if errors.Is(err, gitrepo.ErrPathNotFound) {
+					fail("%s does not exist at %s", path, rev)
+				} else if err != nil {
+					fail("Cannot read %s: %s", path, err)
+				}
+				return
+			}
+		} else if !errors.Is(err, gitrepo.ErrMissingObject) {
+			fail("Cannot read %s: %s", hash, err)
+		}
+
+		if catExact {
+			fail("%s at %s was not recovered", path, rev)
+		}
+
+		for _, earlier := range earlierCommits(repo, hash) {
+			entry, err := repo.Lookup(earlier.Tree, path)
+			if err != nil {
+				continue
+			}
+			if exists, err := repo.Exists(entry.Hash); err != nil || (!exists && !entry.IsSubmodule()) {
+				continue
+			}
+			_, _ = fmt.Fprintln(os.Stderr, tml.Sprintf("<yellow>%s at %s was not recovered, showing the version from commit %s (%s)</yellow>",
+				path, rev, short(earlier.Hash), earlier.Committer.When.Format("2006-01-02")))
+			if err := printEntry(repo, entry, path); err == nil {
+				return
+			} else if !errors.Is(err, gitrepo.ErrMissingObject) {
+				fail("Cannot read %s: %s", path, err)
+			}
+		}
+		fail("%s at %s was not recovered, and neither was any earlier version", path, rev)
+	},
+}
+
+// earlierCommits lists the history of a commit, newest first. Where gaps cut that history short, it continues
+// with any other recovered commits which are older, as the lost history most likely led to them.
+func earlierCommits(repo *gitrepo.Repository, hash string) []*gitrepo.Commit {
+
+	commits, err := repo.Log([]string{hash}, 0)
+	if err != nil {
+		fail("Cannot read history: %s", err)
+	}
+	tips, err := repo.Tips()
+	if err != nil {
+		fail("Cannot read refs: %s", err)
+	}
+	everything, err := repo.Log(tips, 0)
+	if err != nil {
+		fail("Cannot read history: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		seen[commit.Hash] = true
+	}
+	for _, commit := range everything {
+		if seen[commit.Hash] {
+			continue
+		}
+		if len(commits) > 0 && commit.Committer.When.After(commits[0].Committer.When) {
+			continue
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// printEntry writes a file as it is, or lists a directory, marking entries which could not be recovered
+func printEntry(repo *gitrepo.Repository, entry gitrepo.TreeEntry, path string) error {
+
+	switch {
+	case entry.IsSubmodule():
+		fmt.Printf("Submodule %s at commit %s\n", path, entry.Hash)
+		return nil
+	case !entry.IsDir():
+		content, err := repo.Blob(entry.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+
+	entries, err := repo.Tree(entry.Hash)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	for _, child := range entries {
+		name := child.Name
+		if child.IsDir() {
+			name += "/"
+		}
+		exists, err := repo.Exists(child.Hash)
+		if err != nil {
+			return err
+		}
+		if exists || child.IsSubmodule() {
+			fmt.Println(name)
+		} else {
+			_ = tml.Printf("%s <red>[not recovered]</red>\n", name)
+		}
+	}
+	return nil
+}
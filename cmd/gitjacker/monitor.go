@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/alert"
+	"github.com/liamg/gitjacker/internal/pkg/monitor"
+	"github.com/liamg/gitjacker/internal/pkg/results"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var monitorTargets string
+var monitorInterval time.Duration
+var monitorDataDir string
+var monitorListen string
+var monitorOnce bool
+
+func init() {
+	monitorCmd.Flags().StringVarP(&monitorTargets, "targets", "t", monitorTargets, "File containing target URLs to monitor, one per line")
+	monitorCmd.Flags().DurationVar(&monitorInterval, "interval", time.Hour, "Time between checks of each target")
+	monitorCmd.Flags().StringVar(&monitorDataDir, "data-dir", monitorDataDir, "Directory for monitor state and retrieved repositories - defaults to the user cache directory")
+	monitorCmd.Flags().StringVar(&monitorListen, "metrics-listen", "127.0.0.1:9090", "Address to serve Prometheus metrics on at /metrics - empty to disable")
+	monitorCmd.Flags().BoolVar(&monitorOnce, "once", monitorOnce, "Check every target once and exit")
+	_ = monitorCmd.MarkFlagRequired("targets")
+}
+
+var monitorCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "monitor",
+	Short:        "Re-check a list of targets on a schedule and report when their exposure changes",
+	Long: `Re-check a list of targets on a schedule and report when their exposure changes.
+Targets which become exposed, are fixed, or gain new commits are reported, and per-target status is available as Prometheus metrics.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		printBanner()
+
+		if _, err := localGitVersion(); err != nil {
+			fail("Cannot check git version: %s - please check it is installed", err)
+		}
+		prepareLogging()
+		alerts := alertDispatcher()
+
+		targets, err := monitor.ReadTargets(monitorTargets)
+		if err != nil {
+			fail("Failed to read targets: %s", err)
+		}
+		if len(targets) == 0 {
+			fail("No targets found in %s", monitorTargets)
+		}
+
+		if monitorDataDir == "" {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				fail("Cannot determine data directory, please specify --data-dir: %s", err)
+			}
+			monitorDataDir = filepath.Join(cacheDir, "gitjacker", "monitor")
+		}
+
+		m, err := monitor.New(targets, monitorDataDir, monitorInterval, retrieverOptions()...)
+		if err != nil {
+			fail("Failed to start monitor: %s", err)
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		db := openResults()
+		m.OnCheck = func(target *url.URL, started time.Time, summary *gitjacker.Summary) {
+			saveResult(db, results.KindMonitor, []string{target.String()}, started, summary)
+		}
+
+		m.OnTransition = func(transition monitor.Transition) {
+			timestamp := transition.Time.Local().Format("2006-01-02 15:04:05")
+			switch transition.Kind {
+			case monitor.TransitionNewlyExposed:
+				_ = tml.Printf("%s <red>EXPOSED</red>     %s\n", timestamp, transition.Target)
+			case monitor.TransitionFixed:
+				_ = tml.Printf("%s <green>FIXED</green>       %s\n", timestamp, transition.Target)
+			case monitor.TransitionNewCommits:
+				_ = tml.Printf("%s <yellow>NEW COMMITS</yellow> %s (%d)\n", timestamp, transition.Target, len(transition.NewCommits))
+			}
+			sendAlert(ctx, alerts, transition.Target, alert.Event(transition.Kind), transition.Summary)
+		}
+
+		_ = tml.Printf(`
+Targets:    <yellow>%d</yellow>
+Interval:   %s
+Data Dir:   %s
+Metrics:    %s
+
+`, len(targets), monitorInterval, monitorDataDir, metricsDescription())
+
+		if monitorOnce {
+			m.CheckAll(ctx)
+			printMonitorStates(m)
+			return
+		}
+
+		// the metrics server stops the monitor if it fails, so the error is reported from here rather than the
+		// server's goroutine
+		runCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		serveErr := make(chan error, 1)
+		if monitorListen != "" {
+			listener, err := net.Listen("tcp", monitorListen)
+			if err != nil {
+				fail("Failed to serve metrics: %s", err)
+			}
+			server := &http.Server{Handler: m}
+			go func() {
+				if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+					serveErr <- err
+					stop()
+				}
+			}()
+			defer func() { _ = server.Close() }()
+		}
+
+		_ = m.Run(runCtx)
+
+		select {
+		case err := <-serveErr:
+			fail("Failed to serve metrics: %s", err)
+		default:
+		}
+	},
+}
+
+func metricsDescription() string {
+	if monitorOnce || monitorListen == "" {
+		return "disabled"
+	}
+	return "http://" + monitorListen + "/metrics"
+}
+
+func printMonitorStates(m *monitor.Monitor) {
+	for _, state := range m.States() {
+		status := tml.Sprintf("<green>%-12s</green>", state.Exposure)
+		switch state.Exposure {
+		case monitor.ExposureExposed:
+			status = tml.Sprintf("<red>%-12s</red>", state.Exposure)
+		case monitor.ExposureUnknown:
+			status = tml.Sprintf("<yellow>%-12s</yellow>", state.Exposure)
+		}
+		detail := ""
+		if state.LastError != "" {
+			detail = " - " + strings.TrimSpace(state.LastError)
+		}
+		_ = tml.Printf("%s %s%s\n", status, state.Target, detail)
+	}
+	_ = tml.Printf("\n")
+}
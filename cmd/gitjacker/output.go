@@ -0,0 +1,20 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+var stdoutClaimedBy string
+
+// claimStdout reserves stdout for machine-readable output, moving everything else printed to stderr.
+// It must be called before anything else is printed.
+func claimStdout(flag string) io.Writer {
+	if stdoutClaimedBy != "" {
+		fail("Both %s and %s write to stdout - please send one of them to a file", stdoutClaimedBy, flag)
+	}
+	stdoutClaimedBy = flag
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	return stdout
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+
+	"github.com/liamg/gitjacker/internal/pkg/progress"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// startProgress shows live progress unless verbose logging is enabled, returning the option which feeds it and a function to stop it
+func startProgress() ([]gitjacker.Option, func()) {
+	if verbose {
+		return nil, func() {}
+	}
+	tracker := progress.NewTracker()
+	display := progress.NewDisplay(tracker, os.Stdout, progress.IsTerminal(os.Stdout))
+	display.Start()
+	return []gitjacker.Option{gitjacker.WithEventHandler(tracker.Handle)}, display.Stop
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/alert"
+	"github.com/liamg/gitjacker/internal/pkg/results"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var refreshCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "refresh [output-dir]",
+	Short:        "Update a previously recovered output directory from its original target",
+	Long: `Update a previously recovered output directory from its original target.
+Conditional requests are used for ref files, only new objects are fetched, and the working tree is updated in place.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		eventOpts, stopEvents := startEvents()
+		defer stopEvents()
+		writeReport := prepareReport()
+
+		printBanner()
+
+		outputDir = args[0]
+		gitVersion := prepare()
+		alerts := alertDispatcher()
+
+		state, err := gitjacker.LoadState(outputDir)
+		if err != nil {
+			if errors.Is(err, gitjacker.ErrNoState) {
+				fail("The provided directory was not created by a previous gitjacker run against a live target.\n\nError: %s", err)
+			}
+			fail("Refresh failed: %s", err)
+		}
+
+		_ = tml.Printf(`
+Target:       <yellow>%s</yellow>
+Local Git:    %s
+Output Dir:   %s
+Last Updated: %s
+`, strings.Join(state.Targets, ", "), gitVersion, outputDir, state.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+
+		progressOpts, stopProgress := startProgress()
+		retriever, err := gitjacker.NewRefresh(outputDir, append(append(retrieverOptions(), progressOpts...), eventOpts...)...)
+		if err != nil {
+			stopProgress()
+			fail("Refresh failed: %s", err)
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		started := time.Now()
+		summary, err := retriever.Run(ctx)
+		stopProgress()
+		if err != nil {
+			if errors.Is(err, gitjacker.ErrNotVulnerable) {
+				fail("The target no longer appears vulnerable.\n\nError: %s", err)
+			}
+			fail("Refresh failed: %s", err)
+		}
+
+		printSummary(summary)
+		writeReport(state.Targets, summary)
+		printResultID(saveResult(openResults(), results.KindRefresh, state.Targets, started, summary))
+
+		if len(summary.NewCommits) > 0 {
+			sendAlert(ctx, alerts, strings.Join(state.Targets, ", "), alert.EventNewCommits, summary)
+		}
+
+		if len(summary.RefUpdates) == 0 {
+			_ = tml.Printf("<green>No refs have moved since the last run.\n\n")
+			return
+		}
+
+		_ = tml.Printf("Refs moved since the last run:\n")
+		for _, update := range summary.RefUpdates {
+			switch {
+			case update.OldHash == "":
+				_ = tml.Printf("  - %s: <green>new</green> at %s\n", update.Name, short(update.NewHash))
+			case update.NewHash == "":
+				_ = tml.Printf("  - %s: <red>deleted</red> (was %s)\n", update.Name, short(update.OldHash))
+			default:
+				_ = tml.Printf("  - %s: %s -> <yellow>%s</yellow>\n", update.Name, short(update.OldHash), short(update.NewHash))
+			}
+		}
+
+		_ = tml.Printf("\nNew commits since the last run: <bold>%d</bold>\n", len(summary.NewCommits))
+		for _, hash := range summary.NewCommits {
+			_ = tml.Printf("  - <yellow>%s</yellow> %s\n", short(hash), commitSubject(hash))
+		}
+		_ = tml.Printf("\n")
+	},
+}
+
+func short(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+func commitSubject(hash string) string {
+	cmd := exec.Command("git", "show", "-s", "--format=%s", hash)
+	cmd.Dir = outputDir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
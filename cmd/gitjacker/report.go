@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/liamg/gitjacker/internal/pkg/report"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/spf13/cobra"
+)
+
+var reportFormat string
+var reportFile string
+var reportTemplate string
+
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&reportFormat, "report", reportFormat, "Write a report of the run to stdout in the given format: json, sarif, markdown or html")
+	cmd.Flags().StringVar(&reportFile, "report-file", reportFile, "Write the report to a file instead of stdout")
+	cmd.Flags().StringVar(&reportTemplate, "template", reportTemplate, "Write a report rendered with the given Go text/template file instead of a built in format")
+}
+
+// prepareReport validates the report flags and parses any template, returning a function which writes the report once the run is complete.
+// It must be called before anything else is printed, as other output is moved to stderr when the report is written to stdout.
+func prepareReport() func(targets []string, summary *gitjacker.Summary) {
+
+	if reportFormat == "" && reportTemplate == "" {
+		if reportFile != "" {
+			fail("--report-file requires a --report format or a --template")
+		}
+		return func([]string, *gitjacker.Summary) {}
+	}
+
+	flag := "--report"
+	var render func(io.Writer, *report.Report) error
+	switch {
+	case reportFormat != "" && reportTemplate != "":
+		fail("Only one of --report and --template may be specified")
+	case reportTemplate != "":
+		flag = "--template"
+		source, err := ioutil.ReadFile(reportTemplate)
+		if err != nil {
+			fail("Failed to read report template: %s", err)
+		}
+		tmpl, err := report.ParseTemplate(reportTemplate, string(source))
+		if err != nil {
+			fail("%s", err)
+		}
+		render = tmpl.Execute
+	default:
+		format, err := report.ParseFormat(reportFormat)
+		if err != nil {
+			fail("%s", err)
+		}
+		render = func(w io.Writer, r *report.Report) error {
+			return report.Write(w, r, format)
+		}
+	}
+
+	var stdout io.Writer
+	if reportFile == "" || reportFile == "-" {
+		stdout = claimStdout(flag)
+	}
+
+	return func(targets []string, summary *gitjacker.Summary) {
+		w := stdout
+		if w == nil {
+			f, err := os.Create(reportFile)
+			if err != nil {
+				fail("Failed to create report file: %s", err)
+			}
+			defer func() { _ = f.Close() }()
+			w = f
+		}
+		if err := render(w, report.New(targets, summary)); err != nil {
+			fail("Failed to write report: %s", err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/results"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var resultsDir string
+var noResults bool
+
+var resultsFilter results.Filter
+var resultsKind string
+var resultsSince string
+var resultsUntil string
+var exportFormat string
+var exportFile string
+
+func init() {
+	for _, cmd := range []*cobra.Command{resultsListCmd, resultsExportCmd} {
+		cmd.Flags().StringVar(&resultsFilter.Target, "target", "", "Only include runs against targets containing this text")
+		cmd.Flags().StringVar(&resultsKind, "kind", "", "Only include runs of this kind: retrieve, refresh, analyze, monitor or serve")
+		cmd.Flags().StringVar(&resultsFilter.Status, "status", "", "Only include runs with this status: success, \"partial success\" or failure")
+		cmd.Flags().StringVar(&resultsFilter.Identity, "identity", "", "Only include runs which exposed an identity containing this text")
+		cmd.Flags().StringVar(&resultsFilter.Finding, "finding", "", "Only include runs with a finding whose name contains this text, e.g. aws, credential:mysql or file:kubeconfig")
+		cmd.Flags().StringVar(&resultsSince, "since", "", "Only include runs finished after this date (2006-01-02), time (RFC 3339) or duration ago (e.g. 720h)")
+		cmd.Flags().StringVar(&resultsUntil, "until", "", "Only include runs finished before this date, time or duration ago")
+		cmd.Flags().IntVar(&resultsFilter.Limit, "limit", 0, "Maximum number of runs to include")
+	}
+
+	resultsExportCmd.Flags().StringVar(&exportFormat, "format", string(results.FormatJSON), "Export format: json, ndjson or csv")
+	resultsExportCmd.Flags().StringVar(&exportFile, "file", "", "File to export to - defaults to stdout")
+
+	resultsCmd.AddCommand(resultsListCmd)
+	resultsCmd.AddCommand(resultsShowCmd)
+	resultsCmd.AddCommand(resultsDiffCmd)
+	resultsCmd.AddCommand(resultsExportCmd)
+}
+
+// openResults opens the results database, returning nil if saving results is disabled or unavailable
+func openResults() *results.DB {
+
+	if noResults {
+		return nil
+	}
+
+	if resultsDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			logrus.Debugf("Cannot determine results directory: %s", err)
+			return nil
+		}
+		resultsDir = filepath.Join(userCacheDir, "gitjacker", "results")
+	}
+
+	db, err := results.Open(resultsDir)
+	if err != nil {
+		logrus.Debugf("Failed to open results database: %s", err)
+		return nil
+	}
+	return db
+}
+
+func saveResult(db *results.DB, kind results.Kind, targets []string, started time.Time, summary *gitjacker.Summary) string {
+	if db == nil || summary == nil {
+		return ""
+	}
+	record, err := db.Save(results.NewRecord(kind, targets, started, summary))
+	if err != nil {
+		logrus.Errorf("Failed to save result: %s", err)
+		return ""
+	}
+	return record.ID
+}
+
+func printResultID(id string) {
+	if id != "" {
+		_ = tml.Printf("Result ID:  <bold>%s</bold> (see gitjacker results show)\n\n", id)
+	}
+}
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Query the results of previous runs",
+	Long: `Query the results of previous runs.
+Every retrieval, refresh, analysis, monitor check and served job is saved to a local results database, unless --no-results is given.`,
+}
+
+func requireResults() *results.DB {
+	db := openResults()
+	if db == nil {
+		fail("The results database is not available - please specify --results-dir")
+	}
+	return db
+}
+
+func parseResultsFilter() results.Filter {
+	filter := resultsFilter
+	filter.Kind = results.Kind(resultsKind)
+	var err error
+	if filter.Since, err = parseTime(resultsSince); err != nil {
+		fail("Invalid --since: %s", err)
+	}
+	if filter.Until, err = parseTime(resultsUntil); err != nil {
+		fail("Invalid --until: %s", err)
+	}
+	return filter
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+var resultsListCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "list",
+	Short:        "List previous runs, newest first",
+	Args:         cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		records, err := requireResults().List(parseResultsFilter())
+		if err != nil {
+			fail("Failed to list results: %s", err)
+		}
+
+		if len(records) == 0 {
+			_ = tml.Printf("<yellow>No results found.\n")
+			return
+		}
+
+		for _, record := range records {
+			status := tml.Sprintf("<green>%-15s</green>", record.Status)
+			switch record.Status {
+			case gitjacker.StatusPartialSuccess.String():
+				status = tml.Sprintf("<yellow>%-15s</yellow>", record.Status)
+			case gitjacker.StatusFailure.String():
+				status = tml.Sprintf("<red>%-15s</red>", record.Status)
+			}
+			_ = tml.Printf("<bold>%s</bold>  %s  %-8s %s %5d/%-5d  %s\n",
+				record.ID,
+				record.FinishedAt.Local().Format("2006-01-02 15:04"),
+				record.Kind,
+				status,
+				record.FoundObjects,
+				record.FoundObjects+record.MissingObjects,
+				strings.Join(record.Targets, ", "),
+			)
+		}
+	},
+}
+
+var resultsShowCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "show [id]",
+	Short:        "Show the full summary of a previous run",
+	Args:         cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		record, err := requireResults().Get(args[0])
+		if err != nil {
+			fail("%s", err)
+		}
+
+		_ = tml.Printf(`
+Result:     <bold>%s</bold>
+Kind:       %s
+Target:     <yellow>%s</yellow>
+Started:    %s
+Finished:   %s
+Identities: %s
+Findings:   %s
+`,
+			record.ID,
+			record.Kind,
+			strings.Join(record.Targets, ", "),
+			record.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			record.FinishedAt.Local().Format("2006-01-02 15:04:05"),
+			orNA(strings.Join(record.Identities, ", ")),
+			orNA(strings.Join(record.FindingNames(), ", ")),
+		)
+
+		if record.Summary != nil {
+			printSummary(record.Summary)
+		}
+	},
+}
+
+func orNA(value string) string {
+	if value == "" {
+		return "n/a"
+	}
+	return value
+}
+
+var resultsDiffCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "diff [from-id] [to-id]",
+	Short:        "Show what changed between two previous runs",
+	Args:         cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		db := requireResults()
+		from, err := db.Get(args[0])
+		if err != nil {
+			fail("%s", err)
+		}
+		to, err := db.Get(args[1])
+		if err != nil {
+			fail("%s", err)
+		}
+
+		diff := results.Compare(from, to)
+
+		_ = tml.Printf("\nStatus:  %s -> %s\n", diff.StatusFrom, diff.StatusTo)
+		_ = tml.Printf("Objects: <green>+%d</green> <red>-%d</red>\n", len(diff.ObjectsAdded), len(diff.ObjectsRemoved))
+
+		_ = tml.Printf("\nRefs:\n")
+		if len(diff.RefUpdates) == 0 {
+			_ = tml.Printf("  no changes\n")
+		}
+		for _, update := range diff.RefUpdates {
+			switch {
+			case update.OldHash == "":
+				_ = tml.Printf("  <green>+ %s</green> %s\n", update.Name, short(update.NewHash))
+			case update.NewHash == "":
+				_ = tml.Printf("  <red>- %s</red> %s\n", update.Name, short(update.OldHash))
+			default:
+				_ = tml.Printf("  <yellow>~ %s</yellow> %s -> %s\n", update.Name, short(update.OldHash), short(update.NewHash))
+			}
+		}
+
+		_ = tml.Printf("\nIdentities:\n")
+		if len(diff.IdentitiesAdded)+len(diff.IdentitiesRemoved) == 0 {
+			_ = tml.Printf("  no changes\n")
+		}
+		for _, identity := range diff.IdentitiesAdded {
+			_ = tml.Printf("  <green>+ %s</green>\n", identity)
+		}
+		for _, identity := range diff.IdentitiesRemoved {
+			_ = tml.Printf("  <red>- %s</red>\n", identity)
+		}
+		fmt.Println()
+	},
+}
+
+var resultsExportCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "export",
+	Short:        "Export previous runs as JSON, NDJSON or CSV",
+	Args:         cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		db := requireResults()
+		records, err := db.List(parseResultsFilter())
+		if err != nil {
+			fail("Failed to list results: %s", err)
+		}
+
+		format := results.Format(exportFormat)
+		if format != results.FormatCSV {
+			// structured formats include each run's full summary
+			for i, record := range records {
+				full, err := db.Get(record.ID)
+				if err != nil {
+					fail("Failed to load result %s: %s", record.ID, err)
+				}
+				records[i] = *full
+			}
+		}
+
+		var w io.Writer = os.Stdout
+		if exportFile != "" {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				fail("Failed to create export file: %s", err)
+			}
+			defer func() { _ = f.Close() }()
+			w = f
+		}
+
+		if err := results.Export(w, records, format); err != nil {
+			fail("Export failed: %s", err)
+		}
+	},
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/liamg/gitjacker/internal/pkg/analysis"
+	"github.com/liamg/gitjacker/internal/pkg/results"
+	"github.com/liamg/gitjacker/internal/pkg/server"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var serveListen string
+var serveDataDir string
+var serveWorkers int
+var serveMaxQueued int
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8080", "Address to serve the API on")
+	serveCmd.Flags().StringVar(&serveDataDir, "data-dir", serveDataDir, "Directory for the job store and retrieved repositories - defaults to the user cache directory")
+	serveCmd.Flags().IntVar(&serveWorkers, "workers", 2, "Number of jobs to run concurrently")
+	serveCmd.Flags().IntVar(&serveMaxQueued, "max-queued", 1024, "Number of jobs which may wait to run, beyond which submissions are refused with 503")
+	serveCmd.Flags().StringVar(&cacheDir, "cache-dir", cacheDir, "Directory of a shared object cache, used by jobs which request it")
+}
+
+var serveCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "serve",
+	Short:        "Run a local HTTP API for submitting and tracking retrieval jobs",
+	Long: `Run a local HTTP API for submitting and tracking retrieval jobs.
+Jobs are queued in a persistent store and survive restarts. Endpoints:
+
+  POST /jobs                 submit {"targets": ["https://victim.website/", ...], "cache": false}, optionally
+                             with "timeout": "30s", "proxy": "socks5://127.0.0.1:9050" and "analysis": false
+  GET  /jobs                 list jobs
+  GET  /jobs/{id}            get a job and its progress
+  GET  /jobs/{id}/events     stream progress events as newline-delimited JSON
+  GET  /jobs/{id}/summary    get the summary of a finished job
+  GET  /jobs/{id}/artifact   download the retrieved repository as a .tar.gz`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		printBanner()
+
+		if _, err := localGitVersion(); err != nil {
+			fail("Cannot check git version: %s - please check it is installed", err)
+		}
+		prepareLogging()
+
+		if serveDataDir == "" {
+			userCacheDir, err := os.UserCacheDir()
+			if err != nil {
+				fail("Cannot determine data directory, please specify --data-dir: %s", err)
+			}
+			serveDataDir = filepath.Join(userCacheDir, "gitjacker", "server")
+		}
+
+		var analysers []gitjacker.Analyser
+		if !noAnalysis {
+			analysers = append(analysers, analysis.Analyse)
+		}
+
+		db := openResults()
+		s, err := server.New(server.Config{
+			DataDir:   serveDataDir,
+			CacheDir:  cacheDir,
+			Workers:   serveWorkers,
+			MaxQueued: serveMaxQueued,
+			Options:   connectionOptions(),
+			Analysers: analysers,
+			OnComplete: func(job server.Job) {
+				saveResult(db, results.KindServe, job.Request.Targets, *job.StartedAt, job.Summary)
+			},
+		})
+		if err != nil {
+			fail("Failed to start server: %s", err)
+		}
+
+		_ = tml.Printf(`
+Listening:  <yellow>http://%s</yellow>
+Workers:    %d
+Data Dir:   %s
+
+`, serveListen, serveWorkers, serveDataDir)
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		httpServer := &http.Server{Addr: serveListen, Handler: s}
+		go func() {
+			<-ctx.Done()
+			_ = httpServer.Close()
+		}()
+
+		go s.Run(ctx)
+
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fail("Failed to serve API: %s", err)
+		}
+	},
+}
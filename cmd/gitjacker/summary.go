@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/app/version"
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/liamg/tml"
+)
+
+func printBanner() {
+	_ = tml.Printf(`<red>
+ ██████  ██ ████████   ██  █████   ██████ ██   ██ ███████ ██████  
+██       ██    ██      ██ ██   ██ ██      ██  ██  ██      ██   ██ 
+██   ███ ██    ██      ██ ███████ ██      █████   █████   ██████  
+██    ██ ██    ██ ██   ██ ██   ██ ██      ██  ██  ██      ██   ██ 
+ ██████  ██    ██  █████  ██   ██  ██████ ██   ██ ███████ ██   ██
+https://github.com/liamg/gitjacker                      %9s
+`, version.Version)
+}
+
+func localGitVersion() (string, error) {
+	versionData, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	versionParts := strings.Split(string(versionData), " ")
+	return strings.TrimSpace(versionParts[len(versionParts)-1]), nil
+}
+
+func printSummary(summary *gitjacker.Summary) {
+
+	status := "FAILED"
+	switch summary.Status {
+	case gitjacker.StatusPartialSuccess:
+		status = tml.Sprintf("<yellow>Partial Success")
+	case gitjacker.StatusSuccess:
+		status = tml.Sprintf("<green>Success")
+	}
+
+	var remoteStr string
+	for _, remote := range summary.Config.Remotes {
+		remoteStr = tml.Sprintf("%s\n  - %s: <bold>%s", remoteStr, remote.Name, remote.URL)
+	}
+	if len(summary.Config.Remotes) == 0 {
+		remoteStr = "n/a"
+	}
+
+	var branchStr string
+	for _, branch := range summary.Config.Branches {
+		branchStr = tml.Sprintf("%s\n  - %s (%s)", branchStr, branch.Name, branch.Remote)
+	}
+	if len(summary.Config.Branches) == 0 {
+		branchStr = "n/a"
+	}
+
+	var userStr string
+	if summary.Config.User.Name != "" {
+		userStr = tml.Sprintf("%s\n  - Name:         %s", userStr, summary.Config.User.Name)
+	}
+	if summary.Config.User.Username != "" {
+		userStr = tml.Sprintf("%s\n  - Username:     <bold>%s", userStr, summary.Config.User.Username)
+	}
+	if summary.Config.User.Email != "" {
+		userStr = tml.Sprintf("%s\n  - Email:        <bold>%s", userStr, summary.Config.User.Email)
+	}
+	if summary.Config.GithubToken.Token != "" {
+		userStr = tml.Sprintf("%s\n  - GitHub Token: %s:<bold>%s", userStr, summary.Config.GithubToken.Username, summary.Config.GithubToken.Token)
+	}
+
+	if userStr == "" {
+		userStr = "n/a"
+	}
+
+	var secretStr string
+	for _, secret := range summary.Analysis.Secrets {
+		secretStr = tml.Sprintf("%s\n  - %s: <bold>%s</bold>\n    %s:%d, %s", secretStr, secret.Description, truncate(secret.Value, 80), secret.Path, secret.Line, secret.History())
+	}
+	if len(summary.Analysis.Secrets) == 0 {
+		secretStr = "n/a"
+	}
+
+	var artifactStr string
+	for _, artifact := range summary.Analysis.Artifacts {
+		artifactStr = tml.Sprintf("%s\n  - %s: <bold>%s</bold>", artifactStr, artifact.Description, artifact.Path)
+		for _, detail := range artifact.Details {
+			artifactStr = tml.Sprintf("%s\n    %s", artifactStr, truncate(detail, 100))
+		}
+		artifactStr = tml.Sprintf("%s\n    %s", artifactStr, artifact.History())
+	}
+	if len(summary.Analysis.Artifacts) == 0 {
+		artifactStr = "n/a"
+	}
+
+	var credentialStr string
+	for _, credential := range summary.Analysis.Credentials {
+		credentialStr = tml.Sprintf("%s\n  - %s: <bold>%s</bold>\n    %s:%d, %s", credentialStr, credential.Format, truncate(credential.String(), 80), credential.Path, credential.Line, credential.History())
+	}
+	if len(summary.Analysis.Credentials) == 0 {
+		credentialStr = "n/a"
+	}
+
+	endpointStr := endpointSummary(summary.Analysis.Endpoints)
+	mentionStr := mentionSummary(summary.Analysis.Mentions)
+
+	sourceCounts := make(map[string]int)
+	for _, source := range summary.ObjectSources {
+		sourceCounts[source]++
+	}
+	var sourceStr string
+	if len(sourceCounts) > 1 {
+		var sources []string
+		for source := range sourceCounts {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			sourceStr = tml.Sprintf("%s\n  - %s: <bold>%d</bold> objects", sourceStr, source, sourceCounts[source])
+		}
+		sourceStr = tml.Sprintf("\nObject Sources:    %s", sourceStr)
+	}
+
+	var analysisStr string
+	for _, analysisErr := range summary.AnalysisErrors {
+		analysisStr = tml.Sprintf("%s\n  - <red>%s</red>", analysisStr, analysisErr)
+	}
+	if analysisStr != "" {
+		analysisStr = tml.Sprintf("\nAnalysis Errors:   %s", analysisStr)
+	}
+
+	_ = tml.Printf(`
+Status:            %s
+Retrieved Objects: <green>%d</green>
+Missing Objects:   <red>%d</red>
+Cached Objects:    %d
+Pack Data Listed:  %t
+Smart HTTP:        %t
+Repository:        %s
+Remotes:           %s
+Branches:          %s
+User Info:         %s
+Sensitive Files:   %s
+Secrets:           %s
+Credentials:       %s
+Endpoints:         %s
+Commit Messages:   %s%s%s
+
+You can find the retrieved repository data in <blue><bold>%s</bold></blue>
+
+`,
+		status,
+		len(summary.FoundObjects),
+		len(summary.MissingObjects),
+		len(summary.CachedObjects),
+		summary.PackInformationAvailable,
+		summary.SmartProtocolAvailable,
+		summary.Config.RepositoryName,
+		remoteStr,
+		branchStr,
+		userStr,
+		artifactStr,
+		secretStr,
+		credentialStr,
+		endpointStr,
+		mentionStr,
+		sourceStr,
+		analysisStr,
+		summary.OutputDirectory,
+	)
+}
+
+// truncate shortens long values, such as private keys, to keep the summary readable
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
+
+func fail(format string, args ...interface{}) {
+	_, _ = fmt.Fprintln(os.Stderr, tml.Sprintf("<red>%s", fmt.Sprintf(format, args...)))
+	os.Exit(1)
+}
+
+// summaryEndpoints is how many endpoints of each kind are listed in the summary, the rest being left to reports
+const summaryEndpoints = 10
+
+// endpointSummary counts endpoints by kind, listing the infrastructure itself. URLs, routes and third parties
+// are usually too numerous to list.
+func endpointSummary(endpoints []exposed.Endpoint) string {
+	if len(endpoints) == 0 {
+		return "n/a"
+	}
+	var kinds []string
+	byKind := make(map[string][]exposed.Endpoint)
+	for _, endpoint := range endpoints {
+		if _, ok := byKind[endpoint.Kind]; !ok {
+			kinds = append(kinds, endpoint.Kind)
+		}
+		byKind[endpoint.Kind] = append(byKind[endpoint.Kind], endpoint)
+	}
+
+	var s string
+	for _, kind := range kinds {
+		found := byKind[kind]
+		s = tml.Sprintf("%s\n  - %s: <bold>%d</bold>", s, kind, len(found))
+		switch kind {
+		case "url", "route", "third-party":
+			continue
+		}
+		for i, endpoint := range found {
+			if i == summaryEndpoints {
+				s = tml.Sprintf("%s\n    and %d more", s, len(found)-i)
+				break
+			}
+			detail := endpoint.Detail
+			if detail != "" {
+				detail = " (" + detail + ")"
+			}
+			s = tml.Sprintf("%s\n    %s%s, %s:%d", s, endpoint.Value, detail, endpoint.Locations[0].Path, endpoint.Locations[0].Line)
+		}
+	}
+	return s
+}
+
+// summaryMentions is how many mentions of each kind are listed in the summary, the rest being left to reports
+const summaryMentions = 5
+
+// mentionSummary counts what commit messages mention by kind, listing those mentioned most often
+func mentionSummary(mentions []exposed.Mention) string {
+	if len(mentions) == 0 {
+		return "n/a"
+	}
+	var kinds []string
+	byKind := make(map[string][]exposed.Mention)
+	for _, mention := range mentions {
+		if _, ok := byKind[mention.Kind]; !ok {
+			kinds = append(kinds, mention.Kind)
+		}
+		byKind[mention.Kind] = append(byKind[mention.Kind], mention)
+	}
+
+	var s string
+	for _, kind := range kinds {
+		found := byKind[kind]
+		s = tml.Sprintf("%s\n  - %s: <bold>%d</bold>", s, kind, len(found))
+		for i, mention := range found {
+			if i == summaryMentions {
+				s = tml.Sprintf("%s\n    and %d more", s, len(found)-i)
+				break
+			}
+			where := mention.Sources[0].Type
+			if commit := mention.Sources[0].Commit; len(commit) >= 8 {
+				where = commit[:8]
+			}
+			if len(mention.Sources) > 1 {
+				where = fmt.Sprintf("%s and %d more", where, len(mention.Sources)-1)
+			}
+			s = tml.Sprintf("%s\n    %s, %s", s, truncate(mention.Value, 80), where)
+		}
+	}
+	return s
+}
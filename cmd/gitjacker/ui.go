@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/liamg/gitjacker/internal/pkg/webui"
+	"github.com/liamg/tml"
+	"github.com/spf13/cobra"
+)
+
+var uiListen string
+
+func init() {
+	uiCmd.Flags().StringVar(&uiListen, "listen", "127.0.0.1:8090", "Address to serve the web interface on")
+}
+
+var uiCmd = &cobra.Command{
+	SilenceUsage: true,
+	Use:          "ui [dir...]",
+	Short:        "Serve a local web interface for browsing one or more retrieved repositories",
+	Long: `Serve a local web interface for browsing one or more retrieved repositories, with a file browser, commit graph,
+per-file history and blame, and a findings panel across every target. Partially retrieved repositories are fully supported.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		printBanner()
+
+		ui, err := webui.New(args)
+		if err != nil {
+			fail("Cannot open repository: %s", err)
+		}
+		defer func() { _ = ui.Close() }()
+
+		_ = tml.Printf("\nListening:  <yellow>http://%s/</yellow>\n", uiListen)
+		for _, target := range ui.Targets {
+			_ = tml.Printf("Target:     %s (%s)\n", target.Name, target.Dir)
+		}
+		_ = tml.Printf("\n")
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		httpServer := &http.Server{Addr: uiListen, Handler: ui}
+		go func() {
+			<-ctx.Done()
+			_ = httpServer.Close()
+		}()
+
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fail("Failed to serve web interface: %s", err)
+		}
+	},
+}
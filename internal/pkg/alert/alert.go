@@ -0,0 +1,174 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// Event describes why an alert was raised - names match the monitor's transition kinds
+type Event string
+
+const (
+	EventRetrieved    Event = "retrieved"
+	EventNewlyExposed Event = "newly-exposed"
+	EventFixed        Event = "fixed"
+	EventNewCommits   Event = "new-commits"
+)
+
+// Alert is a finding about a single target, delivered to every configured sink
+type Alert struct {
+	Target         string    `json:"target"`
+	Event          Event     `json:"event"`
+	Time           time.Time `json:"time"`
+	Status         string    `json:"status"`
+	FoundObjects   int       `json:"found_objects"`
+	MissingObjects int       `json:"missing_objects"`
+	NewCommits     int       `json:"new_commits"`
+	Identities     []string  `json:"identities,omitempty"`
+	Secrets        int       `json:"secrets"`
+	Credentials    int       `json:"credentials"`
+	Artifacts      int       `json:"sensitive_files"`
+	Message        string    `json:"message"`
+}
+
+// Sink delivers alerts to a destination such as a webhook, syslog, email or a local spool
+type Sink interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+const DefaultTemplate = `[gitjacker] {{ .Event }}: {{ .Target }}{{ if .Status }} - status {{ .Status }}, {{ .FoundObjects }} objects retrieved, {{ .MissingObjects }} missing{{ end }}{{ if .NewCommits }}, {{ .NewCommits }} new commits{{ end }}{{ if .Secrets }}, {{ .Secrets }} secrets found{{ end }}{{ if .Credentials }}, {{ .Credentials }} credentials found{{ end }}{{ if .Artifacts }}, {{ .Artifacts }} sensitive files found{{ end }}{{ if .Identities }}, exposed identities: {{ join .Identities ", " }}{{ end }}`
+
+// New builds an alert from a retrieval summary, which may be nil when the target is no longer exposed
+func New(target string, event Event, summary *gitjacker.Summary) Alert {
+
+	a := Alert{
+		Target: target,
+		Event:  event,
+		Time:   time.Now().UTC(),
+	}
+
+	if summary == nil {
+		return a
+	}
+
+	a.Status = summary.Status.String()
+	a.FoundObjects = len(summary.FoundObjects)
+	a.MissingObjects = len(summary.MissingObjects)
+	a.NewCommits = len(summary.NewCommits)
+	a.Identities = summary.Identities()
+	a.Secrets = len(summary.Analysis.Secrets)
+	a.Credentials = len(summary.Analysis.Credentials)
+	a.Artifacts = len(summary.Analysis.Artifacts)
+
+	return a
+}
+
+// Dispatcher renders alert messages and sends them to every sink
+type Dispatcher struct {
+	sinks    []Sink
+	template *template.Template
+}
+
+func NewDispatcher(sinks []Sink, messageTemplate string) (*Dispatcher, error) {
+	if messageTemplate == "" {
+		messageTemplate = DefaultTemplate
+	}
+	tmpl, err := template.New("alert").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(messageTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+	return &Dispatcher{
+		sinks:    sinks,
+		template: tmpl,
+	}, nil
+}
+
+func (d *Dispatcher) Send(ctx context.Context, alert Alert) error {
+
+	message := &bytes.Buffer{}
+	if err := d.template.Execute(message, alert); err != nil {
+		return fmt.Errorf("failed to render alert: %w", err)
+	}
+	alert.Message = message.String()
+
+	var failures []string
+	for _, sink := range d.sinks {
+		if err := sink.Send(ctx, alert); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to send alert: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Parse creates a sink from a URL, e.g. https://hooks.example/x, syslog://host:514, smtp://host:25?to=a@b or file:///var/spool/alerts.ndjson
+func Parse(spec string) (Sink, error) {
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert sink %q: %w", spec, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return NewWebhookSink(spec), nil
+	case "syslog", "syslog+udp", "syslog+tcp":
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			addr += ":514"
+		}
+		return NewSyslogSink(network, addr), nil
+	case "smtp":
+		query := u.Query()
+		var recipients []string
+		for _, recipient := range strings.Split(query.Get("to"), ",") {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				recipients = append(recipients, recipient)
+			}
+		}
+		if len(recipients) == 0 {
+			return nil, fmt.Errorf("invalid alert sink %q: smtp sinks require at least one recipient in ?to=", spec)
+		}
+		from := query.Get("from")
+		if from == "" {
+			from = "gitjacker@localhost"
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			addr += ":25"
+		}
+		sink := NewSMTPSink(addr, from, recipients)
+		if u.User != nil {
+			password, _ := u.User.Password()
+			sink.Username = u.User.Username()
+			sink.Password = password
+		}
+		return sink, nil
+	case "file":
+		path := u.Path
+		if u.Host != "" {
+			path = u.Host + path
+		}
+		if path == "" {
+			return nil, fmt.Errorf("invalid alert sink %q: no file path", spec)
+		}
+		return NewFileSink(path), nil
+	}
+
+	return nil, fmt.Errorf("invalid alert sink %q: unsupported scheme %q", spec, u.Scheme)
+}
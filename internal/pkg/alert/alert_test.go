@@ -0,0 +1,263 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/testutil"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestNewAlert(t *testing.T) {
+	a := New("http://victim.example", EventRetrieved, testutil.Summary())
+	assert.Equal(t, a.Status, "partial success")
+	assert.Equal(t, a.FoundObjects, 1)
+	assert.Equal(t, a.MissingObjects, 1)
+	assert.Equal(t, a.Identities, []string{"Dev | Ops <dev@example.com>", "github:dev"})
+}
+
+func TestDispatcherRendersTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_alert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	spool := filepath.Join(dir, "alerts.ndjson")
+
+	dispatcher, err := NewDispatcher([]Sink{NewFileSink(spool)}, "{{ .Event }} {{ .Target }} {{ join .Identities \",\" }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.Send(context.Background(), New("http://victim.example", EventNewlyExposed, testutil.Summary())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	var received Alert
+	if err := json.Unmarshal([]byte(lines[0]), &received); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, received.Message, "newly-exposed http://victim.example Dev | Ops <dev@example.com>,github:dev")
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- a
+	}))
+	defer server.Close()
+
+	sink, err := Parse(server.URL + "/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Send(context.Background(), New("http://victim.example", EventFixed, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	a := <-received
+	assert.Equal(t, a.Target, "http://victim.example")
+	assert.Equal(t, a.Event, EventFixed)
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	sink, err := Parse("syslog://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New("http://victim.example", EventNewlyExposed, testutil.Summary())
+	a.Message = "exposed repository"
+	if err := sink.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buffer := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := string(buffer[:n])
+	assert.Equal(t, strings.HasPrefix(message, "<12>1 "), true)
+	assert.Equal(t, strings.HasSuffix(message, " gitjacker "+fmt.Sprint(os.Getpid())+" - - exposed repository"), true)
+}
+
+// fakeSMTPServer accepts a single message and returns its DATA section
+func fakeSMTPServer(t *testing.T, listener net.Listener) <-chan string {
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = fmt.Fprintf(conn, "%s\r\n", line) }
+
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					messages <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case command == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return messages
+}
+
+func TestSMTPSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	messages := fakeSMTPServer(t, listener)
+
+	sink, err := Parse(fmt.Sprintf("smtp://%s?from=gitjacker@example.com&to=security@example.com", listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New("http://victim.example", EventNewlyExposed, testutil.Summary())
+	a.Message = "exposed repository"
+	if err := sink.Send(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, strings.Contains(message, "Subject: [gitjacker] newly-exposed: http://victim.example\r\n"), true)
+		assert.Equal(t, strings.Contains(message, "exposed repository"), true)
+	case <-time.After(time.Second * 5):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSMTPSinkStopsWithContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// the server accepts the connection but never greets the client
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = ioutil.ReadAll(conn)
+	}()
+
+	sink := NewSMTPSink(listener.Addr().String(), "gitjacker@example.com", []string{"security@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	started := time.Now()
+	err = sink.Send(ctx, New("http://victim.example", EventNewlyExposed, testutil.Summary()))
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+	assert.Equal(t, time.Since(started) < time.Second*5, true)
+}
+
+func TestSMTPSinkTimesOut(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = ioutil.ReadAll(conn)
+	}()
+
+	defer func(timeout time.Duration) { sendTimeout = timeout }(sendTimeout)
+	sendTimeout = time.Millisecond * 100
+
+	sink := NewSMTPSink(listener.Addr().String(), "gitjacker@example.com", []string{"security@example.com"})
+
+	// the context has no deadline, so the sink's own timeout has to stop it
+	started := time.Now()
+	err = sink.Send(context.Background(), New("http://victim.example", EventNewlyExposed, testutil.Summary()))
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, time.Since(started) < time.Second*5, true)
+}
+
+func TestParseRejectsInvalidSinks(t *testing.T) {
+	for _, spec := range []string{
+		"ftp://example.com",
+		"smtp://localhost:25",
+		"file://",
+	} {
+		_, err := Parse(spec)
+		assert.Equal(t, err != nil, true, spec)
+	}
+}
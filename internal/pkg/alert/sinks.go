@@ -0,0 +1,261 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sendTimeout bounds each connection made to send an alert, unless the context has an earlier deadline, so that
+// a server which accepts the connection but never replies cannot hang the sender
+var sendTimeout = time.Second * 10
+
+// connDeadline is when a connection made to send an alert gives up
+func connDeadline(ctx context.Context) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(sendTimeout)
+}
+
+// WebhookSink POSTs each alert as JSON
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url: url,
+		client: &http.Client{
+			Timeout: sendTimeout,
+		},
+	}
+}
+
+func (s *WebhookSink) Send(ctx context.Context, alert Alert) error {
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", s.url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status code %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// SyslogSink sends RFC 5424 messages to a syslog server
+type SyslogSink struct {
+	network string
+	addr    string
+}
+
+func NewSyslogSink(network string, addr string) *SyslogSink {
+	return &SyslogSink{
+		network: network,
+		addr:    addr,
+	}
+}
+
+const (
+	facilityUser    = 1
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+func (s *SyslogSink) Send(ctx context.Context, alert Alert) error {
+
+	severity := severityWarning
+	if alert.Event == EventFixed {
+		severity = severityNotice
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	message := fmt.Sprintf("<%d>1 %s %s gitjacker %d - - %s",
+		facilityUser*8+severity,
+		alert.Time.Format(time.RFC3339),
+		hostname,
+		os.Getpid(),
+		strings.ReplaceAll(alert.Message, "\n", " "),
+	)
+
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return fmt.Errorf("syslog %s: %w", s.addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(connDeadline(ctx))
+
+	// stream transports need framing, as described in RFC 6587
+	if s.network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	if _, err := conn.Write([]byte(message)); err != nil {
+		return fmt.Errorf("syslog %s: %w", s.addr, err)
+	}
+	return nil
+}
+
+// SMTPSink emails each alert to a list of recipients
+type SMTPSink struct {
+	addr       string
+	from       string
+	recipients []string
+	Username   string
+	Password   string
+}
+
+func NewSMTPSink(addr string, from string, recipients []string) *SMTPSink {
+	return &SMTPSink{
+		addr:       addr,
+		from:       from,
+		recipients: recipients,
+	}
+}
+
+func (s *SMTPSink) Send(ctx context.Context, alert Alert) error {
+
+	subject := fmt.Sprintf("[gitjacker] %s: %s", alert.Event, alert.Target)
+
+	body := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(body, "From: %s\r\n", s.from)
+	_, _ = fmt.Fprintf(body, "To: %s\r\n", strings.Join(s.recipients, ", "))
+	_, _ = fmt.Fprintf(body, "Subject: %s\r\n", subject)
+	_, _ = fmt.Fprintf(body, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	_, _ = fmt.Fprintf(body, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	_, _ = fmt.Fprintf(body, "%s\r\n", strings.ReplaceAll(alert.Message, "\n", "\r\n"))
+
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("smtp %s: %w", s.addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// net/smtp does not take a context, so the connection is closed to interrupt it once the context is done
+	_ = conn.SetDeadline(connDeadline(ctx))
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := s.send(conn, host, body.Bytes()); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("smtp %s: %w", s.addr, err)
+	}
+	return nil
+}
+
+// send delivers a message over an open connection, as smtp.SendMail would
+func (s *SMTPSink) send(conn net.Conn, host string, message []byte) error {
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, recipient := range s.recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileSink appends each alert to a local file as a line of JSON
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{
+		path: path,
+	}
+}
+
+func (s *FileSink) Send(_ context.Context, alert Alert) error {
+
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("file %s: %w", s.path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
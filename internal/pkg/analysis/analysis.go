@@ -0,0 +1,283 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"path"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// maxFileSize is the largest file which is read, as anything bigger is almost certainly generated or binary
+const maxFileSize = 8 << 20
+
+// sniffSize is how much of a bigger file is given to detectors which recognise files by their start
+const sniffSize = 64 << 10
+
+// Analyse is a gitjacker.Analyser which scans every recoverable commit of a retrieved repository, adding what
+// it finds to the summary
+func Analyse(ctx context.Context, dir string, summary *gitjacker.Summary) error {
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = repo.Close() }()
+
+	result, err := Scan(ctx, repo)
+	if err != nil {
+		return err
+	}
+	summary.Analysis = *result
+	return nil
+}
+
+// Scan reads every file of every commit which can be reached from HEAD, a ref or a reflog entry. Each file is
+// only read once, however many commits it appears in. Commit, tag and reflog messages are read too.
+func Scan(ctx context.Context, repo *gitrepo.Repository) (*exposed.Result, error) {
+	h, err := walk(ctx, repo, []detector{detectSecrets, detectCredentials, detectArtifacts, detectEndpoints}, []detector{detectArtifacts})
+	if err != nil {
+		return nil, err
+	}
+	result := &exposed.Result{Secrets: h.secrets(), Credentials: h.credentials(), Artifacts: h.artifacts()}
+	result.Endpoints = credentialEndpoints(h.endpoints(), result.Credentials)
+	sortEndpoints(result.Endpoints)
+	if result.Mentions, err = h.mentions(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// detector finds things of interest in a file. Results may only depend on the name and content of the file,
+// as they are reused wherever the same file appears in history.
+type detector func(name string, content []byte) []hit
+
+// hit is something found by a detector
+type hit struct {
+	kind string
+	// key identifies the same thing in other versions of the file, so it can be followed through history
+	key   string
+	line  int
+	value interface{}
+}
+
+type pathHit struct {
+	path string
+	hit  hit
+}
+
+// tracked follows a hit through every commit it appears in
+type tracked struct {
+	path string
+	hit  hit
+	// newest is the newest commit containing the hit, which hit was taken from
+	newest  *gitrepo.Commit
+	commits map[string]bool
+}
+
+type history struct {
+	repo      *gitrepo.Repository
+	detectors []detector
+	sniffers  []detector
+	head      string
+	// log lists recovered commits newest first
+	log      []*gitrepo.Commit
+	commits  map[string]*gitrepo.Commit
+	children map[string][]string
+	found    []*tracked
+	index    map[string]*tracked
+	blobs    map[string][]hit
+	trees    map[string][]pathHit
+}
+
+// walk runs detectors over every file in history. Files bigger than maxFileSize are only given to sniffers, cut
+// down to sniffSize.
+func walk(ctx context.Context, repo *gitrepo.Repository, detectors []detector, sniffers []detector) (*history, error) {
+
+	tips, err := repo.Tips()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Log(tips, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &history{
+		repo:      repo,
+		detectors: detectors,
+		sniffers:  sniffers,
+		log:       commits,
+		commits:   make(map[string]*gitrepo.Commit),
+		children:  make(map[string][]string),
+		index:     make(map[string]*tracked),
+		blobs:     make(map[string][]hit),
+		trees:     make(map[string][]pathHit),
+	}
+	if head, err := repo.Head(); err == nil {
+		h.head = head
+	}
+
+	// commits are newest first, so the first commit a hit is seen in is the newest
+	for _, commit := range commits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h.commits[commit.Hash] = commit
+		for _, parent := range commit.Parents {
+			h.children[parent] = append(h.children[parent], commit.Hash)
+		}
+		hits, err := h.tree(commit.Tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, found := range hits {
+			id := found.hit.kind + "\x00" + found.path + "\x00" + found.hit.key
+			t, ok := h.index[id]
+			if !ok {
+				t = &tracked{path: found.path, hit: found.hit, newest: commit, commits: make(map[string]bool)}
+				h.index[id] = t
+				h.found = append(h.found, t)
+			}
+			t.commits[commit.Hash] = true
+		}
+	}
+	return h, nil
+}
+
+// tree returns the hits in every file below a tree, with paths relative to it. Directories which could not be
+// recovered are skipped.
+func (h *history) tree(hash string) ([]pathHit, error) {
+	if hits, ok := h.trees[hash]; ok {
+		return hits, nil
+	}
+	entries, err := h.repo.Tree(hash)
+	if errors.Is(err, gitrepo.ErrMissingObject) {
+		h.trees[hash] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var hits []pathHit
+	for _, entry := range entries {
+		switch {
+		case entry.IsSubmodule(), entry.Mode == "120000":
+			continue
+		case entry.IsDir():
+			below, err := h.tree(entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+			for _, found := range below {
+				hits = append(hits, pathHit{path: path.Join(entry.Name, found.path), hit: found.hit})
+			}
+		default:
+			found, err := h.blob(entry.Name, entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+			for _, hit := range found {
+				hits = append(hits, pathHit{path: entry.Name, hit: hit})
+			}
+		}
+	}
+	h.trees[hash] = hits
+	return hits, nil
+}
+
+func (h *history) blob(name string, hash string) ([]hit, error) {
+	id := name + "\x00" + hash
+	if hits, ok := h.blobs[id]; ok {
+		return hits, nil
+	}
+	content, err := h.repo.Blob(hash)
+	if errors.Is(err, gitrepo.ErrMissingObject) {
+		h.blobs[id] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var hits []hit
+	if len(content) <= maxFileSize {
+		for _, detect := range h.detectors {
+			hits = append(hits, detect(name, content)...)
+		}
+	} else {
+		for _, detect := range h.sniffers {
+			hits = append(hits, detect(name, content[:sniffSize])...)
+		}
+	}
+	h.blobs[id] = hits
+	return hits, nil
+}
+
+// tracked lists everything found by detectors of the given kind, in the order it was first seen
+func (h *history) tracked(kind string) []*tracked {
+	var found []*tracked
+	for _, t := range h.found {
+		if t.hit.kind == kind {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// occurrence works out when a hit was added to and removed from history. Where lines of history diverge, the
+// oldest commit to add or remove it is used.
+func (h *history) occurrence(t *tracked) exposed.Occurrence {
+
+	occurrence := exposed.Occurrence{
+		Path:    t.path,
+		Line:    t.hit.line,
+		Commit:  t.newest.Hash,
+		Present: t.commits[h.head],
+	}
+
+	var introduced, removed *gitrepo.Commit
+	for hash := range t.commits {
+		commit := h.commits[hash]
+
+		var inParent, missingParent bool
+		for _, parent := range commit.Parents {
+			if _, recovered := h.commits[parent]; !recovered {
+				missingParent = true
+			} else if t.commits[parent] {
+				inParent = true
+			}
+		}
+		if !inParent && older(commit, introduced) {
+			introduced = commit
+			occurrence.IntroducedEarlier = missingParent
+		}
+
+		for _, child := range h.children[hash] {
+			if !t.commits[child] && older(h.commits[child], removed) {
+				removed = h.commits[child]
+			}
+		}
+	}
+
+	if introduced != nil {
+		occurrence.Introduced = introduced.Hash
+		occurrence.IntroducedAt = introduced.Committer.When
+	}
+	if removed != nil {
+		occurrence.Removed = removed.Hash
+		occurrence.RemovedAt = removed.Committer.When
+	}
+	return occurrence
+}
+
+// older orders commits by commit time, then hash so that the choice between commits made at the same time
+// does not depend on map order
+func older(commit *gitrepo.Commit, than *gitrepo.Commit) bool {
+	if than == nil {
+		return true
+	}
+	if !commit.Committer.When.Equal(than.Committer.When) {
+		return commit.Committer.When.Before(than.Committer.When)
+	}
+	return commit.Hash < than.Hash
+}
@@ -0,0 +1,667 @@
+package analysis
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+const kindArtifact = "artifact"
+
+type artifactMatch struct {
+	kind        string
+	description string
+	score       int
+	details     []string
+	keys        []exposed.Key
+}
+
+// classifiers recognise sensitive files by name and content. They are tried in order, and the first to recognise
+// a file classifies it, so more specific classifiers come first.
+var classifiers = []func(name string, content []byte) (artifactMatch, bool){
+	classifyKeys,
+	classifyKeystore,
+	classifyCredentialsFile,
+	classifyTerraformState,
+	classifyKubeconfig,
+	classifyHtpasswd,
+	classifySQLDump,
+	classifySQLite,
+	classifyArchive,
+	classifyBackup,
+}
+
+// maxDetails limits lists of names in details, such as the tables of a database
+const maxDetails = 10
+
+// detectArtifacts classifies files which are valuable by themselves, rather than for something written in them
+func detectArtifacts(name string, content []byte) []hit {
+	for _, classify := range classifiers {
+		match, ok := classify(strings.ToLower(name), content)
+		if !ok {
+			continue
+		}
+		// a different key in the same file is a different artifact
+		key := match.kind
+		for _, k := range match.keys {
+			key += "\x00" + k.Fingerprint
+		}
+		return []hit{{kind: kindArtifact, key: key, value: match}}
+	}
+	return nil
+}
+
+func classifyKeys(name string, content []byte) (artifactMatch, bool) {
+
+	if bytes.HasPrefix(content, []byte("PuTTY-User-Key-File-")) {
+		return readPuttyKey(content), true
+	}
+
+	ext := path.Ext(name)
+	if !bytes.Contains(content, []byte("-----BEGIN ")) && ext != ".der" && ext != ".cer" && ext != ".crt" {
+		return artifactMatch{}, false
+	}
+	keys := readKeys(content)
+	if len(keys) == 0 {
+		return artifactMatch{}, false
+	}
+
+	match := artifactMatch{kind: "certificate", description: "Certificate", score: 30, keys: keys}
+	for _, key := range keys {
+		if key.Type == "private key" {
+			match.kind, match.description, match.score = "private-key", "Private key", 100
+		}
+		match.details = append(match.details, key.String())
+	}
+	return match, true
+}
+
+// readPuttyKey reads the headers of a PuTTY private key, e.g. "PuTTY-User-Key-File-2: ssh-rsa"
+func readPuttyKey(content []byte) artifactMatch {
+	key := exposed.Key{Type: "private key"}
+	var comment string
+	for _, line := range gitrepo.SplitLines(string(content)) {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(parts[0], "PuTTY-User-Key-File-"):
+			key.Algorithm = parts[1]
+		case parts[0] == "Encryption":
+			key.Encrypted = parts[1] != "none"
+		case parts[0] == "Comment":
+			comment = parts[1]
+		}
+	}
+	match := artifactMatch{kind: "private-key", description: "Private key", score: 100, keys: []exposed.Key{key}}
+	match.details = append(match.details, "PuTTY "+key.String())
+	if comment != "" {
+		match.details = append(match.details, "comment: "+comment)
+	}
+	return match
+}
+
+func classifyKeystore(name string, content []byte) (artifactMatch, bool) {
+
+	match := artifactMatch{kind: "keystore", description: "Keystore", score: 90}
+	ext := path.Ext(name)
+
+	switch {
+	case bytes.HasPrefix(content, []byte{0xfe, 0xed, 0xfe, 0xed}), bytes.HasPrefix(content, []byte{0xce, 0xce, 0xce, 0xce}):
+		format := "Java keystore (JKS)"
+		if content[0] == 0xce {
+			format = "Java keystore (JCEKS)"
+		}
+		match.details = append(match.details, format)
+		if len(content) >= 12 {
+			match.details = append(match.details, fmt.Sprintf("%d entries", binary.BigEndian.Uint32(content[8:12])))
+		}
+	case isPKCS12(content):
+		match.details = append(match.details, "PKCS#12")
+	case ext == ".p12" || ext == ".pfx" || ext == ".jks" || ext == ".keystore" || ext == ".bks":
+		match.details = append(match.details, "unrecognised content")
+	default:
+		return match, false
+	}
+	return match, true
+}
+
+// isPKCS12 recognises the start of a PKCS#12 file, a DER sequence beginning with version 3
+func isPKCS12(content []byte) bool {
+	if len(content) < 8 || content[0] != 0x30 {
+		return false
+	}
+	// lengths over 127 bytes are given in the number of bytes after the first
+	offset := 2
+	if content[1] > 0x80 {
+		offset += int(content[1] & 0x7f)
+	}
+	return offset+3 <= len(content) && bytes.Equal(content[offset:offset+3], []byte{0x02, 0x01, 0x03})
+}
+
+func classifyCredentialsFile(name string, content []byte) (artifactMatch, bool) {
+
+	text := string(content)
+	match := artifactMatch{kind: "credentials-file", score: 95}
+
+	switch {
+	case (name == "credentials" || name == "config") && strings.Contains(strings.ToLower(text), "aws_access_key_id"):
+		match.description = "AWS credentials file"
+		match.details = iniSections(text, "profiles")
+	case path.Ext(name) == ".json" && strings.Contains(text, `"service_account"`) && strings.Contains(text, `"private_key"`):
+		match.description = "Google Cloud service account key"
+		match.details = jsonFields(content, "client_email", "project_id", "private_key_id")
+	case path.Ext(name) == ".json" && strings.Contains(text, `"authorized_user"`) && strings.Contains(text, `"refresh_token"`):
+		match.description = "Google Cloud user credentials"
+		match.details = jsonFields(content, "client_id", "quota_project_id")
+	case name == "accesstokens.json" || name == "msal_token_cache.json":
+		match.description = "Azure CLI tokens"
+	case name == "config.json" && strings.Contains(text, `"auths"`) && strings.Contains(text, `"auth"`):
+		match.description = "Docker registry credentials"
+		match.details = jsonKeys(content, "auths", "registries")
+	case name == ".git-credentials":
+		match.description = "Git credentials"
+		match.details = lineHosts(text, "hosts")
+	case name == ".netrc" || name == "_netrc":
+		match.description = "netrc credentials"
+		match.details = netrcMachines(text)
+	case name == ".pgpass":
+		match.description = "PostgreSQL password file"
+		match.details = pgpassHosts(text)
+	case name == ".npmrc" && (strings.Contains(text, "_authToken") || strings.Contains(text, "_auth=")):
+		match.description = "npm registry token"
+	case name == ".pypirc" && strings.Contains(text, "password"):
+		match.description = "PyPI credentials"
+		match.details = iniSections(text, "repositories")
+	case name == ".s3cfg" && strings.Contains(text, "secret_key"):
+		match.description = "s3cmd credentials"
+	default:
+		return match, false
+	}
+	return match, true
+}
+
+func classifyTerraformState(name string, content []byte) (artifactMatch, bool) {
+
+	isState := strings.HasSuffix(name, ".tfstate") || strings.HasSuffix(name, ".tfstate.backup")
+	if !isState && !(path.Ext(name) == ".json" && bytes.Contains(content, []byte(`"terraform_version"`))) {
+		return artifactMatch{}, false
+	}
+	match := artifactMatch{kind: "terraform-state", description: "Terraform state", score: 85}
+
+	var state struct {
+		TerraformVersion string `json:"terraform_version"`
+		Resources        []struct {
+			Type string `json:"type"`
+		} `json:"resources"`
+		// modules hold the resources of state from before Terraform 0.12
+		Modules []struct {
+			Resources map[string]struct {
+				Type string `json:"type"`
+			} `json:"resources"`
+		} `json:"modules"`
+		Outputs map[string]struct {
+			Sensitive bool `json:"sensitive"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		match.details = append(match.details, "could not be parsed")
+		return match, true
+	}
+	if state.TerraformVersion != "" {
+		match.details = append(match.details, "Terraform "+state.TerraformVersion)
+	}
+
+	var types []string
+	for _, resource := range state.Resources {
+		types = append(types, resource.Type)
+	}
+	for _, module := range state.Modules {
+		for _, resource := range module.Resources {
+			types = append(types, resource.Type)
+		}
+	}
+	if len(types) > 0 {
+		match.details = append(match.details, fmt.Sprintf("%d resources: %s", len(types), list(unique(types))))
+	}
+
+	var outputs []string
+	for output, value := range state.Outputs {
+		if value.Sensitive {
+			output += " (sensitive)"
+		}
+		outputs = append(outputs, output)
+	}
+	if len(outputs) > 0 {
+		sort.Strings(outputs)
+		match.details = append(match.details, "outputs: "+list(outputs))
+	}
+	return match, true
+}
+
+func classifyKubeconfig(name string, content []byte) (artifactMatch, bool) {
+
+	text := string(content)
+	if !kubeconfigKind.MatchString(text) || !strings.Contains(text, "clusters:") {
+		return artifactMatch{}, false
+	}
+	match := artifactMatch{kind: "kubeconfig", description: "Kubernetes config", score: 85}
+
+	var servers, auth []string
+	var current string
+	for _, line := range gitrepo.SplitLines(text) {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, _ := unquote(strings.TrimSpace(parts[1]))
+		switch parts[0] {
+		case "server":
+			servers = append(servers, value)
+		case "current-context":
+			current = value
+		case "client-key-data", "client-key":
+			auth = append(auth, "client certificate")
+		case "token", "token-file":
+			auth = append(auth, "token")
+		case "password":
+			auth = append(auth, "password")
+		case "exec":
+			auth = append(auth, "exec plugin")
+		case "auth-provider":
+			auth = append(auth, "auth provider")
+		}
+	}
+	if len(servers) > 0 {
+		match.details = append(match.details, "servers: "+list(unique(servers)))
+	}
+	if current != "" {
+		match.details = append(match.details, "current context: "+current)
+	}
+	if len(auth) > 0 {
+		match.details = append(match.details, "authentication: "+list(unique(auth)))
+	}
+	return match, true
+}
+
+var kubeconfigKind = regexp.MustCompile(`(?m)^kind:\s*["']?Config["']?\s*$`)
+
+func classifyHtpasswd(name string, content []byte) (artifactMatch, bool) {
+
+	if !strings.HasPrefix(name, ".htpasswd") && !strings.HasPrefix(name, "htpasswd") {
+		return artifactMatch{}, false
+	}
+	match := artifactMatch{kind: "htpasswd", description: "Apache password file", score: 80}
+
+	var users []string
+	for _, line := range gitrepo.SplitLines(string(content)) {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.HasPrefix(parts[0], "#") {
+			continue
+		}
+		users = append(users, fmt.Sprintf("%s (%s)", parts[0], hashType(parts[1])))
+	}
+	if len(users) > 0 {
+		match.details = append(match.details, "users: "+list(users))
+	}
+	return match, true
+}
+
+// hashType names the scheme of a password hash from an htpasswd file
+func hashType(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return "bcrypt"
+	case strings.HasPrefix(hash, "$apr1$"):
+		return "Apache MD5"
+	case strings.HasPrefix(hash, "{SHA}"):
+		return "SHA-1"
+	case strings.HasPrefix(hash, "$5$"):
+		return "SHA-256 crypt"
+	case strings.HasPrefix(hash, "$6$"):
+		return "SHA-512 crypt"
+	case strings.HasPrefix(hash, "$1$"):
+		return "MD5 crypt"
+	case len(hash) == 13:
+		return "DES crypt"
+	}
+	return "plain text"
+}
+
+func classifySQLDump(name string, content []byte) (artifactMatch, bool) {
+
+	match := artifactMatch{kind: "sql-dump", description: "SQL dump", score: 75}
+
+	for _, ext := range []string{".sql.gz", ".sql.bz2", ".sql.xz", ".sql.zip"} {
+		if strings.HasSuffix(name, ext) {
+			match.details = append(match.details, "compressed")
+			return match, true
+		}
+	}
+	if bytes.HasPrefix(content, []byte("PGDMP")) {
+		match.details = append(match.details, "PostgreSQL custom format")
+		return match, true
+	}
+
+	text := string(content)
+	var dialect string
+	switch {
+	case strings.Contains(text, "-- MySQL dump"), strings.Contains(text, "-- MariaDB dump"):
+		dialect = "MySQL"
+	case strings.Contains(text, "-- PostgreSQL database dump"):
+		dialect = "PostgreSQL"
+	case strings.HasPrefix(text, "PRAGMA foreign_keys=OFF;"):
+		dialect = "SQLite"
+	}
+
+	// schemas and migrations are common in source, so plain SQL files only count as dumps if they hold data
+	var tables []string
+	statements := 0
+	for _, match := range sqlData.FindAllStringSubmatch(text, -1) {
+		statements++
+		tables = append(tables, strings.Trim(match[1], "`\"[]"))
+	}
+	if dialect == "" && (path.Ext(name) != ".sql" || statements == 0) {
+		return match, false
+	}
+
+	if dialect != "" {
+		match.details = append(match.details, dialect)
+	}
+	if statements > 0 {
+		match.details = append(match.details, fmt.Sprintf("%d statements with data, in tables: %s", statements, list(unique(tables))))
+	}
+	return match, true
+}
+
+var sqlData = regexp.MustCompile(`(?im)^\s*(?:INSERT\s+INTO|COPY)\s+((?:[\w"` + "`" + `\[\]]+\.)?[\w"` + "`" + `\[\]]+)`)
+
+func classifySQLite(name string, content []byte) (artifactMatch, bool) {
+
+	if !bytes.HasPrefix(content, []byte("SQLite format 3\x00")) {
+		return artifactMatch{}, false
+	}
+	match := artifactMatch{kind: "sqlite-database", description: "SQLite database", score: 70}
+
+	// the schema is stored as the text of the statements which created it
+	var tables []string
+	for _, match := range sqliteTable.FindAllSubmatch(content, -1) {
+		tables = append(tables, strings.Trim(string(match[1]), "`\"[]'"))
+	}
+	if len(tables) > 0 {
+		match.details = append(match.details, "tables: "+list(unique(tables)))
+	}
+	return match, true
+}
+
+var sqliteTable = regexp.MustCompile(`CREATE TABLE\s+(?:IF NOT EXISTS\s+)?([\w"` + "`" + `\[\]']+)`)
+
+// archiveFormats are recognised by their magic numbers. Formats which are also used for other kinds of file,
+// such as zip for Java archives and Office documents, are only recognised with the usual extensions.
+var archiveFormats = []struct {
+	name       string
+	magic      []byte
+	offset     int
+	extensions []string
+}{
+	{name: "zip", magic: []byte("PK\x03\x04"), extensions: []string{".zip"}},
+	{name: "gzip", magic: []byte{0x1f, 0x8b}, extensions: []string{".gz", ".tgz"}},
+	{name: "bzip2", magic: []byte("BZh"), extensions: []string{".bz2", ".tbz2"}},
+	{name: "xz", magic: []byte("\xfd7zXZ\x00")},
+	{name: "7-Zip", magic: []byte("7z\xbc\xaf\x27\x1c")},
+	{name: "RAR", magic: []byte("Rar!\x1a\x07")},
+	{name: "tar", magic: []byte("ustar"), offset: 257},
+}
+
+func classifyArchive(name string, content []byte) (artifactMatch, bool) {
+
+	ext := path.Ext(name)
+	for _, format := range archiveFormats {
+		if len(content) < format.offset+len(format.magic) || !bytes.Equal(content[format.offset:format.offset+len(format.magic)], format.magic) {
+			continue
+		}
+		if format.extensions != nil && !contains(format.extensions, ext) {
+			continue
+		}
+		match := artifactMatch{kind: "backup-archive", description: "Archive", score: 60, details: []string{format.name}}
+		var entries []string
+		switch format.name {
+		case "zip":
+			entries = zipEntries(content)
+		case "tar":
+			entries = tarEntries(content)
+		case "gzip":
+			if original := gzipName(content); original != "" {
+				entries = []string{original}
+			}
+		}
+		if len(entries) > 0 {
+			match.details = append(match.details, "contains: "+list(entries))
+		}
+		return match, true
+	}
+	return artifactMatch{}, false
+}
+
+// zipEntries lists the names of the files in a zip archive. When only the start of the archive was read, its
+// central directory is missing, so the names are taken from the headers before each file instead.
+func zipEntries(content []byte) []string {
+	var entries []string
+	if r, err := zip.NewReader(bytes.NewReader(content), int64(len(content))); err == nil {
+		for _, f := range r.File {
+			entries = append(entries, f.Name)
+		}
+		return entries
+	}
+	for offset := 0; offset+30 <= len(content) && bytes.Equal(content[offset:offset+4], []byte("PK\x03\x04")); {
+		compressed := int(binary.LittleEndian.Uint32(content[offset+18:]))
+		nameLength := int(binary.LittleEndian.Uint16(content[offset+26:]))
+		extraLength := int(binary.LittleEndian.Uint16(content[offset+28:]))
+		flags := binary.LittleEndian.Uint16(content[offset+6:])
+		if offset+30+nameLength > len(content) {
+			break
+		}
+		entries = append(entries, string(content[offset+30:offset+30+nameLength]))
+		// the size of entries written as a stream follows their data, so the next header cannot be found
+		if flags&0x08 != 0 {
+			break
+		}
+		offset += 30 + nameLength + extraLength + compressed
+	}
+	return entries
+}
+
+// tarEntries lists the names in the headers of a tar archive
+func tarEntries(content []byte) []string {
+	var entries []string
+	for offset := 0; offset+512 <= len(content) && bytes.Equal(content[offset+257:offset+262], []byte("ustar")); {
+		entries = append(entries, cString(content[offset:offset+100]))
+		var size int64
+		_, _ = fmt.Sscanf(strings.TrimSpace(cString(content[offset+124:offset+136])), "%o", &size)
+		offset += 512 + int((size+511)/512*512)
+	}
+	return entries
+}
+
+// gzipName reads the original name of a compressed file from its gzip header
+func gzipName(content []byte) string {
+	const flagName = 0x08
+	if len(content) < 10 || content[3]&flagName == 0 {
+		return ""
+	}
+	offset := 10
+	if content[3]&0x04 != 0 && len(content) >= 12 {
+		offset += 2 + int(binary.LittleEndian.Uint16(content[10:]))
+	}
+	if offset >= len(content) {
+		return ""
+	}
+	return cString(content[offset:])
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// backupSuffixes are added by editors and by hand to copies of files
+var backupSuffixes = []string{".bak", ".backup", ".old", ".orig", ".save", ".swp", "~"}
+
+func classifyBackup(name string, content []byte) (artifactMatch, bool) {
+	for _, suffix := range backupSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			original := strings.TrimPrefix(strings.TrimSuffix(name, suffix), ".")
+			if suffix == ".swp" {
+				return artifactMatch{kind: "backup-file", description: "Editor swap file", score: 50, details: []string{"swap file of " + original}}, true
+			}
+			return artifactMatch{kind: "backup-file", description: "Backup copy", score: 50, details: []string{"copy of " + original}}, true
+		}
+	}
+	return artifactMatch{}, false
+}
+
+func iniSections(text string, label string) []string {
+	var sections []string
+	for _, line := range gitrepo.SplitLines(text) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, strings.TrimSpace(strings.TrimPrefix(line[1:len(line)-1], "profile ")))
+		}
+	}
+	if len(sections) == 0 {
+		return nil
+	}
+	return []string{label + ": " + list(sections)}
+}
+
+func jsonFields(content []byte, names ...string) []string {
+	var fields map[string]interface{}
+	if json.Unmarshal(content, &fields) != nil {
+		return nil
+	}
+	var details []string
+	for _, name := range names {
+		if value, ok := fields[name].(string); ok && value != "" {
+			details = append(details, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	return details
+}
+
+func jsonKeys(content []byte, field string, label string) []string {
+	var fields map[string]map[string]interface{}
+	if json.Unmarshal(content, &fields) != nil {
+		return nil
+	}
+	var keys []string
+	for key := range fields[field] {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return []string{label + ": " + list(keys)}
+}
+
+// lineHosts lists the hosts of the URLs in a .git-credentials file
+func lineHosts(text string, label string) []string {
+	var hosts []string
+	for _, line := range gitrepo.SplitLines(text) {
+		if c, ok := parseEndpoint(line); ok {
+			hosts = append(hosts, c.username+"@"+c.host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	return []string{label + ": " + list(unique(hosts))}
+}
+
+func netrcMachines(text string) []string {
+	var machines []string
+	fields := strings.Fields(text)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "machine" {
+			machines = append(machines, fields[i+1])
+		}
+	}
+	if len(machines) == 0 {
+		return nil
+	}
+	return []string{"machines: " + list(unique(machines))}
+}
+
+func pgpassHosts(text string) []string {
+	var hosts []string
+	for _, line := range gitrepo.SplitLines(text) {
+		parts := strings.Split(line, ":")
+		if len(parts) == 5 && !strings.HasPrefix(parts[0], "#") {
+			hosts = append(hosts, fmt.Sprintf("%s@%s:%s/%s", parts[3], parts[0], parts[1], parts[2]))
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	return []string{"logins: " + list(unique(hosts))}
+}
+
+// unique removes repeated values, keeping the first of each
+func unique(values []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// list joins values with commas, saying how many more there are beyond maxDetails
+func list(values []string) string {
+	if len(values) > maxDetails {
+		return fmt.Sprintf("%s and %d more", strings.Join(values[:maxDetails], ", "), len(values)-maxDetails)
+	}
+	return strings.Join(values, ", ")
+}
+
+func (h *history) artifacts() []exposed.Artifact {
+	var artifacts []exposed.Artifact
+	for _, t := range h.tracked(kindArtifact) {
+		match := t.hit.value.(artifactMatch)
+		artifacts = append(artifacts, exposed.Artifact{
+			Kind:        match.kind,
+			Description: match.description,
+			Score:       match.score,
+			Details:     match.details,
+			Keys:        match.keys,
+			Occurrence:  h.occurrence(t),
+		})
+	}
+	// the most valuable first, then the ones which are still there
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		if artifacts[i].Score != artifacts[j].Score {
+			return artifacts[i].Score > artifacts[j].Score
+		}
+		if artifacts[i].Present != artifacts[j].Present {
+			return artifacts[i].Present
+		}
+		return artifacts[i].Path < artifacts[j].Path
+	})
+	return artifacts
+}
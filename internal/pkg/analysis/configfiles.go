@@ -0,0 +1,313 @@
+package analysis
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// setting is a key and value read from a configuration file. Keys of nested settings are joined with dots.
+type setting struct {
+	key   string
+	value string
+	line  int
+	// unknown is set when the value is an expression, such as a lookup of an environment variable, rather than a literal
+	unknown bool
+}
+
+// parseEnv reads KEY=value lines, as used by dotenv files
+func parseEnv(content string) []setting {
+	var settings []setting
+	for i, line := range gitrepo.SplitLines(content) {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:eq])
+		value, quoted := unquote(stripComment(strings.TrimSpace(line[eq+1:])))
+		settings = append(settings, setting{key: key, value: value, line: i + 1, unknown: !quoted && strings.HasPrefix(value, "${")})
+	}
+	return settings
+}
+
+// parsePHPDefines reads constants defined with define('NAME', 'value'), as used by wp-config.php
+func parsePHPDefines(content string) []setting {
+	var settings []setting
+	for i, line := range gitrepo.SplitLines(content) {
+		for _, match := range phpDefine.FindAllStringSubmatch(line, -1) {
+			settings = append(settings, setting{key: match[1], value: match[2] + match[3], line: i + 1})
+		}
+	}
+	return settings
+}
+
+var phpDefine = regexp.MustCompile(`define\(\s*['"]([A-Za-z0-9_]+)['"]\s*,\s*(?:'([^']*)'|"([^"]*)")\s*\)`)
+
+// parseProperties reads Java properties files, joining lines continued with a backslash
+func parseProperties(content string) []setting {
+	var settings []setting
+	lines := gitrepo.SplitLines(content)
+	for i := 0; i < len(lines); i++ {
+		start := i
+		line := strings.TrimSpace(lines[i])
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + strings.TrimSpace(lines[i])
+		}
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		sep := strings.IndexAny(line, "=: \t")
+		if sep <= 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[sep+1:])
+		if line[sep] == ' ' || line[sep] == '\t' {
+			value = strings.TrimSpace(strings.TrimLeft(value, "=:"))
+		}
+		settings = append(settings, setting{key: line[:sep], value: value, line: start + 1, unknown: strings.HasPrefix(value, "${")})
+	}
+	return settings
+}
+
+// parseYAML flattens the mappings of a YAML document into dotted keys, following merge keys such as
+// "<<: *default" as used by Rails. Sequences and multi-line values are skipped.
+func parseYAML(content string) []setting {
+
+	type level struct {
+		indent int
+		key    string
+	}
+	type alias struct {
+		path   string
+		anchor string
+	}
+
+	var stack []level
+	var settings []setting
+	var aliases []alias
+	anchors := make(map[string]string)
+
+	for i, raw := range gitrepo.SplitLines(content) {
+		line := strings.TrimRight(stripComment(raw), " \t")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		colon := strings.Index(trimmed, ": ")
+		if colon < 0 {
+			if !strings.HasSuffix(trimmed, ":") {
+				continue
+			}
+			colon = len(trimmed) - 1
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		key, _ := unquote(strings.TrimSpace(trimmed[:colon]))
+		value := strings.TrimSpace(trimmed[colon+1:])
+		var parent []string
+		for _, l := range stack {
+			parent = append(parent, l.key)
+		}
+		path := strings.Join(append(parent, key), ".")
+
+		switch {
+		case key == "<<" && strings.HasPrefix(value, "*"):
+			aliases = append(aliases, alias{path: strings.Join(parent, "."), anchor: value[1:]})
+		case strings.HasPrefix(value, "&"):
+			anchors[value[1:]] = path
+			stack = append(stack, level{indent: indent, key: key})
+		case value == "":
+			stack = append(stack, level{indent: indent, key: key})
+		case value == "|" || value == ">":
+			continue
+		default:
+			unquoted, _ := unquote(value)
+			settings = append(settings, setting{key: path, value: unquoted, line: i + 1, unknown: strings.Contains(unquoted, "<%") || strings.HasPrefix(unquoted, "${")})
+		}
+	}
+
+	// merged settings are overridden by any set alongside the merge key
+	for _, a := range aliases {
+		base, ok := anchors[a.anchor]
+		if !ok {
+			continue
+		}
+		existing := make(map[string]bool)
+		for _, s := range settings {
+			existing[s.key] = true
+		}
+		for _, s := range settings {
+			if !strings.HasPrefix(s.key, base+".") {
+				continue
+			}
+			merged := s
+			merged.key = a.path + strings.TrimPrefix(s.key, base)
+			if a.path == "" {
+				merged.key = strings.TrimPrefix(s.key, base+".")
+			}
+			if !existing[merged.key] {
+				settings = append(settings, merged)
+			}
+		}
+	}
+	return settings
+}
+
+// parsePython reads module level assignments of string and number literals, and the contents of dictionaries
+// assigned to names such as DATABASES, as used by Django settings
+func parsePython(content string) []setting {
+	var settings []setting
+	lines := gitrepo.SplitLines(content)
+	for i := 0; i < len(lines); i++ {
+		match := pythonAssignment.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		value := strings.TrimSpace(stripComment(match[2]))
+		if !strings.HasPrefix(value, "{") {
+			literal, ok := pythonLiteral(value)
+			settings = append(settings, setting{key: match[1], value: literal, line: i + 1, unknown: !ok})
+			continue
+		}
+
+		// read the dictionary, until its braces balance
+		var tokens []pythonToken
+		depth := 0
+		for j := i; j < len(lines); j++ {
+			for _, token := range pythonTokens.FindAllString(stripComment(lines[j]), -1) {
+				if j == i && len(tokens) == 0 && token != "{" {
+					continue
+				}
+				tokens = append(tokens, pythonToken{text: token, line: j + 1})
+				switch token {
+				case "{":
+					depth++
+				case "}":
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if depth == 0 {
+				i = j
+				break
+			}
+		}
+		settings = append(settings, pythonDict(match[1], tokens)...)
+	}
+	return settings
+}
+
+var pythonAssignment = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)\s*=\s*(.+)$`)
+var pythonTokens = regexp.MustCompile(`'[^']*'|"[^"]*"|[{}:,]|[^\s{}:,'"]+`)
+
+type pythonToken struct {
+	text string
+	line int
+}
+
+func pythonDict(name string, tokens []pythonToken) []setting {
+	var settings []setting
+	path := []string{name}
+	for i := 0; i < len(tokens); i++ {
+		switch {
+		case tokens[i].text == "}":
+			if len(path) > 1 {
+				path = path[:len(path)-1]
+			}
+		case i+2 < len(tokens) && tokens[i+1].text == ":":
+			key, ok := pythonLiteral(tokens[i].text)
+			if !ok {
+				continue
+			}
+			if tokens[i+2].text == "{" {
+				path = append(path, key)
+				i += 2
+				continue
+			}
+			value, known := pythonLiteral(tokens[i+2].text)
+			// anything more than a single literal, such as os.environ.get(...), is an expression
+			if i+3 < len(tokens) && tokens[i+3].text != "," && tokens[i+3].text != "}" {
+				known = false
+			}
+			settings = append(settings, setting{key: strings.Join(append(path, key), "."), value: value, line: tokens[i].line, unknown: !known})
+			i += 2
+		}
+	}
+	return settings
+}
+
+func pythonLiteral(s string) (string, bool) {
+	if value, quoted := unquote(s); quoted {
+		return value, true
+	}
+	if _, err := strconv.Atoi(s); err == nil {
+		return s, true
+	}
+	return "", false
+}
+
+// connectionStrings finds connection strings in the attributes of web.config and the values of appsettings.json
+func connectionStrings(content string, json bool) []setting {
+	var settings []setting
+	for i, line := range gitrepo.SplitLines(content) {
+		if json {
+			for _, match := range jsonString.FindAllStringSubmatch(line, -1) {
+				settings = append(settings, setting{key: match[1], value: strings.ReplaceAll(match[2], `\\`, `\`), line: i + 1})
+			}
+			continue
+		}
+		for _, tag := range xmlAddTag.FindAllString(line, -1) {
+			attributes := make(map[string]string)
+			for _, match := range xmlAttribute.FindAllStringSubmatch(tag, -1) {
+				attributes[strings.ToLower(match[1])] = html.UnescapeString(match[2])
+			}
+			if value, ok := attributes["connectionstring"]; ok {
+				settings = append(settings, setting{key: attributes["providername"], value: value, line: i + 1})
+			}
+		}
+	}
+	return settings
+}
+
+var jsonString = regexp.MustCompile(`"([^"\\]+)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+var xmlAddTag = regexp.MustCompile(`(?i)<add\b[^>]*>`)
+var xmlAttribute = regexp.MustCompile(`([A-Za-z]+)\s*=\s*"([^"]*)"`)
+
+// unquote removes matching quotes around a value, reporting whether there were any
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return s, false
+}
+
+// stripComment removes a trailing # comment which is not within quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
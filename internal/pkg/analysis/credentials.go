@@ -0,0 +1,421 @@
+package analysis
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+const kindCredential = "credential"
+
+// setting names, compared case insensitively, which hold each part of a credential
+var (
+	passwordNames = []string{"password", "passwd", "pass", "pwd"}
+	usernameNames = []string{"username", "user", "login", "uid"}
+	hostNames     = []string{"host", "hostname", "server", "address", "addr"}
+	portNames     = []string{"port"}
+	databaseNames = []string{"database", "dbname", "db", "name"}
+	urlNames      = []string{"url", "uri", "dsn", "jdbc-url", "jdbcurl", "connection-string", "connectionstring"}
+	serviceNames  = []string{"connection", "driver", "adapter", "engine", "scheme", "type"}
+)
+
+// nullValues mean that no password is set
+var nullValues = []string{"null", "none", "nil", "~"}
+
+// templated values are filled in at runtime or are left for the user to fill in, so say nothing about the real credential
+var templated = []string{"${", "{{", "<%", "%(", "env(", "getenv", "process.env", "_here", "changeme", "your_"}
+
+type credentialMatch struct {
+	service  string
+	host     string
+	port     string
+	database string
+	username string
+	secret   string
+	format   string
+}
+
+func (c credentialMatch) key() string {
+	return strings.Join([]string{c.service, c.host, c.port, c.database, c.username, c.secret}, "\x00")
+}
+
+// detectCredentials extracts connection details from the configuration files of common frameworks, by file name
+func detectCredentials(name string, content []byte) []hit {
+
+	if gitrepo.IsBinary(content) {
+		return nil
+	}
+	text := string(content)
+	lower := strings.ToLower(name)
+	ext := path.Ext(lower)
+
+	var found []located
+	switch {
+	case ext == ".php" || strings.Contains(lower, "wp-config"):
+		found = groupSettings(parsePHPDefines(text), "_", "wordpress", "mysql")
+	case lower == ".env" || strings.HasPrefix(lower, ".env.") || ext == ".env":
+		found = groupSettings(parseEnv(text), "_", "dotenv", "")
+	case ext == ".py":
+		settings := parsePython(text)
+		found = append(groupSettings(settings, ".", "django", ""), groupSettings(settings, "_", "django", "")...)
+	case ext == ".properties":
+		found = groupSettings(parseProperties(text), ".", "properties", "")
+	case ext == ".yml" || ext == ".yaml":
+		format := "yaml"
+		if strings.HasPrefix(lower, "database") {
+			format = "rails"
+		}
+		found = groupSettings(parseYAML(text), ".", format, "")
+	case lower == "web.config" || (strings.HasSuffix(lower, ".config") && strings.Contains(text, "connectionString")):
+		found = parseConnectionStrings(connectionStrings(text, false), "web.config")
+	case strings.HasPrefix(lower, "appsettings") && ext == ".json":
+		found = parseConnectionStrings(connectionStrings(text, true), "appsettings.json")
+	}
+
+	var hits []hit
+	seen := make(map[string]bool)
+	for _, l := range found {
+		key := l.credential.key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		hits = append(hits, hit{kind: kindCredential, key: key, line: l.line, value: l.credential})
+	}
+	return hits
+}
+
+type located struct {
+	credential credentialMatch
+	line       int
+}
+
+// groupSettings gathers settings which share a prefix with a password, such as DB_HOST, DB_USERNAME and DB_PASSWORD,
+// into credentials. URLs holding credentials, such as DATABASE_URL, are credentials by themselves.
+func groupSettings(settings []setting, sep string, format string, defaultService string) []located {
+
+	byKey := make(map[string]setting)
+	for _, s := range settings {
+		byKey[strings.ToLower(s.key)] = s
+	}
+
+	// lookup finds a setting alongside the password, or failing that one level up, e.g. EMAIL_PORT for EMAIL_HOST_PASSWORD
+	lookup := func(prefix string, names []string) (setting, bool) {
+		prefixes := []string{prefix}
+		if i := strings.LastIndex(prefix, sep); i > 0 {
+			prefixes = append(prefixes, prefix[:i])
+		}
+		for _, p := range prefixes {
+			for _, name := range names {
+				if s, ok := byKey[p+sep+name]; ok {
+					return s, true
+				}
+			}
+		}
+		return setting{}, false
+	}
+
+	var found []located
+	for _, s := range settings {
+		key := strings.ToLower(s.key)
+		prefix, last := "", key
+		if i := strings.LastIndex(key, sep); i >= 0 {
+			prefix, last = key[:i], key[i+len(sep):]
+		}
+
+		if contains(urlNames, last) {
+			if c, ok := parseEndpoint(s.value); ok && c.secret != "" && !isTemplated(c.secret) {
+				if c.username == "" {
+					if user, ok := lookup(prefix, usernameNames); ok && !isUnknown(user) {
+						c.username = user.value
+					}
+				}
+				c.format = format
+				found = append(found, located{credential: c, line: s.line})
+			}
+			continue
+		}
+		if prefix == "" || !contains(passwordNames, last) || isUnknown(s) {
+			continue
+		}
+
+		c := credentialMatch{secret: s.value, format: format}
+		if contains(nullValues, strings.ToLower(c.secret)) {
+			c.secret = ""
+		}
+		if u, ok := lookup(prefix, urlNames); ok && !isUnknown(u) {
+			if endpoint, ok := parseEndpoint(u.value); ok {
+				c.service, c.host, c.port, c.database, c.username = endpoint.service, endpoint.host, endpoint.port, endpoint.database, endpoint.username
+			}
+		}
+		set := func(field *string, names []string) {
+			if s, ok := lookup(prefix, names); ok && !isUnknown(s) && s.value != "" {
+				*field = s.value
+			}
+		}
+		set(&c.username, usernameNames)
+		set(&c.host, hostNames)
+		set(&c.port, portNames)
+		set(&c.database, databaseNames)
+		if c.host == "" && strings.HasSuffix(prefix, sep+"host") {
+			if s, ok := byKey[prefix]; ok && !isUnknown(s) {
+				c.host = s.value
+			}
+		}
+		if s, ok := lookup(prefix, serviceNames); ok && !isUnknown(s) && s.value != "" {
+			c.service = normaliseService(s.value)
+		}
+		if c.service == "" {
+			c.service = defaultService
+		}
+		if c.service == "" {
+			c.service = prefixService(prefix, sep)
+		}
+
+		// an empty password is worth reporting, as long as there is a user it applies to
+		if c.secret == "" && c.username == "" {
+			continue
+		}
+		found = append(found, located{credential: splitHostPort(c), line: s.line})
+	}
+	return found
+}
+
+// parseConnectionStrings reads ADO.NET style connection strings, such as "Server=db;Database=app;User Id=sa;Password=x"
+func parseConnectionStrings(settings []setting, format string) []located {
+	var found []located
+	for _, s := range settings {
+		if c, ok := parseEndpoint(s.value); ok && c.secret != "" {
+			c.format = format
+			found = append(found, located{credential: c, line: s.line})
+			continue
+		}
+
+		c := credentialMatch{format: format}
+		var hasPassword bool
+		var keys []string
+		for _, part := range strings.Split(s.value, ";") {
+			eq := strings.Index(part, "=")
+			if eq < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(part[:eq]))
+			value := strings.TrimSpace(part[eq+1:])
+			keys = append(keys, key)
+			switch key {
+			case "server", "data source", "datasource", "host", "address", "addr", "network address":
+				c.host = strings.TrimPrefix(value, "tcp:")
+				if i := strings.Index(c.host, ","); i >= 0 {
+					c.host, c.port = c.host[:i], c.host[i+1:]
+				}
+			case "port":
+				c.port = value
+			case "database", "initial catalog":
+				c.database = value
+			case "user id", "userid", "uid", "user", "username", "user name":
+				c.username = value
+			case "password", "pwd":
+				c.secret = value
+				hasPassword = true
+			}
+		}
+		if !hasPassword || isTemplated(c.secret) || (c.secret == "" && c.username == "") {
+			continue
+		}
+
+		provider := strings.ToLower(s.key)
+		switch {
+		case strings.Contains(provider, "mysql"):
+			c.service = "mysql"
+		case strings.Contains(provider, "npgsql"), strings.Contains(provider, "postgres"):
+			c.service = "postgresql"
+		case strings.Contains(provider, "oracle"):
+			c.service = "oracle"
+		case strings.Contains(provider, "sqlite"):
+			c.service = "sqlite"
+		case contains(keys, "host") && contains(keys, "username"):
+			// Npgsql connection strings are the usual form using Host and Username
+			c.service = "postgresql"
+		default:
+			c.service = "mssql"
+		}
+		found = append(found, located{credential: splitHostPort(c), line: s.line})
+	}
+	return found
+}
+
+// parseEndpoint reads credentials from a URL or JDBC connection string, e.g. jdbc:mysql://db:3306/app?user=app&password=x
+func parseEndpoint(raw string) (credentialMatch, bool) {
+
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "jdbc:")
+	var c credentialMatch
+
+	// Oracle thin connections look like oracle:thin:user/password@host:1521:sid or oracle:thin:@//host:1521/service
+	if strings.HasPrefix(raw, "oracle:") {
+		at := strings.LastIndex(raw, "@")
+		if at < 0 {
+			return c, false
+		}
+		c.service = "oracle"
+		if login := raw[strings.LastIndex(raw[:at], ":")+1 : at]; strings.Contains(login, "/") {
+			c.username, c.secret = strings.SplitN(login, "/", 2)[0], strings.SplitN(login, "/", 2)[1]
+		}
+		address := strings.TrimPrefix(raw[at+1:], "//")
+		if i := strings.Index(address, "/"); i >= 0 {
+			address, c.database = address[:i], address[i+1:]
+		}
+		parts := strings.Split(address, ":")
+		c.host = parts[0]
+		if len(parts) > 1 {
+			c.port = parts[1]
+		}
+		if len(parts) > 2 {
+			c.database = parts[2]
+		}
+		return c, c.host != ""
+	}
+
+	// SQL Server JDBC URLs put properties after semicolons, e.g. sqlserver://db:1433;databaseName=app;user=sa;password=x
+	var properties []string
+	if i := strings.Index(raw, ";"); i >= 0 {
+		raw, properties = raw[:i], strings.Split(raw[i+1:], ";")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return c, false
+	}
+	c.service = normaliseService(u.Scheme)
+	c.host = u.Hostname()
+	c.port = u.Port()
+	c.database = strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.secret, _ = u.User.Password()
+	}
+
+	query := u.Query()
+	for _, property := range properties {
+		if eq := strings.Index(property, "="); eq > 0 {
+			query.Set(property[:eq], property[eq+1:])
+		}
+	}
+	for key, values := range query {
+		switch strings.ToLower(key) {
+		case "user", "username":
+			c.username = values[0]
+		case "password":
+			c.secret = values[0]
+		case "databasename", "database":
+			c.database = values[0]
+		}
+	}
+	return c, true
+}
+
+// normaliseService gives the same name to the different spellings of a service, e.g. mysql2, pdo_mysql and MySql.Data
+func normaliseService(s string) string {
+	s = strings.ToLower(s)
+	s = s[strings.LastIndex(s, ".")+1:]
+	switch {
+	case strings.Contains(s, "mysql"), s == "mariadb":
+		return "mysql"
+	case strings.Contains(s, "postgres"), strings.Contains(s, "pgsql"), s == "psql":
+		return "postgresql"
+	case strings.Contains(s, "sqlserver"), strings.Contains(s, "sqlsrv"), s == "mssql":
+		return "mssql"
+	case strings.Contains(s, "sqlite"):
+		return "sqlite"
+	case strings.HasPrefix(s, "mongodb"):
+		return "mongodb"
+	case s == "rediss":
+		return "redis"
+	case s == "amqps":
+		return "amqp"
+	case s == "smtps":
+		return "smtp"
+	}
+	return s
+}
+
+// prefixService names the service from the prefix of its settings, e.g. "redis" for REDIS_PASSWORD
+func prefixService(prefix string, sep string) string {
+	name := prefix
+	if i := strings.LastIndex(prefix, sep); i >= 0 {
+		name = prefix[i+len(sep):]
+	}
+	for _, part := range strings.Split(prefix, sep) {
+		switch part {
+		case "mail", "email", "smtp":
+			return "smtp"
+		}
+	}
+	switch name {
+	case "db", "database", "datasource", "sql":
+		return "database"
+	}
+	return name
+}
+
+// splitHostPort separates a port given as part of the host, e.g. localhost:3306
+func splitHostPort(c credentialMatch) credentialMatch {
+	if c.port != "" {
+		return c
+	}
+	if host, port, err := net.SplitHostPort(c.host); err == nil {
+		c.host, c.port = host, port
+	}
+	return c
+}
+
+func isUnknown(s setting) bool {
+	return s.unknown || isTemplated(s.value)
+}
+
+func isTemplated(value string) bool {
+	lower := strings.ToLower(value)
+	for _, t := range templated {
+		if strings.Contains(lower, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *history) credentials() []exposed.Credential {
+	var credentials []exposed.Credential
+	for _, t := range h.tracked(kindCredential) {
+		match := t.hit.value.(credentialMatch)
+		credentials = append(credentials, exposed.Credential{
+			Service:    match.service,
+			Host:       match.host,
+			Port:       match.port,
+			Database:   match.database,
+			Username:   match.username,
+			Secret:     match.secret,
+			Format:     match.format,
+			Occurrence: h.occurrence(t),
+		})
+	}
+	sort.SliceStable(credentials, func(i, j int) bool {
+		if credentials[i].Path != credentials[j].Path {
+			return credentials[i].Path < credentials[j].Path
+		}
+		return credentials[i].Line < credentials[j].Line
+	})
+	return credentials
+}
@@ -0,0 +1,411 @@
+package analysis
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+const kindEndpoint = "endpoint"
+
+// endpointKinds are listed in this order, with the infrastructure most useful to an assessment first
+var endpointKinds = []string{"database", "queue", "bucket", "hostname", "ip", "route", "url", "third-party"}
+
+type endpointMatch struct {
+	kind   string
+	value  string
+	detail string
+}
+
+// maxLineLength skips lines of minified code, which are mostly noise
+const maxLineLength = 4096
+
+var (
+	databaseSchemes = []string{"mysql", "mariadb", "postgres", "postgresql", "pgsql", "mongodb", "mongodb+srv", "redis", "rediss", "sqlserver", "mssql", "oracle", "cassandra", "couchdb", "memcached", "neo4j", "bolt", "clickhouse"}
+	queueSchemes    = []string{"amqp", "amqps", "nats", "kafka", "stomp", "mqtt", "mqtts", "sb", "sqs", "beanstalk"}
+	bucketSchemes   = []string{"s3", "s3a", "s3n", "gs"}
+	urlSchemes      = []string{"http", "https", "ws", "wss", "ftp", "ftps", "sftp", "ssh", "git", "ldap", "ldaps", "smtp", "smtps"}
+)
+
+// noiseHosts appear in schemas, licences and documentation rather than referring to anything the target runs.
+// Subdomains of these are also ignored.
+var noiseHosts = []string{
+	"localhost", "example.com", "example.org", "example.net",
+	"w3.org", "xmlsoap.org", "schemas.microsoft.com", "schemas.android.com", "schemas.openxmlformats.org",
+	"xmlns.com", "purl.org", "json-schema.org", "xml.org", "relaxng.org", "yaml.org", "ietf.org",
+	"apache.org", "opensource.org", "gnu.org", "fsf.org", "creativecommons.org", "mozilla.org", "spdx.org", "semver.org",
+}
+
+// cloudHosts recognise the endpoints of cloud services by hostname. The first submatch, if any, is the value.
+var cloudHosts = []struct {
+	pattern *regexp.Regexp
+	kind    string
+	detail  string
+}{
+	{regexp.MustCompile(`^([a-z0-9][a-z0-9.-]+)\.s3[.-](?:[a-z0-9-]+\.)?amazonaws\.com$`), "bucket", "AWS S3"},
+	{regexp.MustCompile(`^([a-z0-9-]+)\.blob\.core\.windows\.net$`), "bucket", "Azure Blob Storage"},
+	{regexp.MustCompile(`^([a-z0-9-]+)\.(?:[a-z0-9-]+\.)?digitaloceanspaces\.com$`), "bucket", "DigitalOcean Spaces"},
+	{regexp.MustCompile(`\.rds\.amazonaws\.com$`), "database", "AWS RDS"},
+	{regexp.MustCompile(`\.cache\.amazonaws\.com$`), "database", "AWS ElastiCache"},
+	{regexp.MustCompile(`\.es\.amazonaws\.com$`), "database", "AWS OpenSearch"},
+	{regexp.MustCompile(`\.database\.windows\.net$`), "database", "Azure SQL"},
+	{regexp.MustCompile(`\.documents\.azure\.com$`), "database", "Azure Cosmos DB"},
+	{regexp.MustCompile(`\.redis\.cache\.windows\.net$`), "database", "Azure Cache for Redis"},
+	{regexp.MustCompile(`\.mongodb\.net$`), "database", "MongoDB Atlas"},
+	{regexp.MustCompile(`\.servicebus\.windows\.net$`), "queue", "Azure Service Bus"},
+}
+
+// internalDomains are top level domains used for private networks
+var internalDomains = []string{"internal", "local", "lan", "corp", "intranet", "intra", "localdomain", "private", "home.arpa"}
+
+// thirdParties name the services which domains belong to, including subdomains
+var thirdParties = []struct {
+	domain  string
+	service string
+}{
+	{"amazonaws.com", "AWS"}, {"cloudfront.net", "AWS CloudFront"}, {"googleapis.com", "Google APIs"},
+	{"firebaseio.com", "Firebase"}, {"firebaseapp.com", "Firebase"}, {"windows.net", "Azure"}, {"azure.com", "Azure"},
+	{"azurewebsites.net", "Azure"}, {"herokuapp.com", "Heroku"}, {"digitaloceanspaces.com", "DigitalOcean"},
+	{"stripe.com", "Stripe"}, {"paypal.com", "PayPal"}, {"braintreegateway.com", "Braintree"},
+	{"slack.com", "Slack"}, {"twilio.com", "Twilio"}, {"sendgrid.net", "SendGrid"}, {"sendgrid.com", "SendGrid"},
+	{"mailgun.net", "Mailgun"}, {"mailgun.org", "Mailgun"}, {"mailchimp.com", "Mailchimp"}, {"mandrillapp.com", "Mandrill"},
+	{"postmarkapp.com", "Postmark"}, {"github.com", "GitHub"}, {"gitlab.com", "GitLab"}, {"bitbucket.org", "Bitbucket"},
+	{"atlassian.net", "Atlassian"}, {"sentry.io", "Sentry"}, {"datadoghq.com", "Datadog"}, {"newrelic.com", "New Relic"},
+	{"algolia.net", "Algolia"}, {"auth0.com", "Auth0"}, {"okta.com", "Okta"}, {"segment.io", "Segment"},
+	{"segment.com", "Segment"}, {"intercom.io", "Intercom"}, {"zendesk.com", "Zendesk"}, {"hubspot.com", "HubSpot"},
+	{"salesforce.com", "Salesforce"}, {"myshopify.com", "Shopify"}, {"cloudinary.com", "Cloudinary"},
+	{"pusher.com", "Pusher"}, {"mixpanel.com", "Mixpanel"}, {"cloudflare.com", "Cloudflare"}, {"mongodb.net", "MongoDB Atlas"},
+}
+
+var (
+	urlPattern = regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]{1,15})://[^\s'"<>(){}\[\]\\,;|^` + "`" + `]+`)
+	ipPattern  = regexp.MustCompile(`(?:^|[^\d.])((?:\d{1,3}\.){3}\d{1,3})(?:$|[^\d.])`)
+	// hostPattern finds hostnames outside of URLs, which are only recognised with domains known to be of interest
+	hostPattern     = regexp.MustCompile(`(?i)\b((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+(?:internal|corp|lan|intranet|intra|localdomain|amazonaws\.com|windows\.net|azure\.com|mongodb\.net|digitaloceanspaces\.com))\b`)
+	validHost       = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9.-]*[a-z0-9])?$`)
+	bucketSetting   = regexp.MustCompile(`(?i)[\w.-]*bucket(?:[_-]?name)?["']?\s*(?:=>|:=|=|:)\s*["']([a-z0-9][a-z0-9.-]{1,61}[a-z0-9])["']`)
+	bucketVariable  = regexp.MustCompile(`^\s*(?:export\s+)?\w*BUCKET\w*\s*=\s*([a-z0-9][a-z0-9.-]{1,61}[a-z0-9])\s*$`)
+	kafkaSetting    = regexp.MustCompile(`(?i)(?:bootstrap[._-]servers|kafka[._-]?(?:brokers|bootstrap[._-]servers|servers|hosts))["']?\s*[:=]\s*["']?([\w.:,-]+)`)
+	sqsQueuePath    = regexp.MustCompile(`^/\d{12}/[\w-]+$`)
+	pathPlaceholder = regexp.MustCompile(`[{$%<]`)
+)
+
+// routeRule finds the routes of a web framework. The method submatch is empty for routes which take any method.
+type routeRule struct {
+	framework string
+	pattern   *regexp.Regexp
+	method    int
+	path      int
+	// applies decides whether a file is in the language of the framework
+	applies func(name string) bool
+}
+
+func extension(extensions ...string) func(string) bool {
+	return func(name string) bool {
+		return contains(extensions, path.Ext(name))
+	}
+}
+
+var routeRules = []routeRule{
+	{"express", regexp.MustCompile(`\b(?:app|router|server|api|route)\.(get|post|put|patch|delete|all|options|head)\(\s*['"` + "`" + `](/[^'"` + "`" + `]*)`), 1, 2, extension(".js", ".mjs", ".cjs", ".ts")},
+	{"flask", regexp.MustCompile(`@\w+\.(route|get|post|put|patch|delete)\(\s*['"](/[^'"]*)['"]`), 1, 2, extension(".py")},
+	{"django", regexp.MustCompile(`\b(?:re_path|path|url)\(\s*r?['"]\^?([^'"]*?)\$?['"]`), 0, 1, func(name string) bool { return name == "urls.py" }},
+	{"laravel", regexp.MustCompile(`Route::(get|post|put|patch|delete|any|match|resource|apiResource)\(\s*['"]([^'"]+)['"]`), 1, 2, extension(".php")},
+	{"rails", regexp.MustCompile(`^\s*(get|post|put|patch|delete|match|resources|resource)\s+['":]([^'",\s]+)`), 1, 2, func(name string) bool { return name == "routes.rb" }},
+	{"spring", regexp.MustCompile(`@(Get|Post|Put|Patch|Delete|Request)Mapping\(\s*(?:(?:value|path)\s*=\s*)?\{?\s*"([^"]*)"`), 1, 2, extension(".java", ".kt")},
+	{"go", regexp.MustCompile(`\.(HandleFunc|Handle|GET|POST|PUT|PATCH|DELETE|Get|Post|Put|Patch|Delete)\(\s*"(/[^"]*)"`), 1, 2, extension(".go")},
+	{"asp.net", regexp.MustCompile(`\[(?:Http(Get|Post|Put|Patch|Delete)|Route)\(\s*"([^"]*)"`), 1, 2, extension(".cs")},
+}
+
+// detectEndpoints finds the URLs, hosts and routes referred to by source and configuration
+func detectEndpoints(name string, content []byte) []hit {
+
+	if gitrepo.IsBinary(content) || isGenerated(name) {
+		return nil
+	}
+
+	var rules []routeRule
+	for _, rule := range routeRules {
+		if rule.applies(strings.ToLower(name)) {
+			rules = append(rules, rule)
+		}
+	}
+
+	var hits []hit
+	seen := make(map[string]bool)
+	for i, line := range gitrepo.SplitLines(string(content)) {
+		if len(line) > maxLineLength {
+			continue
+		}
+		for _, match := range lineEndpoints(line, rules) {
+			key := match.kind + "\x00" + match.value
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			hits = append(hits, hit{kind: kindEndpoint, key: key, line: i + 1, value: match})
+		}
+	}
+	return hits
+}
+
+func lineEndpoints(line string, rules []routeRule) []endpointMatch {
+
+	var found []endpointMatch
+
+	rest := line
+	for _, raw := range urlPattern.FindAllString(line, -1) {
+		found = append(found, urlEndpoints(strings.TrimRight(raw, ".:!?*"))...)
+		rest = strings.Replace(rest, raw, " ", 1)
+	}
+
+	for _, host := range hostPattern.FindAllString(rest, -1) {
+		found = append(found, hostEndpoints(strings.ToLower(host))...)
+	}
+
+	// version numbers look like IP addresses
+	if !strings.Contains(strings.ToLower(rest), "version") {
+		for _, match := range ipPattern.FindAllStringSubmatch(rest, -1) {
+			found = append(found, hostEndpoints(match[1])...)
+		}
+	}
+
+	for _, pattern := range []*regexp.Regexp{bucketSetting, bucketVariable} {
+		for _, match := range pattern.FindAllStringSubmatch(line, -1) {
+			found = append(found, endpointMatch{kind: "bucket", value: match[1]})
+		}
+	}
+
+	for _, match := range kafkaSetting.FindAllStringSubmatch(line, -1) {
+		for _, broker := range strings.Split(match[1], ",") {
+			if broker = strings.TrimSpace(broker); validHost.MatchString(strings.ToLower(strings.Split(broker, ":")[0])) {
+				found = append(found, endpointMatch{kind: "queue", value: "kafka://" + broker, detail: "Kafka"})
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		for _, match := range rule.pattern.FindAllStringSubmatch(line, -1) {
+			found = append(found, routeEndpoint(rule, match))
+		}
+	}
+	return found
+}
+
+// urlEndpoints classifies a URL by its scheme, along with its host. Credentials and query strings are left out.
+func urlEndpoints(raw string) []endpointMatch {
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if !validHost.MatchString(host) || isNoise(host) {
+		return nil
+	}
+
+	address := host
+	if u.Port() != "" {
+		address = net.JoinHostPort(host, u.Port())
+	}
+	value := scheme + "://" + address + strings.TrimSuffix(u.EscapedPath(), "/")
+
+	var found []endpointMatch
+	switch {
+	case contains(databaseSchemes, scheme):
+		found = append(found, endpointMatch{kind: "database", value: value, detail: normaliseService(scheme)})
+	case contains(queueSchemes, scheme):
+		found = append(found, endpointMatch{kind: "queue", value: value, detail: normaliseService(scheme)})
+	case contains(bucketSchemes, scheme):
+		detail := "AWS S3"
+		if scheme == "gs" {
+			detail = "Google Cloud Storage"
+		}
+		return []endpointMatch{{kind: "bucket", value: host, detail: detail}}
+	case !contains(urlSchemes, scheme):
+		return nil
+	case strings.HasPrefix(host, "sqs.") && strings.HasSuffix(host, ".amazonaws.com") && sqsQueuePath.MatchString(u.Path):
+		found = append(found, endpointMatch{kind: "queue", value: value, detail: "AWS SQS"})
+	case host == "storage.googleapis.com" && len(strings.Split(strings.Trim(u.Path, "/"), "/")[0]) > 2:
+		found = append(found, endpointMatch{kind: "bucket", value: strings.Split(strings.Trim(u.Path, "/"), "/")[0], detail: "Google Cloud Storage"})
+	case strings.HasPrefix(host, "s3.") || strings.HasPrefix(host, "s3-"):
+		// path style S3 URLs name the bucket first
+		if bucket := strings.Split(strings.Trim(u.Path, "/"), "/")[0]; len(bucket) > 2 && strings.HasSuffix(host, ".amazonaws.com") {
+			found = append(found, endpointMatch{kind: "bucket", value: bucket, detail: "AWS S3"})
+		}
+	}
+	if contains(urlSchemes, scheme) && !pathPlaceholder.MatchString(u.Path) {
+		found = append(found, endpointMatch{kind: "url", value: value})
+	}
+	return append(found, hostEndpoints(host)...)
+}
+
+// hostEndpoints classifies a hostname or IP address
+func hostEndpoints(host string) []endpointMatch {
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip = ip.To4(); ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip[0] == 255 || ip[0] == 0 {
+			return nil
+		}
+		detail := "public"
+		switch {
+		case ip.IsLinkLocalUnicast():
+			detail = "link-local"
+		case ip[0] == 10, ip[0] == 172 && ip[1]&0xf0 == 16, ip[0] == 192 && ip[1] == 168:
+			detail = "private"
+		}
+		return []endpointMatch{{kind: "ip", value: ip.String(), detail: detail}}
+	}
+	if !validHost.MatchString(host) || isNoise(host) {
+		return nil
+	}
+
+	var found []endpointMatch
+	for _, cloud := range cloudHosts {
+		if match := cloud.pattern.FindStringSubmatch(host); match != nil {
+			value := host
+			if len(match) > 1 {
+				value = match[1]
+			}
+			found = append(found, endpointMatch{kind: cloud.kind, value: value, detail: cloud.detail})
+			break
+		}
+	}
+	for _, domain := range internalDomains {
+		if strings.HasSuffix(host, "."+domain) {
+			found = append(found, endpointMatch{kind: "hostname", value: host, detail: "internal"})
+		}
+	}
+	for _, party := range thirdParties {
+		if host == party.domain || strings.HasSuffix(host, "."+party.domain) {
+			found = append(found, endpointMatch{kind: "third-party", value: host, detail: party.service})
+			break
+		}
+	}
+	return found
+}
+
+func routeEndpoint(rule routeRule, match []string) endpointMatch {
+	route := match[rule.path]
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	method := "ANY"
+	if rule.method > 0 {
+		switch m := strings.ToUpper(match[rule.method]); m {
+		case "GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD":
+			method = m
+		}
+	}
+	return endpointMatch{kind: "route", value: method + " " + route, detail: rule.framework}
+}
+
+func isNoise(host string) bool {
+	for _, noise := range noiseHosts {
+		if host == noise || strings.HasSuffix(host, "."+noise) {
+			return true
+		}
+	}
+	return false
+}
+
+// vendoredDirs hold third-party code, which refers to the infrastructure of its authors rather than the target
+var vendoredDirs = []string{"node_modules", "vendor", "bower_components", "third_party", "site-packages"}
+
+func isVendored(p string) bool {
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if contains(vendoredDirs, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *history) endpoints() []exposed.Endpoint {
+	var endpoints []exposed.Endpoint
+	index := make(map[string]int)
+	for _, t := range h.tracked(kindEndpoint) {
+		if isVendored(t.path) {
+			continue
+		}
+		match := t.hit.value.(endpointMatch)
+		endpoints = addEndpoint(endpoints, index, match, h.occurrence(t))
+	}
+	return endpoints
+}
+
+// addEndpoint adds a location to an endpoint, adding the endpoint if it is new
+func addEndpoint(endpoints []exposed.Endpoint, index map[string]int, match endpointMatch, location exposed.Occurrence) []exposed.Endpoint {
+	key := match.kind + "\x00" + match.value
+	i, ok := index[key]
+	if !ok {
+		i = len(endpoints)
+		index[key] = i
+		endpoints = append(endpoints, exposed.Endpoint{Kind: match.kind, Value: match.value, Detail: match.detail})
+	}
+	endpoints[i].Locations = append(endpoints[i].Locations, location)
+	return endpoints
+}
+
+// credentialEndpoints adds the hosts of credentials to the inventory, as configuration often names a database
+// host in a setting of its own rather than in a URL
+func credentialEndpoints(endpoints []exposed.Endpoint, credentials []exposed.Credential) []exposed.Endpoint {
+	index := make(map[string]int)
+	for i, endpoint := range endpoints {
+		index[endpoint.Kind+"\x00"+endpoint.Value] = i
+	}
+	for _, credential := range credentials {
+		if credential.Host == "" || !validHost.MatchString(strings.ToLower(credential.Host)) {
+			continue
+		}
+		kind := "database"
+		if contains(queueSchemes, credential.Service) {
+			kind = "queue"
+		} else if !contains(databaseSchemes, credential.Service) && credential.Service != "database" {
+			continue
+		}
+		value := credential.Service + "://" + strings.ToLower(credential.Host)
+		if credential.Port != "" {
+			value += ":" + credential.Port
+		}
+		if credential.Database != "" {
+			value += "/" + credential.Database
+		}
+		detail := credential.Service
+		if detail == "database" {
+			// the kind of database is not known
+			detail = ""
+		}
+		endpoints = addEndpoint(endpoints, index, endpointMatch{kind: kind, value: value, detail: detail}, credential.Occurrence)
+	}
+	return endpoints
+}
+
+func sortEndpoints(endpoints []exposed.Endpoint) {
+	order := make(map[string]int)
+	for i, kind := range endpointKinds {
+		order[kind] = i
+	}
+	for _, endpoint := range endpoints {
+		locations := endpoint.Locations
+		sort.SliceStable(locations, func(i, j int) bool {
+			if locations[i].Path != locations[j].Path {
+				return locations[i].Path < locations[j].Path
+			}
+			return locations[i].Line < locations[j].Line
+		})
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].Kind != endpoints[j].Kind {
+			return order[endpoints[i].Kind] < order[endpoints[j].Kind]
+		}
+		return endpoints[i].Value < endpoints[j].Value
+	})
+}
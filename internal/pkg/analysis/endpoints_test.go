@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/internal/pkg/testutil"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestDetectEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "config.js",
+			content: `const api = "https://admin:pw@api.victim.test/v2/?token=abc"; // see https://www.w3.org/TR/xml/`,
+			want:    []string{"url https://api.victim.test/v2"},
+		},
+		{
+			name:    ".env",
+			content: "DATABASE_URL=postgres://app:pw@db.prod.internal:5432/app\nQUEUE=amqp://rabbit.corp:5672/\nASSETS_BUCKET=victim-assets\n",
+			want: []string{
+				"database postgres://db.prod.internal:5432/app postgresql",
+				"hostname db.prod.internal internal",
+				"queue amqp://rabbit.corp:5672 amqp",
+				"hostname rabbit.corp internal",
+				"bucket victim-assets",
+			},
+		},
+		{
+			name:    "settings.py",
+			content: "AWS_STORAGE_BUCKET_NAME = 'victim-media'\nCACHE = 'redis-prod.abc123.0001.euw1.cache.amazonaws.com'\nINTERNAL_IP = '10.0.3.7'\nVERSION = '1.2.3.4'\n",
+			want: []string{
+				"bucket victim-media",
+				"database redis-prod.abc123.0001.euw1.cache.amazonaws.com AWS ElastiCache",
+				"third-party redis-prod.abc123.0001.euw1.cache.amazonaws.com AWS",
+				"ip 10.0.3.7 private",
+			},
+		},
+		{
+			name:    "upload.js",
+			content: `fetch("https://victim-uploads.s3.eu-west-1.amazonaws.com/" + key); sqs.send("https://sqs.eu-west-1.amazonaws.com/123456789012/orders");`,
+			want: []string{
+				"url https://victim-uploads.s3.eu-west-1.amazonaws.com",
+				"bucket victim-uploads AWS S3",
+				"third-party victim-uploads.s3.eu-west-1.amazonaws.com AWS",
+				"queue https://sqs.eu-west-1.amazonaws.com/123456789012/orders AWS SQS",
+				"url https://sqs.eu-west-1.amazonaws.com/123456789012/orders",
+				"third-party sqs.eu-west-1.amazonaws.com AWS",
+			},
+		},
+		{
+			name:    "application.properties",
+			content: "spring.kafka.bootstrap-servers=kafka1.internal:9092,kafka2.internal:9092\n",
+			want: []string{
+				"hostname kafka1.internal internal",
+				"hostname kafka2.internal internal",
+				"queue kafka://kafka1.internal:9092 Kafka",
+				"queue kafka://kafka2.internal:9092 Kafka",
+			},
+		},
+		{
+			name:    "server.js",
+			content: "app.post('/api/login', login);\nrouter.get(`/admin/users/:id`, show);\n",
+			want:    []string{"route POST /api/login express", "route GET /admin/users/:id express"},
+		},
+		{
+			name:    "urls.py",
+			content: "urlpatterns = [path('admin/', admin.site.urls), url(r'^api/v1/$', api)]\n",
+			want:    []string{"route ANY /admin/ django", "route ANY /api/v1/ django"},
+		},
+		{
+			name:    "UserController.java",
+			content: "@GetMapping(\"/users/{id}\")\n@RequestMapping(value = \"/internal/health\")\n",
+			want:    []string{"route GET /users/{id} spring", "route ANY /internal/health spring"},
+		},
+		{
+			name:    "web.php",
+			content: "Route::get('dashboard', 'DashboardController@index');\n",
+			want:    []string{"route GET /dashboard laravel"},
+		},
+		{
+			name:    "package-lock.json",
+			content: `"resolved": "https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz"`,
+		},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, found := range detectEndpoints(test.name, []byte(test.content)) {
+			match := found.value.(endpointMatch)
+			got = append(got, strings.TrimSpace(match.kind+" "+match.value+" "+match.detail))
+		}
+		assert.Equal(t, got, test.want, test.name)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_endpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "src/a.js", "fetch('https://api.victim.test/orders');\n")
+	testutil.Write(t, dir, "src/b.js", "\nfetch('https://api.victim.test/orders');\n")
+	testutil.Write(t, dir, "node_modules/lib/index.js", "fetch('https://cdn.lib.test/');\n")
+	testutil.Write(t, dir, ".env", "DB_HOST=mysql.victim.test\nDB_USERNAME=app\nDB_PASSWORD=hunter2\nDB_DATABASE=shop\n")
+	testutil.Commit(t, dir, "initial commit")
+
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	result, err := Scan(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, endpoint := range result.Endpoints {
+		values = append(values, endpoint.Kind+" "+endpoint.Value)
+	}
+	assert.Equal(t, values, []string{"database database://mysql.victim.test/shop", "url https://api.victim.test/orders"})
+
+	locations := result.Endpoints[1].Locations
+	assert.Equal(t, len(locations), 2)
+	assert.Equal(t, locations[0].Path, "src/a.js")
+	assert.Equal(t, locations[1].Path, "src/b.js")
+	assert.Equal(t, locations[1].Line, 2)
+	assert.Equal(t, result.Endpoints[0].Locations[0].Path, ".env")
+}
@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+)
+
+// readKeys describes every private key and certificate in a PEM file, or in an OpenSSH or DER encoded one
+func readKeys(content []byte) []exposed.Key {
+
+	var keys []exposed.Key
+	rest := content
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if key, ok := readPEMBlock(block); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+
+	if cert, err := x509.ParseCertificate(content); err == nil {
+		return []exposed.Key{readCertificate(cert)}
+	}
+	return nil
+}
+
+func readPEMBlock(block *pem.Block) (exposed.Key, bool) {
+
+	key := exposed.Key{Type: "private key"}
+
+	// keys encrypted with a passphrase using the legacy OpenSSL format say so in their headers
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		key.Encrypted = true
+		key.Algorithm = strings.TrimSuffix(block.Type, " PRIVATE KEY")
+		return key, true
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return exposed.Key{Type: "certificate"}, true
+		}
+		return readCertificate(cert), true
+	case "RSA PRIVATE KEY":
+		if private, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			describePublicKey(&key, private.Public())
+		} else {
+			key.Algorithm = "RSA"
+		}
+	case "EC PRIVATE KEY":
+		if private, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			describePublicKey(&key, private.Public())
+		} else {
+			key.Algorithm = "ECDSA"
+		}
+	case "PRIVATE KEY":
+		if private, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			if signer, ok := private.(crypto.Signer); ok {
+				describePublicKey(&key, signer.Public())
+			}
+		}
+	case "ENCRYPTED PRIVATE KEY":
+		key.Encrypted = true
+	case "OPENSSH PRIVATE KEY":
+		readOpenSSHKey(&key, block.Bytes)
+	case "DSA PRIVATE KEY":
+		key.Algorithm = "DSA"
+	case "PGP PRIVATE KEY BLOCK":
+		key.Algorithm = "PGP"
+	default:
+		return key, false
+	}
+	return key, true
+}
+
+func readCertificate(cert *x509.Certificate) exposed.Key {
+	key := exposed.Key{
+		Type:      "certificate",
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		DNSNames:  cert.DNSNames,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+	describePublicKey(&key, cert.PublicKey)
+	// certificates are identified by their own fingerprint rather than that of their key
+	key.Fingerprint = hexFingerprint(cert.Raw)
+	return key
+}
+
+// describePublicKey sets the algorithm, size and fingerprint of a key. Fingerprints are those ssh-keygen would
+// give, so that keys can be matched against authorized_keys files and the deploy keys of hosting services.
+func describePublicKey(key *exposed.Key, public crypto.PublicKey) {
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = "RSA"
+		key.Bits = public.N.BitLen()
+		key.Fingerprint = sshFingerprint(sshString("ssh-rsa"), sshInt(big.NewInt(int64(public.E))), sshInt(public.N))
+	case *ecdsa.PublicKey:
+		key.Algorithm = "ECDSA"
+		key.Bits = public.Curve.Params().BitSize
+		curve := fmt.Sprintf("nistp%d", key.Bits)
+		key.Fingerprint = sshFingerprint(sshString("ecdsa-sha2-"+curve), sshString(curve), sshBytes(elliptic.Marshal(public.Curve, public.X, public.Y)))
+	case ed25519.PublicKey:
+		key.Algorithm = "Ed25519"
+		key.Bits = 256
+		key.Fingerprint = sshFingerprint(sshString("ssh-ed25519"), sshBytes(public))
+	}
+}
+
+// readOpenSSHKey reads the public half of an OpenSSH private key, which is stored in the clear even when the
+// private half is encrypted
+func readOpenSSHKey(key *exposed.Key, data []byte) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return
+	}
+	data = data[len(magic):]
+	cipher, data := readSSHString(data)
+	_, data = readSSHString(data) // kdf name
+	_, data = readSSHString(data) // kdf options
+	if len(data) < 4 {
+		return
+	}
+	public, _ := readSSHString(data[4:])
+	key.Encrypted = len(cipher) > 0 && string(cipher) != "none"
+	if len(public) == 0 {
+		return
+	}
+	key.Fingerprint = sshFingerprint(public)
+
+	algorithm, rest := readSSHString(public)
+	switch name := string(algorithm); {
+	case name == "ssh-rsa":
+		_, rest = readSSHString(rest) // exponent
+		n, _ := readSSHString(rest)
+		key.Algorithm = "RSA"
+		key.Bits = new(big.Int).SetBytes(n).BitLen()
+	case name == "ssh-ed25519":
+		key.Algorithm = "Ed25519"
+		key.Bits = 256
+	case strings.HasPrefix(name, "ecdsa-sha2-nistp"):
+		key.Algorithm = "ECDSA"
+		_, _ = fmt.Sscanf(strings.TrimPrefix(name, "ecdsa-sha2-nistp"), "%d", &key.Bits)
+	case name == "ssh-dss":
+		key.Algorithm = "DSA"
+	default:
+		key.Algorithm = name
+	}
+}
+
+func readSSHString(data []byte) ([]byte, []byte) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	length := binary.BigEndian.Uint32(data)
+	if uint64(length) > uint64(len(data)-4) {
+		return nil, nil
+	}
+	return data[4 : 4+length], data[4+length:]
+}
+
+func sshBytes(b []byte) []byte {
+	encoded := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(encoded, uint32(len(b)))
+	return append(encoded, b...)
+}
+
+func sshString(s string) []byte {
+	return sshBytes([]byte(s))
+}
+
+// sshInt encodes a positive integer as an SSH mpint, which has a leading zero byte if the top bit is set
+func sshInt(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return sshBytes(b)
+}
+
+func sshFingerprint(parts ...[]byte) string {
+	sum := sha256.Sum256(bytes.Join(parts, nil))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+func hexFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
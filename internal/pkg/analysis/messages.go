@@ -0,0 +1,289 @@
+package analysis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// mentionKinds are listed in this order
+var mentionKinds = []string{"ticket", "codename", "customer", "host", "url", "deployment"}
+
+// maxDeploymentNote is the longest deployment note kept, as the whole line is the note
+const maxDeploymentNote = 120
+
+var (
+	ticketPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9]{1,9})-\d{1,6}\b`)
+	issuePattern  = regexp.MustCompile(`(?:^|[\s(\[,:])((?:[\w.-]+/[\w.-]+)?#\d{1,6})\b`)
+	// codenamePattern finds names given to projects, such as "Project Phoenix" or "codename: Atlas"
+	codenamePattern = regexp.MustCompile(`\b(?:[Pp]roject|[Cc]ode[ -]?[Nn]ame|[Oo]peration)\s*[:=]?\s*["'“]?([A-Z][A-Za-z0-9-]*)`)
+	// prefixPattern finds subjects tagged with the part of a system they change, such as "[Phoenix] add login"
+	prefixPattern   = regexp.MustCompile(`^\[([A-Z][a-z][A-Za-z0-9-]*)\]`)
+	customerPattern = regexp.MustCompile(`\b(?:[Cc]ustomers?|[Cc]lients?|[Tt]enants?)(?:\s*[:=]\s*|\s+)["'“]?([A-Z][\w&'.-]*(?:\s+[A-Z][\w&'.-]*){0,2})`)
+	companyPattern  = regexp.MustCompile(`\b((?:[A-Z][\w&'-]*\s+){1,3}(?:Inc|Ltd|LLC|GmbH|PLC|Plc|Corp|Corporation|Limited|AG|BV|Pty|SAS|SARL|SpA))\b`)
+	// messageHostPattern finds public hostnames, which are left out of source code scans as they are too noisy
+	// there but are almost always meaningful in a message
+	messageHostPattern = regexp.MustCompile(`(?i)(?:^|[^\w@./-])((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.){2,}(?:com|net|org|io|co|uk|de|fr|nl|eu|au|ca|us|info|biz|app|dev|cloud|tech|ai))\b`)
+	// deploymentPattern does not match inside hostnames, such as db.prod.internal
+	deploymentPattern = regexp.MustCompile(`(?i)(?:^|[^\w.-])(deploy(?:s|ed|ing|ment)?|re-?deploy(?:s|ed|ing)?|roll(?:ed|ing)?[ -]?back|hot-?fix(?:es|ed)?|go[ -]live|cut-?over|downtime|maintenance window|prod(?:uction)?|staging)(?:$|[^\w.-]|[.-](?:\s|$))`)
+	// reflogAction is the prefix git gives reflog messages of commits, such as "commit (amend): "
+	reflogAction = regexp.MustCompile(`^commit(?: \([a-z-]+\))?: `)
+)
+
+// notTickets are prefixes of identifiers which look like tickets, such as UTF-8 and SHA-256
+var notTickets = []string{
+	"UTF", "UCS", "SHA", "MD", "CRC", "AES", "RSA", "ISO", "RFC", "CVE", "CWE", "HTTP", "TLS", "SSL", "IPV", "CP", "WIN",
+	"GPL", "LGPL", "AGPL", "BSD", "MPL", "ECMA", "ES", "PEP", "IE", "UUID", "BASE", "INT", "UINT", "FLOAT",
+}
+
+// commonPrefixes are subject tags which describe the type of change rather than a project
+var commonPrefixes = []string{
+	"wip", "fix", "fixes", "feat", "feature", "docs", "doc", "chore", "test", "tests", "merge", "hotfix", "bugfix", "bug",
+	"security", "release", "revert", "refactor", "style", "build", "perf", "skip", "misc", "update", "cleanup", "minor",
+	"draft", "breaking", "deps", "backport", "ci",
+}
+
+// notCustomers follow the word "client" or "customer" in technical phrases, e.g. "client ID"
+var notCustomers = []string{"ID", "IDs", "Id", "Secret", "Side", "API", "SDK", "Key", "Keys", "Certificate", "Library", "Error", "App", "Credentials"}
+
+type mentionMatch struct {
+	kind   string
+	value  string
+	detail string
+}
+
+// mentions reads every recovered commit message, then annotated tags, reflogs and COMMIT_EDITMSG, which often
+// hold the messages of commits which could not be recovered
+func (h *history) mentions() ([]exposed.Mention, error) {
+
+	m := &mentionIndex{index: make(map[string]int)}
+
+	for _, commit := range h.log {
+		m.message(commit.Message, exposed.MessageSource{
+			Type:   "commit",
+			Commit: commit.Hash,
+			Author: signature(commit.Author),
+			When:   commit.Author.When,
+		})
+	}
+
+	tags, err := h.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		m.message(tag.Message, exposed.MessageSource{
+			Type:   "tag",
+			Commit: tag.Object,
+			Ref:    tag.Name,
+			Author: signature(tag.Tagger),
+			When:   tag.Tagger.When,
+		})
+	}
+
+	entries, err := h.repo.Reflogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		m.message(reflogAction.ReplaceAllString(entry.Message, ""), exposed.MessageSource{
+			Type:   "reflog",
+			Commit: entry.New,
+			Ref:    entry.Ref,
+			Author: signature(entry.Who),
+			When:   entry.Who.When,
+		})
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(h.repo.GitDir(), "COMMIT_EDITMSG"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if message := editMessage(string(content)); message != "" {
+		source := exposed.MessageSource{Type: "COMMIT_EDITMSG"}
+		for _, commit := range h.log {
+			if strings.TrimSpace(commit.Message) == message {
+				source.Commit = commit.Hash
+				source.Author = signature(commit.Author)
+				source.When = commit.Author.When
+				break
+			}
+		}
+		m.message(message, source)
+	}
+
+	sortMentions(m.mentions)
+	return m.mentions, nil
+}
+
+type mentionIndex struct {
+	mentions []exposed.Mention
+	index    map[string]int
+}
+
+// message adds everything mentioned in a message. A message is only listed once for each mention, and messages
+// of the same commit count as one.
+func (m *mentionIndex) message(message string, source exposed.MessageSource) {
+	for i, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		source.Line = line
+		for _, match := range lineMentions(line, i == 0) {
+			id := match.kind + "\x00" + match.value
+			index, ok := m.index[id]
+			if !ok {
+				index = len(m.mentions)
+				m.index[id] = index
+				m.mentions = append(m.mentions, exposed.Mention{Kind: match.kind, Value: match.value, Detail: match.detail})
+			}
+			mention := &m.mentions[index]
+			if !hasSource(mention.Sources, source) {
+				mention.Sources = append(mention.Sources, source)
+			}
+		}
+	}
+}
+
+func hasSource(sources []exposed.MessageSource, source exposed.MessageSource) bool {
+	for _, existing := range sources {
+		if source.Commit != "" && existing.Commit == source.Commit {
+			return true
+		}
+		if source.Commit == "" && existing.Commit == "" && existing.Type == source.Type && existing.Ref == source.Ref && existing.Line == source.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// lineMentions finds everything mentioned on a line of a message. Subject tags are only recognised on the first
+// line.
+func lineMentions(line string, subject bool) []mentionMatch {
+
+	var found []mentionMatch
+	add := func(kind string, value string, detail string) {
+		for _, existing := range found {
+			if existing.kind == kind && existing.value == value {
+				return
+			}
+		}
+		found = append(found, mentionMatch{kind: kind, value: value, detail: detail})
+	}
+
+	for _, match := range ticketPattern.FindAllStringSubmatch(line, -1) {
+		if !contains(notTickets, match[1]) {
+			add("ticket", match[0], "")
+		}
+	}
+	for _, match := range issuePattern.FindAllStringSubmatch(line, -1) {
+		add("ticket", match[1], "")
+	}
+
+	if subject {
+		if match := prefixPattern.FindStringSubmatch(line); match != nil && !contains(commonPrefixes, strings.ToLower(match[1])) {
+			add("codename", match[1], "")
+		}
+	}
+	for _, match := range codenamePattern.FindAllStringSubmatch(line, -1) {
+		add("codename", match[1], "")
+	}
+
+	for _, match := range customerPattern.FindAllStringSubmatch(line, -1) {
+		name := strings.TrimRight(match[1], ".,:;'")
+		if !contains(notCustomers, strings.Fields(name)[0]) {
+			add("customer", name, "")
+		}
+	}
+	for _, match := range companyPattern.FindAllStringSubmatchIndex(line, -1) {
+		name := line[match[2]:match[3]]
+		// the first word of a line is capitalised anyway, so is more likely to be a verb than part of the name
+		if words := strings.Fields(name); match[2] == 0 && len(words) > 2 {
+			name = strings.Join(words[1:], " ")
+		}
+		add("customer", name, "")
+	}
+
+	for _, endpoint := range lineEndpoints(line, nil) {
+		switch endpoint.kind {
+		case "hostname", "ip", "third-party":
+			add("host", endpoint.value, endpoint.detail)
+		case "url", "database", "queue":
+			add("url", endpoint.value, endpoint.detail)
+		}
+	}
+	for _, match := range messageHostPattern.FindAllStringSubmatch(urlPattern.ReplaceAllString(line, " "), -1) {
+		host := strings.ToLower(match[1])
+		if isNoise(host) {
+			continue
+		}
+		detail := ""
+		for _, endpoint := range hostEndpoints(host) {
+			if endpoint.kind == "third-party" || endpoint.kind == "hostname" {
+				detail = endpoint.detail
+			}
+		}
+		add("host", host, detail)
+	}
+
+	if match := deploymentPattern.FindStringSubmatch(line); match != nil {
+		note := line
+		if runes := []rune(note); len(runes) > maxDeploymentNote {
+			note = string(runes[:maxDeploymentNote-3]) + "..."
+		}
+		add("deployment", note, strings.ToLower(match[1]))
+	}
+	return found
+}
+
+// editMessage reads COMMIT_EDITMSG, which holds the message of the last commit made in the repository along
+// with the comments git adds to explain it
+func editMessage(content string) string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		// verbose commits include the diff below a scissors line
+		if strings.HasPrefix(line, "# ------------------------ >8 ------------------------") {
+			break
+		}
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func signature(s gitrepo.Signature) string {
+	if s.Name == "" && s.Email == "" {
+		return ""
+	}
+	return s.String()
+}
+
+// sortMentions orders mentions by kind, then lists those found in the most messages first
+func sortMentions(mentions []exposed.Mention) {
+	rank := func(kind string) int {
+		for i, k := range mentionKinds {
+			if k == kind {
+				return i
+			}
+		}
+		return len(mentionKinds)
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		a, b := mentions[i], mentions[j]
+		if rank(a.Kind) != rank(b.Kind) {
+			return rank(a.Kind) < rank(b.Kind)
+		}
+		if len(a.Sources) != len(b.Sources) {
+			return len(a.Sources) > len(b.Sources)
+		}
+		return a.Value < b.Value
+	})
+}
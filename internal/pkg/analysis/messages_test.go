@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/internal/pkg/testutil"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestLineMentions(t *testing.T) {
+	tests := []struct {
+		line    string
+		subject bool
+		want    []string
+	}{
+		{
+			line:    "[Phoenix] PAY-1432: switch to UTF-8 and SHA-256 (fixes #88, acme/billing#12)",
+			subject: true,
+			want:    []string{"ticket PAY-1432", "ticket #88", "ticket acme/billing#12", "codename Phoenix"},
+		},
+		{
+			line:    "[WIP] start work on Project Atlas",
+			subject: true,
+			want:    []string{"codename Atlas"},
+		},
+		{
+			line: "Add export for customer Globex Corporation, requested by Initech Ltd",
+			want: []string{"customer Globex Corporation", "customer Initech Ltd"},
+		},
+		{
+			line: "Store client ID and client secret for tenant: Umbrella",
+			want: []string{"customer Umbrella"},
+		},
+		{
+			line: "Point the API at https://api.acme-corp.com/v2 and db01.prod.internal instead of 10.1.2.3",
+			want: []string{
+				"url https://api.acme-corp.com/v2",
+				"host db01.prod.internal internal",
+				"host 10.1.2.3 private",
+			},
+		},
+		{
+			line: "Move uploads to assets.cdn.acme-corp.com, contact ops@mail.acme-corp.com",
+			want: []string{"host assets.cdn.acme-corp.com"},
+		},
+		{
+			line: "Hotfix deployed to production after rollback of 2.3",
+			want: []string{"deployment Hotfix deployed to production after rollback of 2.3 hotfix"},
+		},
+		{
+			line: "Rolled back.",
+			want: []string{"deployment Rolled back. rolled back"},
+		},
+		{
+			line: "Refactor the parser",
+		},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, match := range lineMentions(test.line, test.subject) {
+			got = append(got, strings.TrimSpace(match.kind+" "+match.value+" "+match.detail))
+		}
+		assert.Equal(t, got, test.want, test.line)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	content := "Fix login for OPS-7\n\n# Please enter the commit message for your changes.\n#\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n"
+	assert.Equal(t, editMessage(content), "Fix login for OPS-7")
+}
+
+func TestMentions(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_mentions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "index.php", "<?php\n")
+	first := testutil.Commit(t, dir, "OPS-12 initial import")
+	testutil.Write(t, dir, "index.php", "<?php\necho 1;\n")
+	second := testutil.Commit(t, dir, "OPS-12 deploy to staging.acme-corp.com")
+	testutil.Git(t, dir, "tag", "-a", "v1", "-m", "Release for customer Globex")
+	testutil.Write(t, dir, "index.php", "<?php\necho 2;\n")
+	amended := testutil.Commit(t, dir, "Add OPS-99 feature for Project Atlas")
+	// amending leaves the old commit in the reflog only, and is the message left in COMMIT_EDITMSG
+	testutil.Git(t, dir, "commit", "-q", "--amend", "-m", "Add feature for Project Atlas")
+	head := testutil.Git(t, dir, "rev-parse", "HEAD")
+	if err := os.Remove(filepath.Join(dir, ".git", "objects", amended[:2], amended[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	result, err := Scan(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, mention := range result.Mentions {
+		values = append(values, mention.Kind+" "+mention.Value)
+	}
+	assert.Equal(t, values, []string{
+		"ticket OPS-12",
+		"ticket OPS-99",
+		"codename Atlas",
+		"customer Globex",
+		"host staging.acme-corp.com",
+		"deployment OPS-12 deploy to staging.acme-corp.com",
+	})
+
+	// the commit and its reflog entry count as one
+	tickets := result.Mentions[0].Sources
+	assert.Equal(t, len(tickets), 2)
+	assert.Equal(t, tickets[0].Type, "commit")
+	assert.Equal(t, tickets[0].Commit, second)
+	assert.Equal(t, tickets[1].Commit, first)
+
+	// the amended commit could not be recovered, but its message is still in the reflog
+	dropped := result.Mentions[1].Sources
+	assert.Equal(t, len(dropped), 1)
+	assert.Equal(t, dropped[0].Type, "reflog")
+	assert.Equal(t, dropped[0].Commit, amended)
+	assert.Equal(t, dropped[0].Ref, "HEAD")
+	assert.Equal(t, dropped[0].Author, testutil.Author+" <"+testutil.Email+">")
+
+	atlas := result.Mentions[2].Sources
+	assert.Equal(t, atlas[0].Commit, head)
+
+	globex := result.Mentions[3].Sources
+	assert.Equal(t, globex[0].Type, "tag")
+	assert.Equal(t, globex[0].Ref, "refs/tags/v1")
+	assert.Equal(t, globex[0].Commit, testutil.Git(t, dir, "rev-parse", "v1^{commit}"))
+}
@@ -0,0 +1,255 @@
+package analysis
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+const kindSecret = "secret"
+
+type secretRule struct {
+	id          string
+	description string
+	pattern     *regexp.Regexp
+	// group is the submatch holding the secret, or zero for the whole match
+	group int
+	// minEntropy rejects matches which are too regular to be real secrets
+	minEntropy float64
+	// generic rules match by context rather than by a known key format, so are prone to false positives
+	// and are not applied to generated files
+	generic bool
+}
+
+// secretRules are tried in order, so that a secret matched by a specific rule is not reported again by a generic one
+var secretRules = []secretRule{
+	{
+		id:          "private-key",
+		description: "Private key",
+		pattern:     regexp.MustCompile(`-----BEGIN[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----`),
+	},
+	{
+		id:          "aws-access-key-id",
+		description: "AWS access key ID",
+		pattern:     regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16})\b`),
+		group:       1,
+		minEntropy:  3,
+	},
+	{
+		id:          "aws-secret-access-key",
+		description: "AWS secret access key",
+		pattern:     regexp.MustCompile(`(?i)aws_?secret(?:_?access)?_?key["']?\s*(?:=>|:=|=|:)\s*["']?([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`),
+		group:       1,
+		minEntropy:  4,
+	},
+	{
+		id:          "github-token",
+		description: "GitHub token",
+		pattern:     regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`),
+		group:       1,
+	},
+	{
+		id:          "gitlab-token",
+		description: "GitLab personal access token",
+		pattern:     regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20})\b`),
+		group:       1,
+	},
+	{
+		id:          "slack-token",
+		description: "Slack token",
+		pattern:     regexp.MustCompile(`\b(xox[abposr]-[A-Za-z0-9-]{10,})\b`),
+		group:       1,
+	},
+	{
+		id:          "slack-webhook",
+		description: "Slack webhook URL",
+		pattern:     regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9_/]+`),
+	},
+	{
+		id:          "stripe-key",
+		description: "Stripe live secret key",
+		pattern:     regexp.MustCompile(`\b((?:sk|rk)_live_[A-Za-z0-9]{24,})\b`),
+		group:       1,
+	},
+	{
+		id:          "google-api-key",
+		description: "Google API key",
+		pattern:     regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`),
+		group:       1,
+	},
+	{
+		id:          "sendgrid-api-key",
+		description: "SendGrid API key",
+		pattern:     regexp.MustCompile(`\b(SG\.[A-Za-z0-9_-]{22}\.[A-Za-z0-9_-]{43})`),
+		group:       1,
+	},
+	{
+		id:          "npm-token",
+		description: "npm access token",
+		pattern:     regexp.MustCompile(`\b(npm_[A-Za-z0-9]{36})\b`),
+		group:       1,
+	},
+	{
+		id:          "jwt",
+		description: "JSON web token",
+		pattern:     regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`),
+		group:       1,
+	},
+	{
+		id:          "url-credentials",
+		description: "Credentials in URL",
+		pattern:     regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:[^\s/@'"]+@[A-Za-z0-9.-]+`),
+	},
+	{
+		id:          "generic-secret",
+		description: "Password or secret assigned in code",
+		pattern:     regexp.MustCompile(`(?i)[A-Za-z0-9_.-]*(?:passw(?:or)?d|pwd|secret|token|api_?key|access_?key|auth_?key|private_?key)[A-Za-z0-9_.-]*["']?\s*(?:=>|:=|=|:|,)\s*["']([^"'\s]{8,})["']`),
+		group:       1,
+		minEntropy:  3,
+		generic:     true,
+	},
+	{
+		id:          "generic-secret",
+		description: "Password or secret assigned in configuration",
+		pattern:     regexp.MustCompile(`(?i)^\s*(?:export\s+)?[A-Za-z0-9_.]*(?:passw(?:or)?d|pwd|secret|token|api_?key|access_?key|private_?key)[A-Za-z0-9_.]*\s*[=:]\s*([^\s"'#]{8,})\s*$`),
+		group:       1,
+		minEntropy:  3,
+		generic:     true,
+	},
+	{
+		id:          "high-entropy-string",
+		description: "High entropy string",
+		pattern:     regexp.MustCompile(`["']([A-Za-z0-9+/=_-]{32,})["']`),
+		group:       1,
+		minEntropy:  4.5,
+		generic:     true,
+	},
+}
+
+// placeholders are found in example configuration and templates, rather than real secrets
+var placeholders = []string{"example", "xxxx", "changeme", "change_me", "your_", "your-", "placeholder", "dummy", "${", "{{", "<", "%s", "process.env", "getenv", "env("}
+
+var variableReference = regexp.MustCompile(`^\$\{?[A-Za-z_]`)
+
+// generatedFiles hold checksums and minified code which are full of high entropy strings
+var generatedFiles = []string{"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "composer.lock", "Gemfile.lock", "Cargo.lock", "poetry.lock", "go.sum", "*.min.js", "*.min.css", "*.map"}
+
+type secretMatch struct {
+	rule        string
+	description string
+	value       string
+	entropy     float64
+}
+
+func detectSecrets(name string, content []byte) []hit {
+
+	if gitrepo.IsBinary(content) {
+		return nil
+	}
+	generated := isGenerated(name)
+
+	var hits []hit
+	seen := make(map[string]bool)
+	for i, line := range gitrepo.SplitLines(string(content)) {
+		for _, rule := range secretRules {
+			if rule.generic && generated {
+				continue
+			}
+			for _, match := range rule.pattern.FindAllStringSubmatchIndex(line, -1) {
+				start, end := match[rule.group*2], match[rule.group*2+1]
+				if start < 0 {
+					continue
+				}
+				value := line[start:end]
+				if seen[value] || isPlaceholder(value) {
+					continue
+				}
+				entropy := Entropy(value)
+				if entropy < rule.minEntropy {
+					continue
+				}
+				seen[value] = true
+				hits = append(hits, hit{
+					kind: kindSecret,
+					key:  rule.id + "\x00" + value,
+					line: i + 1,
+					value: secretMatch{
+						rule:        rule.id,
+						description: rule.description,
+						value:       value,
+						entropy:     math.Round(entropy*100) / 100,
+					},
+				})
+			}
+		}
+	}
+	return hits
+}
+
+func isGenerated(name string) bool {
+	for _, pattern := range generatedFiles {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlaceholder(value string) bool {
+	if variableReference.MatchString(value) {
+		return true
+	}
+	lower := strings.ToLower(value)
+	for _, placeholder := range placeholders {
+		if strings.Contains(lower, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entropy is the Shannon entropy of s in bits per character. Random keys score highly, while words and
+// repeated characters score low.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	var total int
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func (h *history) secrets() []exposed.Secret {
+	var secrets []exposed.Secret
+	for _, t := range h.tracked(kindSecret) {
+		match := t.hit.value.(secretMatch)
+		secrets = append(secrets, exposed.Secret{
+			Rule:        match.rule,
+			Description: match.description,
+			Value:       match.value,
+			Entropy:     match.entropy,
+			Occurrence:  h.occurrence(t),
+		})
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		if secrets[i].Path != secrets[j].Path {
+			return secrets[i].Path < secrets[j].Path
+		}
+		return secrets[i].Line < secrets[j].Line
+	})
+	return secrets
+}
@@ -0,0 +1,501 @@
+package browse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/liamg/gitjacker/internal/pkg/analysis"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+type Pane int
+
+const (
+	PaneRefs Pane = iota
+	PaneCommits
+	PaneTree
+	PaneViewer
+)
+
+var paneNames = map[Pane]string{
+	PaneRefs:    "Refs",
+	PaneCommits: "Commits",
+	PaneTree:    "Tree",
+	PaneViewer:  "Viewer",
+}
+
+// FileFilter limits the tree to recovered or missing files
+type FileFilter int
+
+const (
+	FilterAll FileFilter = iota
+	FilterRecovered
+	FilterMissing
+)
+
+func (f FileFilter) String() string {
+	switch f {
+	case FilterRecovered:
+		return "recovered"
+	case FilterMissing:
+		return "missing"
+	}
+	return "all"
+}
+
+// Finding is a location worth looking at. Paths beginning with .git/ refer to files in the git directory,
+// anything else to a file in the tree of the selected commit.
+type Finding struct {
+	Path        string
+	Line        int
+	Description string
+	// Commit is the commit the finding was seen in, or empty for the selected commit
+	Commit string
+}
+
+type treeFile struct {
+	gitrepo.File
+	Missing bool
+	// Dir marks a directory whose contents could not be recovered
+	Dir bool
+}
+
+// Browser holds the state of the interface. It does no terminal I/O, so it can be driven by tests.
+type Browser struct {
+	repo     *gitrepo.Repository
+	refs     []gitjacker.Ref
+	commits  []*gitrepo.Commit
+	files    []treeFile
+	findings []Finding
+
+	focus     Pane
+	filter    FileFilter
+	search    map[Pane]string
+	cursor    map[Pane]int
+	offset    map[Pane]int
+	searching bool
+	input     string
+
+	viewer  viewer
+	finding int
+	status  string
+	rows    map[Pane]int
+}
+
+type lineStyle int
+
+const (
+	styleNormal lineStyle = iota
+	styleHeader
+	styleAdd
+	styleDelete
+	styleHunk
+	styleNote
+	styleMark
+)
+
+// viewer is the content of the right hand pane
+type viewer struct {
+	title   string
+	lines   []string
+	styles  []lineStyle
+	numbers bool
+	match   string
+}
+
+func (v *viewer) add(style lineStyle, format string, args ...interface{}) {
+	for _, line := range strings.Split(fmt.Sprintf(format, args...), "\n") {
+		v.lines = append(v.lines, line)
+		v.styles = append(v.styles, style)
+	}
+}
+
+// ConfigFindings points at each credential in the git config
+func ConfigFindings(repo *gitrepo.Repository) ([]Finding, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, secret := range config.Secrets {
+		findings = append(findings, Finding{Path: ".git/config", Line: secret.Line, Description: secret.Description})
+	}
+	return findings, nil
+}
+
+// AnalysisFindings points at each secret, credential and sensitive file found in the recovered files and their history
+func AnalysisFindings(ctx context.Context, repo *gitrepo.Repository) ([]Finding, error) {
+	result, err := analysis.Scan(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, secret := range result.Secrets {
+		finding := Finding{Path: secret.Path, Line: secret.Line, Description: fmt.Sprintf("%s, %s", secret.Description, secret.History())}
+		// secrets still at HEAD are shown in whichever commit is selected, the rest where they were last seen
+		if !secret.Present {
+			finding.Commit = secret.Commit
+		}
+		findings = append(findings, finding)
+	}
+	for _, credential := range result.Credentials {
+		finding := Finding{Path: credential.Path, Line: credential.Line, Description: fmt.Sprintf("%s credentials %s, %s", credential.Format, credential.String(), credential.History())}
+		if !credential.Present {
+			finding.Commit = credential.Commit
+		}
+		findings = append(findings, finding)
+	}
+	for _, artifact := range result.Artifacts {
+		finding := Finding{Path: artifact.Path, Description: fmt.Sprintf("%s, %s", artifact.Description, artifact.History())}
+		if !artifact.Present {
+			finding.Commit = artifact.Commit
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// New creates a browser showing HEAD, or the first ref if HEAD cannot be resolved
+func New(repo *gitrepo.Repository, findings []Finding) (*Browser, error) {
+
+	b := &Browser{
+		repo:     repo,
+		findings: findings,
+		search:   make(map[Pane]string),
+		cursor:   make(map[Pane]int),
+		offset:   make(map[Pane]int),
+		rows:     make(map[Pane]int),
+		finding:  -1,
+	}
+
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	if head, err := repo.Head(); err == nil {
+		b.refs = append(b.refs, gitjacker.Ref{Name: "HEAD", Hash: head})
+	}
+	b.refs = append(b.refs, refs...)
+
+	b.selectRef()
+	if len(b.refs) == 0 {
+		b.viewer = viewer{title: "No refs"}
+		b.viewer.add(styleNote, "No refs could be found in this repository.")
+	}
+	return b, nil
+}
+
+// visible lists the indexes of the items in a pane which match its search and filter
+func (b *Browser) visible(pane Pane) []int {
+	query := strings.ToLower(b.search[pane])
+	var indexes []int
+	switch pane {
+	case PaneRefs:
+		for i, ref := range b.refs {
+			if strings.Contains(strings.ToLower(ref.Name+" "+ref.Hash), query) {
+				indexes = append(indexes, i)
+			}
+		}
+	case PaneCommits:
+		for i, commit := range b.commits {
+			if strings.Contains(strings.ToLower(commit.Hash+" "+commit.Author.String()+" "+commit.Message), query) {
+				indexes = append(indexes, i)
+			}
+		}
+	case PaneTree:
+		for i, file := range b.files {
+			if (b.filter == FilterMissing && !file.Missing) || (b.filter == FilterRecovered && file.Missing) {
+				continue
+			}
+			if strings.Contains(strings.ToLower(file.Path), query) {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	return indexes
+}
+
+// selected returns the index of the selected item in a pane, or -1 if there is none
+func (b *Browser) selected(pane Pane) int {
+	indexes := b.visible(pane)
+	if len(indexes) == 0 {
+		return -1
+	}
+	if b.cursor[pane] >= len(indexes) {
+		b.cursor[pane] = len(indexes) - 1
+	}
+	if b.cursor[pane] < 0 {
+		b.cursor[pane] = 0
+	}
+	return indexes[b.cursor[pane]]
+}
+
+func (b *Browser) selectRef() {
+	b.commits = nil
+	b.cursor[PaneCommits], b.offset[PaneCommits] = 0, 0
+	if i := b.selected(PaneRefs); i >= 0 {
+		commits, err := b.repo.Log([]string{b.refs[i].Hash}, 0)
+		if err != nil {
+			b.status = err.Error()
+		}
+		b.commits = commits
+	}
+	b.selectCommit()
+}
+
+func (b *Browser) selectCommit() {
+	b.files = nil
+	b.cursor[PaneTree], b.offset[PaneTree] = 0, 0
+
+	i := b.selected(PaneCommits)
+	if i < 0 {
+		b.viewer = viewer{title: "No commits"}
+		if ref := b.selected(PaneRefs); ref >= 0 {
+			b.viewer.add(styleNote, "Commit %s could not be recovered.", b.refs[ref].Hash)
+		}
+		return
+	}
+	commit := b.commits[i]
+
+	files, missingDirs, err := b.repo.Files(commit.Tree)
+	if err != nil {
+		b.status = err.Error()
+	}
+	for _, file := range files {
+		exists, err := b.repo.Exists(file.Hash)
+		b.files = append(b.files, treeFile{File: file, Missing: err != nil || (!exists && file.Mode != "160000")})
+	}
+	for _, dir := range missingDirs {
+		b.files = append(b.files, treeFile{File: gitrepo.File{Path: dir + "/"}, Missing: true, Dir: true})
+	}
+
+	b.showCommit(commit)
+}
+
+// selectCommitHash selects a commit of the selected ref by hash, returning false if it is not there
+func (b *Browser) selectCommitHash(hash string) bool {
+	b.search[PaneCommits] = ""
+	for position, i := range b.visible(PaneCommits) {
+		if b.commits[i].Hash == hash {
+			b.cursor[PaneCommits] = position
+			b.selectCommit()
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Browser) showCommit(commit *gitrepo.Commit) {
+	v := viewer{title: "commit " + shortHash(commit.Hash)}
+	v.add(styleHeader, "commit %s", commit.Hash)
+	if len(commit.Parents) > 1 {
+		v.add(styleHeader, "Merge: %s", strings.Join(commit.Parents, " "))
+	}
+	v.add(styleHeader, "Author: %s", commit.Author)
+	v.add(styleHeader, "Date:   %s", commit.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	v.add(styleNormal, "")
+	for _, line := range strings.Split(strings.TrimRight(commit.Message, "\n"), "\n") {
+		v.add(styleNormal, "    %s", line)
+	}
+	v.add(styleNormal, "")
+
+	changes, err := b.repo.DiffCommit(commit)
+	if errors.Is(err, gitrepo.ErrMissingObject) {
+		v.add(styleNote, "The parent commit could not be recovered, so changes are not available.")
+	} else if err != nil {
+		v.add(styleNote, "Changes could not be read: %s", err)
+	}
+	for _, change := range changes {
+		added, deleted := change.Stats()
+		v.add(styleHeader, "%s %s (+%d -%d)", change.Status, change.Path, added, deleted)
+		switch {
+		case change.Missing:
+			v.add(styleNote, "  content could not be recovered")
+		case change.Binary:
+			v.add(styleNote, "  binary file")
+		case change.TooLarge:
+			v.add(styleNote, "  file too large to compare")
+		}
+		for _, hunk := range change.Hunks {
+			v.add(styleHunk, "@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+			for _, line := range hunk.Lines {
+				style := styleNormal
+				switch line.Op {
+				case gitrepo.OpAdd:
+					style = styleAdd
+				case gitrepo.OpDelete:
+					style = styleDelete
+				}
+				v.lines = append(v.lines, string(line.Op)+line.Text)
+				v.styles = append(v.styles, style)
+			}
+		}
+		v.add(styleNormal, "")
+	}
+	b.setViewer(v)
+}
+
+func (b *Browser) showFile(file treeFile) {
+	v := viewer{title: file.Path, numbers: true}
+	switch {
+	case file.Dir:
+		v.numbers = false
+		v.add(styleNote, "The contents of this directory could not be recovered.")
+	case file.Mode == "160000":
+		v.numbers = false
+		v.add(styleNote, "Submodule at commit %s", file.Hash)
+	default:
+		content, err := b.repo.Blob(file.Hash)
+		b.fillFile(&v, content, err)
+	}
+	b.setViewer(v)
+}
+
+// showGitFile shows a file from the git directory itself, such as .git/config
+func (b *Browser) showGitFile(path string) {
+	v := viewer{title: path, numbers: true}
+	content, err := ioutil.ReadFile(filepath.Join(b.repo.GitDir(), filepath.FromSlash(filepath.Clean("/"+strings.TrimPrefix(path, ".git/")))))
+	b.fillFile(&v, content, err)
+	b.setViewer(v)
+}
+
+func (b *Browser) fillFile(v *viewer, content []byte, err error) {
+	switch {
+	case errors.Is(err, gitrepo.ErrMissingObject):
+		v.numbers = false
+		v.add(styleNote, "This file could not be recovered.")
+	case err != nil:
+		v.numbers = false
+		v.add(styleNote, "This file could not be read: %s", err)
+	case gitrepo.IsBinary(content) || !utf8.Valid(content):
+		v.numbers = false
+		v.add(styleNote, "Binary file, %d bytes.", len(content))
+	default:
+		for _, line := range gitrepo.SplitLines(string(content)) {
+			v.lines = append(v.lines, line)
+			v.styles = append(v.styles, styleNormal)
+		}
+	}
+}
+
+func (b *Browser) setViewer(v viewer) {
+	v.match = b.viewer.match
+	b.viewer = v
+	b.offset[PaneViewer] = 0
+	b.cursor[PaneViewer] = 0
+}
+
+// onSelect updates the panes which depend on the selection in a pane
+func (b *Browser) onSelect(pane Pane) {
+	switch pane {
+	case PaneRefs:
+		b.selectRef()
+	case PaneCommits:
+		b.selectCommit()
+	case PaneTree:
+		if i := b.selected(PaneTree); i >= 0 {
+			b.showFile(b.files[i])
+		}
+	}
+}
+
+// jumpToFinding shows the next or previous finding, selecting its file in the tree where possible
+func (b *Browser) jumpToFinding(step int) {
+	if len(b.findings) == 0 {
+		b.status = "There are no findings."
+		return
+	}
+	b.finding = (b.finding + step + len(b.findings)) % len(b.findings)
+	finding := b.findings[b.finding]
+
+	if strings.HasPrefix(finding.Path, ".git/") {
+		b.showGitFile(finding.Path)
+	} else {
+		if finding.Commit != "" && !b.selectCommitHash(finding.Commit) {
+			b.status = fmt.Sprintf("Finding %d/%d: %s is in commit %s, which is not on the selected ref", b.finding+1, len(b.findings), finding.Path, shortHash(finding.Commit))
+			return
+		}
+		b.filter = FilterAll
+		b.search[PaneTree] = ""
+		found := false
+		for position, i := range b.visible(PaneTree) {
+			if b.files[i].Path == finding.Path {
+				b.cursor[PaneTree] = position
+				b.focus = PaneTree
+				b.showFile(b.files[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.status = fmt.Sprintf("Finding %d/%d: %s is not in the selected commit", b.finding+1, len(b.findings), finding.Path)
+			return
+		}
+	}
+
+	if finding.Line > 0 && finding.Line <= len(b.viewer.lines) {
+		b.viewer.styles[finding.Line-1] = styleMark
+		b.cursor[PaneViewer] = finding.Line - 1
+		b.offset[PaneViewer] = finding.Line - 1 - b.rows[PaneViewer]/2
+		b.clampViewer()
+	}
+	b.status = fmt.Sprintf("Finding %d/%d: %s", b.finding+1, len(b.findings), finding.Description)
+}
+
+// nextMatch scrolls the viewer to the next or previous line matching the viewer search
+func (b *Browser) nextMatch(step int) {
+	query := strings.ToLower(b.viewer.match)
+	if query == "" {
+		return
+	}
+	count := len(b.viewer.lines)
+	for n := 1; n <= count; n++ {
+		i := ((b.cursor[PaneViewer]+step*n)%count + count) % count
+		if strings.Contains(strings.ToLower(b.viewer.lines[i]), query) {
+			b.cursor[PaneViewer] = i
+			b.offset[PaneViewer] = i - b.rows[PaneViewer]/2
+			b.clampViewer()
+			b.status = fmt.Sprintf("Line %d matches %q", i+1, b.viewer.match)
+			return
+		}
+	}
+	b.status = fmt.Sprintf("No lines match %q", b.viewer.match)
+}
+
+func (b *Browser) clampViewer() {
+	max := len(b.viewer.lines) - b.rows[PaneViewer]
+	if b.offset[PaneViewer] > max {
+		b.offset[PaneViewer] = max
+	}
+	if b.offset[PaneViewer] < 0 {
+		b.offset[PaneViewer] = 0
+	}
+}
+
+func (b *Browser) showHelp() {
+	v := viewer{title: "Help"}
+	v.add(styleHeader, "Keys")
+	v.add(styleNormal, `
+  Tab / Shift+Tab    switch pane
+  Up/Down, j/k       move the selection, or scroll the viewer
+  PgUp/PgDn          move a page at a time
+  Home/End, g/G      jump to the start or end
+  /                  search the focused pane, or the viewer content
+  n / N              next or previous search match in the viewer
+  m                  cycle the tree filter between all, missing and recovered files
+  f / F              jump to the next or previous finding
+  Esc                clear the search of the focused pane
+  ?                  show this help
+  q, Ctrl+C          quit`)
+	v.add(styleHeader, "\nLegend")
+	v.add(styleNormal, `
+  Files and commits which could not be recovered are marked with ! and shown in red.
+  Findings are highlighted in the viewer when jumped to.`)
+	b.setViewer(v)
+}
@@ -0,0 +1,181 @@
+package browse
+
+import (
+	"fmt"
+)
+
+// HandleKey applies a key press, returning true when the browser should close
+func (b *Browser) HandleKey(key Key) bool {
+
+	if key.Code == KeyCtrlC {
+		return true
+	}
+
+	if b.searching {
+		b.handleSearchKey(key)
+		return false
+	}
+
+	b.status = ""
+
+	switch key.Code {
+	case KeyTab, KeyRight:
+		b.focus = (b.focus + 1) % 4
+	case KeyBacktab, KeyLeft:
+		b.focus = (b.focus + 3) % 4
+	case KeyUp:
+		b.move(-1)
+	case KeyDown:
+		b.move(1)
+	case KeyPageUp:
+		b.move(-b.page())
+	case KeyPageDown:
+		b.move(b.page())
+	case KeyHome:
+		b.moveTo(0)
+	case KeyEnd:
+		b.moveTo(-1)
+	case KeyEnter:
+		if b.focus != PaneViewer {
+			b.focus++
+		}
+	case KeyEscape:
+		b.clearSearch()
+	case KeyRune:
+		switch key.Rune {
+		case 'q':
+			return true
+		case 'l':
+			b.focus = (b.focus + 1) % 4
+		case 'h':
+			b.focus = (b.focus + 3) % 4
+		case 'k':
+			b.move(-1)
+		case 'j':
+			b.move(1)
+		case 'g':
+			b.moveTo(0)
+		case 'G':
+			b.moveTo(-1)
+		case '/':
+			b.searching = true
+			b.input = b.search[b.focus]
+			if b.focus == PaneViewer {
+				b.input = b.viewer.match
+			}
+		case 'n':
+			b.nextMatch(1)
+		case 'N':
+			b.nextMatch(-1)
+		case 'm':
+			b.keepSelection(PaneTree, func() {
+				b.filter = (b.filter + 1) % 3
+			})
+			b.status = fmt.Sprintf("Showing %s files", b.filter)
+		case 'f':
+			b.jumpToFinding(1)
+		case 'F':
+			b.jumpToFinding(-1)
+		case '?':
+			b.showHelp()
+		}
+	}
+	return false
+}
+
+func (b *Browser) handleSearchKey(key Key) {
+	switch key.Code {
+	case KeyEscape:
+		b.searching = false
+	case KeyEnter:
+		b.searching = false
+		if b.focus == PaneViewer {
+			b.viewer.match = b.input
+			b.nextMatch(1)
+			return
+		}
+		b.keepSelection(b.focus, func() {
+			b.search[b.focus] = b.input
+		})
+	case KeyBackspace:
+		if runes := []rune(b.input); len(runes) > 0 {
+			b.input = string(runes[:len(runes)-1])
+		}
+	case KeyRune:
+		b.input += string(key.Rune)
+	}
+}
+
+func (b *Browser) clearSearch() {
+	if b.focus == PaneViewer {
+		b.viewer.match = ""
+		return
+	}
+	if b.search[b.focus] != "" {
+		b.keepSelection(b.focus, func() {
+			b.search[b.focus] = ""
+		})
+	}
+}
+
+// keepSelection changes what a pane lists, keeping the selected item selected if it is still listed
+func (b *Browser) keepSelection(pane Pane, change func()) {
+	before := b.selected(pane)
+	change()
+	b.cursor[pane] = 0
+	for position, i := range b.visible(pane) {
+		if i == before {
+			b.cursor[pane] = position
+			return
+		}
+	}
+	b.offset[pane] = 0
+	if b.selected(pane) != before {
+		b.onSelect(pane)
+	}
+}
+
+func (b *Browser) page() int {
+	if rows := b.rows[b.focus]; rows > 1 {
+		return rows - 1
+	}
+	return 1
+}
+
+func (b *Browser) move(delta int) {
+	if b.focus == PaneViewer {
+		b.offset[PaneViewer] += delta
+		b.clampViewer()
+		b.cursor[PaneViewer] = b.offset[PaneViewer]
+		return
+	}
+	position := b.cursor[b.focus] + delta
+	if position < 0 {
+		position = 0
+	}
+	b.moveTo(position)
+}
+
+// moveTo selects an item in the focused pane by position, where -1 selects the last item
+func (b *Browser) moveTo(position int) {
+	if b.focus == PaneViewer {
+		if position < 0 {
+			position = len(b.viewer.lines)
+		}
+		b.offset[PaneViewer] = position
+		b.clampViewer()
+		b.cursor[PaneViewer] = b.offset[PaneViewer]
+		return
+	}
+	count := len(b.visible(b.focus))
+	if position < 0 || position >= count {
+		position = count - 1
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position != b.cursor[b.focus] {
+		b.cursor[b.focus] = position
+		b.onSelect(b.focus)
+	}
+}
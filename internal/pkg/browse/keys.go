@@ -0,0 +1,111 @@
+package browse
+
+import (
+	"unicode/utf8"
+)
+
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyTab
+	KeyBacktab
+	KeyEnter
+	KeyEscape
+	KeyBackspace
+	KeyCtrlC
+)
+
+// Key is a single key press. Rune is only set for KeyRune.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+var escapeSequences = map[string]KeyCode{
+	"[A":  KeyUp,
+	"[B":  KeyDown,
+	"[C":  KeyRight,
+	"[D":  KeyLeft,
+	"OA":  KeyUp,
+	"OB":  KeyDown,
+	"OC":  KeyRight,
+	"OD":  KeyLeft,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+	"[H":  KeyHome,
+	"[F":  KeyEnd,
+	"OH":  KeyHome,
+	"OF":  KeyEnd,
+	"[1~": KeyHome,
+	"[4~": KeyEnd,
+	"[7~": KeyHome,
+	"[8~": KeyEnd,
+	"[Z":  KeyBacktab,
+}
+
+// ParseKeys decodes the bytes read from a terminal in raw mode. Unknown escape sequences are dropped, and an
+// escape which does not start a sequence is a press of the escape key.
+func ParseKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		switch c := input[0]; {
+		case c == 0x1b:
+			length, code, ok := parseEscape(input[1:])
+			if !ok {
+				keys = append(keys, Key{Code: KeyEscape})
+				input = input[1:]
+				continue
+			}
+			if code != KeyRune {
+				keys = append(keys, Key{Code: code})
+			}
+			input = input[1+length:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c < 0x20:
+			// other control characters have no meaning here
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+// parseEscape reads a CSI or SS3 sequence following an escape, returning its length and key. Sequences
+// which are recognised but not bound to a key return KeyRune, so they can be skipped.
+func parseEscape(input []byte) (int, KeyCode, bool) {
+	if len(input) < 2 || (input[0] != '[' && input[0] != 'O') {
+		return 0, 0, false
+	}
+	for i := 1; i < len(input); i++ {
+		// parameter and intermediate bytes continue the sequence, anything else ends it
+		if input[i] >= 0x20 && input[i] <= 0x3f {
+			continue
+		}
+		if code, ok := escapeSequences[string(input[:i+1])]; ok {
+			return i + 1, code, true
+		}
+		return i + 1, KeyRune, true
+	}
+	return 0, 0, false
+}
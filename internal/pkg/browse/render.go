@@ -0,0 +1,245 @@
+package browse
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	ansiReset    = "\x1b[0m"
+	ansiBold     = "\x1b[1m"
+	ansiReverse  = "\x1b[7m"
+	ansiTitle    = "\x1b[1;7m"
+	ansiRed      = "\x1b[31m"
+	ansiGreen    = "\x1b[32m"
+	ansiYellow   = "\x1b[33m"
+	ansiCyan     = "\x1b[36m"
+	ansiDim      = "\x1b[2m"
+	ansiMark     = "\x1b[30;43m"
+	ansiSelected = "\x1b[1;31;7m"
+)
+
+var lineStyles = map[lineStyle]string{
+	styleHeader: ansiBold,
+	styleAdd:    ansiGreen,
+	styleDelete: ansiRed,
+	styleHunk:   ansiCyan,
+	styleNote:   ansiYellow,
+	styleMark:   ansiMark,
+}
+
+const tabWidth = 4
+
+// fit makes a line exactly width cells wide, expanding tabs and replacing control characters so that
+// recovered content cannot move the cursor or change the terminal state
+func fit(s string, width int) string {
+	var line []rune
+	for _, r := range s {
+		if len(line) >= width {
+			break
+		}
+		switch {
+		case r == '\t':
+			for pad := tabWidth - len(line)%tabWidth; pad > 0 && len(line) < width; pad-- {
+				line = append(line, ' ')
+			}
+			continue
+		case unicode.IsControl(r):
+			r = '?'
+		}
+		line = append(line, r)
+	}
+	for len(line) < width {
+		line = append(line, ' ')
+	}
+	return string(line)
+}
+
+func styled(style string, s string) string {
+	if style == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// Render draws the whole screen as height lines of width cells, including ANSI escapes for colour
+func (b *Browser) Render(width int, height int) []string {
+
+	if width < 40 || height < 10 {
+		lines := make([]string, height)
+		for i := range lines {
+			lines[i] = fit("", width)
+		}
+		if height > 0 {
+			lines[0] = fit("Please enlarge the terminal", width)
+		}
+		return lines
+	}
+
+	body := height - 2
+	leftWidth := width / 3
+	if leftWidth < 30 {
+		leftWidth = 30
+	}
+	rightWidth := width - leftWidth - 1
+
+	refsHeight := len(b.refs) + 1
+	if refsHeight > body/4 {
+		refsHeight = body / 4
+	}
+	if refsHeight < 2 {
+		refsHeight = 2
+	}
+	commitsHeight := (body - refsHeight) / 2
+	treeHeight := body - refsHeight - commitsHeight
+
+	var left []string
+	left = append(left, b.renderList(PaneRefs, leftWidth, refsHeight)...)
+	left = append(left, b.renderList(PaneCommits, leftWidth, commitsHeight)...)
+	left = append(left, b.renderList(PaneTree, leftWidth, treeHeight)...)
+	right := b.renderViewer(rightWidth, body)
+
+	lines := []string{styled(ansiReverse, fit(b.header(), width))}
+	for i := 0; i < body; i++ {
+		lines = append(lines, left[i]+styled(ansiDim, "│")+right[i])
+	}
+	return append(lines, styled(ansiDim, fit(b.footer(), width)))
+}
+
+func (b *Browser) header() string {
+	header := " gitjacker browse  " + b.repo.GitDir()
+	if i := b.selected(PaneRefs); i >= 0 {
+		header += "  ref: " + b.refs[i].Name
+	}
+	return fmt.Sprintf("%s  filter: %s  findings: %d", header, b.filter, len(b.findings))
+}
+
+func (b *Browser) footer() string {
+	if b.searching {
+		return fmt.Sprintf(" Search %s: %s_", strings.ToLower(paneNames[b.focus]), b.input)
+	}
+	if b.status != "" {
+		return " " + b.status
+	}
+	return " Tab pane  / search  n/N next match  m filter  f/F findings  ? help  q quit"
+}
+
+// label describes an item in a list pane, and whether it could not be recovered
+func (b *Browser) label(pane Pane, i int) (string, bool) {
+	switch pane {
+	case PaneRefs:
+		return b.refs[i].Name + " " + shortHash(b.refs[i].Hash), false
+	case PaneCommits:
+		commit := b.commits[i]
+		return fmt.Sprintf("%s %s %s", shortHash(commit.Hash), commit.Author.When.Format("2006-01-02"), commit.Subject()), false
+	}
+	file := b.files[i]
+	switch {
+	case file.Dir && file.Path == "/":
+		return "! (root tree missing)", true
+	case file.Dir:
+		return "! " + file.Path + " (contents missing)", true
+	case file.Missing:
+		return "! " + file.Path, true
+	}
+	return "  " + file.Path, false
+}
+
+func (b *Browser) renderList(pane Pane, width int, height int) []string {
+
+	indexes := b.visible(pane)
+	rows := height - 1
+	b.rows[pane] = rows
+
+	title := fmt.Sprintf(" %s (%d)", paneNames[pane], len(indexes))
+	if b.search[pane] != "" {
+		title += " /" + b.search[pane]
+	}
+	if pane == PaneTree && b.filter != FilterAll {
+		title += " [" + b.filter.String() + "]"
+	}
+	titleStyle := ansiBold
+	if b.focus == pane {
+		titleStyle = ansiTitle
+	}
+	lines := []string{styled(titleStyle, fit(title, width))}
+
+	b.selected(pane)
+	cursor := b.cursor[pane]
+	if cursor < b.offset[pane] {
+		b.offset[pane] = cursor
+	}
+	if cursor >= b.offset[pane]+rows {
+		b.offset[pane] = cursor - rows + 1
+	}
+
+	for row := 0; row < rows; row++ {
+		position := b.offset[pane] + row
+		if position >= len(indexes) {
+			lines = append(lines, fit("", width))
+			continue
+		}
+		text, missing := b.label(pane, indexes[position])
+		style := ""
+		switch {
+		case position == cursor && b.focus == pane && missing:
+			style = ansiSelected
+		case position == cursor && b.focus == pane:
+			style = ansiReverse
+		case position == cursor:
+			style = ansiBold
+		case missing:
+			style = ansiRed
+		}
+		lines = append(lines, styled(style, fit(text, width)))
+	}
+	return lines
+}
+
+func (b *Browser) renderViewer(width int, height int) []string {
+
+	rows := height - 1
+	b.rows[PaneViewer] = rows
+	b.clampViewer()
+
+	title := " " + b.viewer.title
+	if b.viewer.match != "" {
+		title += " /" + b.viewer.match
+	}
+	titleStyle := ansiBold
+	if b.focus == PaneViewer {
+		titleStyle = ansiTitle
+	}
+	lines := []string{styled(titleStyle, fit(title, width))}
+
+	gutter := len(fmt.Sprint(len(b.viewer.lines)))
+	for row := 0; row < rows; row++ {
+		i := b.offset[PaneViewer] + row
+		if i >= len(b.viewer.lines) {
+			lines = append(lines, fit("", width))
+			continue
+		}
+		style := lineStyles[b.viewer.styles[i]]
+		if b.viewer.match != "" && i == b.cursor[PaneViewer] && b.viewer.styles[i] != styleMark {
+			style = ansiReverse
+		}
+		if !b.viewer.numbers {
+			lines = append(lines, styled(style, fit(" "+b.viewer.lines[i], width)))
+			continue
+		}
+		number := fmt.Sprintf(" %*d ", gutter, i+1)
+		if len(number) >= width {
+			number = ""
+		}
+		lines = append(lines, styled(ansiDim, number)+styled(style, fit(b.viewer.lines[i], width-len(number))))
+	}
+	return lines
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
@@ -0,0 +1,85 @@
+package browse
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// how often the terminal size is checked, as resizes are not signalled portably
+const resizeInterval = 250 * time.Millisecond
+
+// Run takes over the terminal until the user quits
+func Run(b *Browser, in *os.File, out *os.File) error {
+
+	inFd, outFd := int(in.Fd()), int(out.Fd())
+	if !terminal.IsTerminal(inFd) || !terminal.IsTerminal(outFd) {
+		return fmt.Errorf("browse needs an interactive terminal")
+	}
+
+	state, err := terminal.MakeRaw(inFd)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = terminal.Restore(inFd, state) }()
+
+	// switch to the alternate screen and hide the cursor, restoring both on exit
+	_, _ = out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() { _, _ = out.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l") }()
+
+	keys := make(chan []Key)
+	errs := make(chan error, 1)
+	go func() {
+		buffer := make([]byte, 256)
+		for {
+			n, err := in.Read(buffer)
+			if err != nil {
+				errs <- err
+				return
+			}
+			keys <- ParseKeys(buffer[:n])
+		}
+	}()
+
+	width, height := 0, 0
+	draw := func() error {
+		screen := &bytes.Buffer{}
+		for row, line := range b.Render(width, height) {
+			_, _ = fmt.Fprintf(screen, "\x1b[%d;1H%s", row+1, line)
+		}
+		_, err := out.Write(screen.Bytes())
+		return err
+	}
+
+	ticker := time.NewTicker(resizeInterval)
+	defer ticker.Stop()
+
+	for {
+		if w, h, err := terminal.GetSize(outFd); err == nil && (w != width || h != height) {
+			width, height = w, h
+			_, _ = out.WriteString("\x1b[2J")
+			if err := draw(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			continue
+		case pressed := <-keys:
+			for _, key := range pressed {
+				if b.HandleKey(key) {
+					return nil
+				}
+			}
+			if err := draw(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FileVersion is a commit which changed a file. Hash is the content after the commit, and empty if the commit
+// deleted the file. A status of StatusUnknown means the file was there but the parent commit, or the file in
+// it, could not be recovered for comparison.
+type FileVersion struct {
+	Commit *Commit
+	Hash   string
+	Status ChangeStatus
+}
+
+// FileHistory lists the commits reachable from start which changed a path, newest first
+func (r *Repository) FileHistory(start string, path string) ([]FileVersion, error) {
+
+	commits, err := r.Log([]string{start}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []FileVersion
+	for _, commit := range commits {
+		current, err := r.pathHash(commit.Tree, path)
+		if err != nil {
+			continue
+		}
+
+		previous := ""
+		if len(commit.Parents) > 0 {
+			parent, err := r.Commit(commit.Parents[0])
+			if err == nil {
+				previous, err = r.pathHash(parent.Tree, path)
+			}
+			if errors.Is(err, ErrMissingObject) {
+				if current != "" {
+					versions = append(versions, FileVersion{Commit: commit, Hash: current, Status: StatusUnknown})
+				}
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case current == previous:
+		case previous == "":
+			versions = append(versions, FileVersion{Commit: commit, Hash: current, Status: StatusAdded})
+		case current == "":
+			versions = append(versions, FileVersion{Commit: commit, Status: StatusDeleted})
+		default:
+			versions = append(versions, FileVersion{Commit: commit, Hash: current, Status: StatusModified})
+		}
+	}
+	return versions, nil
+}
+
+// pathHash finds the object at a path, returning an empty hash if there is nothing there
+func (r *Repository) pathHash(tree string, path string) (string, error) {
+	entry, err := r.Lookup(tree, path)
+	if errors.Is(err, ErrPathNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if entry.IsDir() {
+		return "", nil
+	}
+	return entry.Hash, nil
+}
+
+// BlameLine is a line of a file and the commit which last changed it. Boundary marks lines which were already
+// present in the oldest version that could be recovered, so they may have been written earlier still.
+type BlameLine struct {
+	Text     string
+	Commit   *Commit
+	Boundary bool
+}
+
+// Blame attributes each line of a file, as of the start commit, to the commit which last changed it
+func (r *Repository) Blame(start string, path string) ([]BlameLine, error) {
+
+	versions, err := r.FileHistory(start, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || versions[0].Hash == "" {
+		return nil, fmt.Errorf("%w: %s", ErrPathNotFound, path)
+	}
+
+	content, err := r.Blob(versions[0].Hash)
+	if err != nil {
+		return nil, err
+	}
+	lines := SplitLines(string(content))
+	blame := make([]BlameLine, len(lines))
+
+	// pending maps lines of the version being examined to the lines of the file they became
+	pending := make(map[int]int, len(lines))
+	for i, line := range lines {
+		blame[i].Text = line
+		pending[i] = i
+	}
+
+	for i, version := range versions {
+		if len(pending) == 0 {
+			break
+		}
+
+		var older []string
+		known := i+1 < len(versions) && version.Status != StatusUnknown && versions[i+1].Hash != ""
+		if known {
+			content, err := r.Blob(versions[i+1].Hash)
+			if errors.Is(err, ErrMissingObject) {
+				known = false
+			} else if err != nil {
+				return nil, err
+			}
+			older = SplitLines(string(content))
+		}
+
+		if !known {
+			// without the previous content, the remaining lines can only be traced this far back
+			for _, line := range pending {
+				blame[line].Commit = version.Commit
+				blame[line].Boundary = version.Status != StatusAdded
+			}
+			break
+		}
+
+		content, err := r.Blob(version.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		next := make(map[int]int)
+		oldIndex, newIndex := 0, 0
+		for _, edit := range editScript(older, SplitLines(string(content))) {
+			switch edit.Op {
+			case OpEqual:
+				if line, ok := pending[newIndex]; ok {
+					next[oldIndex] = line
+				}
+				oldIndex++
+				newIndex++
+			case OpDelete:
+				oldIndex++
+			case OpAdd:
+				if line, ok := pending[newIndex]; ok {
+					blame[line].Commit = version.Commit
+				}
+				newIndex++
+			}
+		}
+		pending = next
+	}
+	return blame, nil
+}
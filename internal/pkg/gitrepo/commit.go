@@ -0,0 +1,159 @@
+package gitrepo
+
+import (
+	"container/heap"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+func (s Signature) String() string {
+	return strings.TrimSpace(s.Name + " <" + s.Email + ">")
+}
+
+type Commit struct {
+	Hash      string
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0])
+}
+
+func (r *Repository) Commit(hash string) (*Commit, error) {
+	object, err := r.typedObject(hash, TypeCommit)
+	if err != nil {
+		return nil, err
+	}
+	return parseCommit(hash, object.Data), nil
+}
+
+func parseCommit(hash string, data []byte) *Commit {
+	commit := &Commit{Hash: hash}
+	content := string(data)
+
+	headers := content
+	if i := strings.Index(content, "\n\n"); i >= 0 {
+		headers = content[:i]
+		commit.Message = content[i+2:]
+	}
+
+	for _, line := range strings.Split(headers, "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "tree":
+			commit.Tree = parts[1]
+		case "parent":
+			commit.Parents = append(commit.Parents, parts[1])
+		case "author":
+			commit.Author = parseSignature(parts[1])
+		case "committer":
+			commit.Committer = parseSignature(parts[1])
+		}
+	}
+	return commit
+}
+
+// parseSignature reads a signature such as "Jane Doe <jane@example.com> 1600000000 +0100"
+func parseSignature(raw string) Signature {
+	var signature Signature
+	start, end := strings.Index(raw, "<"), strings.LastIndex(raw, ">")
+	if start < 0 || end < start {
+		signature.Name = strings.TrimSpace(raw)
+		return signature
+	}
+	signature.Name = strings.TrimSpace(raw[:start])
+	signature.Email = raw[start+1 : end]
+
+	fields := strings.Fields(raw[end+1:])
+	if len(fields) == 0 {
+		return signature
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return signature
+	}
+	signature.When = time.Unix(seconds, 0)
+	if len(fields) > 1 && len(fields[1]) == 5 {
+		hours, _ := strconv.Atoi(fields[1][1:3])
+		minutes, _ := strconv.Atoi(fields[1][3:5])
+		offset := hours*3600 + minutes*60
+		if fields[1][0] == '-' {
+			offset = -offset
+		}
+		signature.When = signature.When.In(time.FixedZone(fields[1], offset))
+	}
+	return signature
+}
+
+// Log walks history from the given commits, newest first. Missing commits are skipped, along with their
+// ancestors unless they are reachable another way. A limit of zero or less returns every commit.
+func (r *Repository) Log(from []string, limit int) ([]*Commit, error) {
+
+	seen := make(map[string]bool)
+	queue := &commitQueue{}
+
+	push := func(hash string) error {
+		if seen[hash] {
+			return nil
+		}
+		seen[hash] = true
+		commit, err := r.Commit(hash)
+		if errors.Is(err, ErrMissingObject) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		heap.Push(queue, commit)
+		return nil
+	}
+
+	for _, hash := range from {
+		if err := push(hash); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*Commit
+	for queue.Len() > 0 && (limit <= 0 || len(commits) < limit) {
+		commit := heap.Pop(queue).(*Commit)
+		commits = append(commits, commit)
+		for _, parent := range commit.Parents {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return commits, nil
+}
+
+// commitQueue orders commits by commit time, newest first
+type commitQueue []*Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*Commit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
@@ -0,0 +1,20 @@
+package gitrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// Config reads the git config, including the credentials it holds, in the same way as retrieval does
+func (r *Repository) Config() (gitjacker.Config, error) {
+	content, err := ioutil.ReadFile(filepath.Join(r.gitDir, "config"))
+	if os.IsNotExist(err) {
+		return gitjacker.Config{}, nil
+	} else if err != nil {
+		return gitjacker.Config{}, err
+	}
+	return gitjacker.ParseConfig(content), nil
+}
@@ -0,0 +1,395 @@
+package gitrepo
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// files larger than this are reported as changed without computing a line diff
+const maxDiffSize = 1 << 20
+
+// beyond this many edits, a line diff is too slow to compute and too long to read, so files are shown as replaced
+const maxEditDistance = 2000
+
+const diffContext = 3
+
+type ChangeStatus string
+
+const (
+	StatusAdded    ChangeStatus = "added"
+	StatusDeleted  ChangeStatus = "deleted"
+	StatusModified ChangeStatus = "modified"
+	// StatusUnknown marks a directory which could not be read in one of the trees, so its changes are unknown
+	StatusUnknown ChangeStatus = "unknown"
+)
+
+// Change describes a file which differs between two trees. Hunks are only available for text files whose
+// content could be read, otherwise Binary, Missing or TooLarge explains why.
+type Change struct {
+	Path     string
+	Status   ChangeStatus
+	OldHash  string
+	NewHash  string
+	Binary   bool
+	Missing  bool
+	TooLarge bool
+	Hunks    []Hunk
+}
+
+// Stats counts the lines added and deleted by a change
+func (c Change) Stats() (added int, deleted int) {
+	for _, hunk := range c.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Op {
+			case OpAdd:
+				added++
+			case OpDelete:
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+type LineOp byte
+
+const (
+	OpEqual  LineOp = ' '
+	OpAdd    LineOp = '+'
+	OpDelete LineOp = '-'
+)
+
+type Line struct {
+	Op   LineOp
+	Text string
+}
+
+// DiffCommit compares a commit with its first parent. Root commits are compared with an empty tree.
+func (r *Repository) DiffCommit(commit *Commit) ([]Change, error) {
+	var parentTree string
+	if len(commit.Parents) > 0 {
+		parent, err := r.Commit(commit.Parents[0])
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.Tree
+	}
+	return r.Diff(parentTree, commit.Tree)
+}
+
+// Diff compares two trees, where an empty hash is treated as an empty tree
+func (r *Repository) Diff(fromTree string, toTree string) ([]Change, error) {
+
+	from, fromMissing, err := r.fileMap(fromTree)
+	if err != nil {
+		return nil, err
+	}
+	to, toMissing, err := r.fileMap(toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	unknown := append(fromMissing, toMissing...)
+	seen := make(map[string]bool)
+	for _, dir := range unknown {
+		if dir == "" {
+			dir = "."
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			changes = append(changes, Change{Path: dir, Status: StatusUnknown, Missing: true})
+		}
+	}
+
+	for path, old := range from {
+		current, ok := to[path]
+		switch {
+		case underAny(path, unknown):
+			continue
+		case !ok:
+			changes = append(changes, Change{Path: path, Status: StatusDeleted, OldHash: old.Hash})
+		case current.Hash != old.Hash:
+			changes = append(changes, Change{Path: path, Status: StatusModified, OldHash: old.Hash, NewHash: current.Hash})
+		}
+	}
+	for path, current := range to {
+		if _, ok := from[path]; !ok && !underAny(path, unknown) {
+			changes = append(changes, Change{Path: path, Status: StatusAdded, NewHash: current.Hash})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	for i := range changes {
+		if changes[i].Status == StatusUnknown {
+			continue
+		}
+		if err := r.diffContent(&changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// fileMap lists the files below a tree by path, along with any directories which could not be read
+func (r *Repository) fileMap(tree string) (map[string]File, []string, error) {
+	files := make(map[string]File)
+	if tree == "" {
+		return files, nil, nil
+	}
+	list, missing, err := r.Files(tree)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range list {
+		if file.Mode == "160000" {
+			continue
+		}
+		files[file.Path] = file
+	}
+	return files, missing, nil
+}
+
+// underAny reports whether a path is within any of the given directories, where "" is the root
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if dir == "" || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Repository) diffContent(change *Change) error {
+
+	read := func(hash string) ([]byte, error) {
+		if hash == "" {
+			return nil, nil
+		}
+		return r.Blob(hash)
+	}
+
+	old, err := read(change.OldHash)
+	if errors.Is(err, ErrMissingObject) {
+		change.Missing = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+	current, err := read(change.NewHash)
+	if errors.Is(err, ErrMissingObject) {
+		change.Missing = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch {
+	case IsBinary(old) || IsBinary(current):
+		change.Binary = true
+	case len(old) > maxDiffSize || len(current) > maxDiffSize:
+		change.TooLarge = true
+	default:
+		change.Hunks = DiffLines(SplitLines(string(old)), SplitLines(string(current)))
+	}
+	return nil
+}
+
+// IsBinary uses the same heuristic as git, looking for a null byte near the start of the content
+func IsBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// SplitLines splits content into lines, without their line endings
+func SplitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// DiffLines returns the hunks of a unified diff between two sets of lines
+func DiffLines(a []string, b []string) []Hunk {
+	return hunks(editScript(a, b), diffContext)
+}
+
+// editScript finds the shortest sequence of edits from a to b, after trimming any common prefix and suffix
+func editScript(a []string, b []string) []Line {
+
+	var prefix, suffix []Line
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, Line{Op: OpEqual, Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]Line{{Op: OpEqual, Text: a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	middle, ok := myers(a, b)
+	if !ok {
+		middle = nil
+		for _, line := range a {
+			middle = append(middle, Line{Op: OpDelete, Text: line})
+		}
+		for _, line := range b {
+			middle = append(middle, Line{Op: OpAdd, Text: line})
+		}
+	}
+
+	return append(append(prefix, middle...), suffix...)
+}
+
+// myers implements the O(ND) difference algorithm, giving up once more than maxEditDistance edits are needed
+func myers(a []string, b []string) ([]Line, bool) {
+
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxEditDistance {
+			return nil, false
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d), true
+			}
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+	}
+	return nil, false
+}
+
+func backtrack(a []string, b []string, trace [][]int, distance int) []Line {
+
+	var reversed []Line
+	x, y := len(a), len(b)
+
+	for d := distance; d > 0; d-- {
+		previous := trace[d-1]
+		at := func(k int) int { return previous[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Line{Op: OpEqual, Text: a[x]})
+		}
+		if prevK == k+1 {
+			reversed = append(reversed, Line{Op: OpAdd, Text: b[prevY]})
+		} else {
+			reversed = append(reversed, Line{Op: OpDelete, Text: a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, Line{Op: OpEqual, Text: a[x]})
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
+
+// hunks groups edits into hunks, each surrounded by up to context unchanged lines
+func hunks(edits []Line, context int) []Hunk {
+
+	// line numbers before each edit
+	oldPos := make([]int, len(edits)+1)
+	newPos := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if edit.Op != OpAdd {
+			oldPos[i+1]++
+		}
+		if edit.Op != OpDelete {
+			newPos[i+1]++
+		}
+	}
+
+	var result []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].Op == OpEqual {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i + 1
+		for j := i + 1; j < len(edits); j++ {
+			if edits[j].Op != OpEqual {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		hunk := Hunk{
+			OldStart: oldPos[start] + 1,
+			OldLines: oldPos[stop] - oldPos[start],
+			NewStart: newPos[start] + 1,
+			NewLines: newPos[stop] - newPos[start],
+			Lines:    append([]Line(nil), edits[start:stop]...),
+		}
+		// an empty range starts at the line before it, as in git
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		result = append(result, hunk)
+		i = stop
+	}
+	return result
+}
@@ -0,0 +1,413 @@
+package gitrepo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/liamg/gitjacker/internal/pkg/testutil"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestRepository(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_gitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "index.php", "<?php\necho 'a';\necho 'b';\necho 'c';\n")
+	testutil.Write(t, dir, "lib/db.php", "<?php\n$password = 'x';\n")
+	testutil.Git(t, dir, "add", ".")
+	testutil.Git(t, dir, "commit", "-q", "-m", "first commit")
+	first := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	testutil.Write(t, dir, "index.php", "<?php\necho 'a';\necho 'B';\necho 'c';\n")
+	testutil.Write(t, dir, "notes.txt", "hello\n")
+	testutil.Git(t, dir, "add", ".")
+	testutil.Git(t, dir, "commit", "-q", "-m", "second commit\n\nwith a body")
+	second := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	// remove a blob, as is usual for a partially recovered repository
+	missing := testutil.Git(t, dir, "rev-parse", "HEAD:lib/db.php")
+	if err := os.Remove(filepath.Join(dir, ".git", "objects", missing[:2], missing[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, head, second)
+
+	commit, err := repo.Commit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, commit.Subject(), "second commit")
+	assert.Equal(t, commit.Parents, []string{first})
+	assert.Equal(t, commit.Author.String(), testutil.Author+" <"+testutil.Email+">")
+	assert.Equal(t, strconv.FormatInt(commit.Author.When.Unix(), 10), testutil.Git(t, dir, "log", "-1", "--format=%at", head))
+
+	files, missingDirs, err := repo.Files(commit.Tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, paths, []string{"index.php", "lib/db.php", "notes.txt"})
+	assert.Equal(t, len(missingDirs), 0)
+
+	_, err = repo.Blob(missing)
+	assert.Equal(t, errors.Is(err, ErrMissingObject), true)
+
+	log, err := repo.Log([]string{head}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(log), 2)
+
+	changes, err := repo.DiffCommit(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(changes), 2)
+	assert.Equal(t, changes[0].Path, "index.php")
+	assert.Equal(t, changes[0].Status, StatusModified)
+	added, deleted := changes[0].Stats()
+	assert.Equal(t, added, 1)
+	assert.Equal(t, deleted, 1)
+	assert.Equal(t, changes[1].Status, StatusAdded)
+
+	rootChanges, err := repo.DiffCommit(log[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rootChanges[1].Path, "lib/db.php")
+	assert.Equal(t, rootChanges[1].Missing, true)
+}
+
+func TestDiffLines(t *testing.T) {
+	a := SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n")
+	b := SplitLines("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n")
+
+	hunks := DiffLines(a, b)
+	assert.Equal(t, len(hunks), 2)
+
+	assert.Equal(t, hunks[0].OldStart, 1)
+	assert.Equal(t, hunks[0].OldLines, 6)
+	assert.Equal(t, hunks[0].NewStart, 1)
+	assert.Equal(t, hunks[0].NewLines, 6)
+	assert.Equal(t, hunks[0].Lines[2], Line{Op: OpDelete, Text: "3"})
+	assert.Equal(t, hunks[0].Lines[3], Line{Op: OpAdd, Text: "three"})
+
+	assert.Equal(t, hunks[1].OldStart, 10)
+	assert.Equal(t, hunks[1].OldLines, 3)
+	assert.Equal(t, hunks[1].NewStart, 10)
+	assert.Equal(t, hunks[1].NewLines, 4)
+
+	// interleaved changes are found without the common prefix and suffix shortcut
+	edits := editScript([]string{"a", "b", "c", "a", "b", "b", "a"}, []string{"c", "b", "a", "b", "a", "c"})
+	var changed int
+	var before, after []string
+	for _, edit := range edits {
+		if edit.Op != OpEqual {
+			changed++
+		}
+		if edit.Op != OpAdd {
+			before = append(before, edit.Text)
+		}
+		if edit.Op != OpDelete {
+			after = append(after, edit.Text)
+		}
+	}
+	assert.Equal(t, changed, 5)
+	assert.Equal(t, before, []string{"a", "b", "c", "a", "b", "b", "a"})
+	assert.Equal(t, after, []string{"c", "b", "a", "b", "a", "c"})
+}
+
+func TestRevisions(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_gitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "lib/x.php", "x\n")
+	testutil.Git(t, dir, "add", ".")
+	testutil.Git(t, dir, "commit", "-q", "-m", "first")
+	first := testutil.Git(t, dir, "rev-parse", "HEAD")
+	testutil.Git(t, dir, "tag", "-a", "v1", "-m", "release")
+	testutil.Write(t, dir, "a.txt", "a\n")
+	testutil.Git(t, dir, "add", ".")
+	testutil.Git(t, dir, "commit", "-q", "-m", "second")
+	second := testutil.Git(t, dir, "rev-parse", "HEAD")
+	testutil.Write(t, dir, "lib/x.php", "y\n")
+	testutil.Git(t, dir, "commit", "-q", "-am", "third")
+	third := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	// a commit which is only named by the reflog after a reset
+	testutil.Write(t, dir, "a.txt", "dropped\n")
+	testutil.Git(t, dir, "commit", "-q", "-am", "dropped")
+	dropped := testutil.Git(t, dir, "rev-parse", "HEAD")
+	testutil.Git(t, dir, "reset", "-q", "--hard", "HEAD~1")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	for rev, expected := range map[string]string{
+		"HEAD":     third,
+		"HEAD~2":   first,
+		"@^":       second,
+		"HEAD^^0":  second,
+		"v1":       first,
+		"tags/v1":  first,
+		second[:7]: second,
+	} {
+		hash, err := repo.ResolveRevision(rev)
+		if err != nil {
+			t.Fatalf("%s: %s", rev, err)
+		}
+		assert.Equal(t, hash, expected, rev)
+	}
+	_, err = repo.ResolveRevision("nope")
+	assert.Equal(t, err != nil, true)
+
+	tips, err := repo.Tips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(strings.Join(tips, " "), dropped), true)
+
+	reflogs, err := repo.Reflogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last ReflogEntry
+	for _, entry := range reflogs {
+		if entry.Ref == "HEAD" {
+			last = entry
+		}
+	}
+	assert.Equal(t, last.Old, dropped)
+	assert.Equal(t, last.New, third)
+	assert.Equal(t, strings.HasPrefix(last.Message, "reset:"), true)
+	assert.Equal(t, last.Who.Name, "Dev")
+
+	commit, err := repo.Commit(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := repo.Lookup(commit.Tree, "/lib/x.php")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, entry.Name, "x.php")
+	_, err = repo.Lookup(commit.Tree, "lib/nope")
+	assert.Equal(t, errors.Is(err, ErrPathNotFound), true)
+
+	// lose a directory and a commit
+	lib := testutil.Git(t, dir, "rev-parse", "HEAD:lib")
+	for _, hash := range []string{lib, second} {
+		if err := os.Remove(filepath.Join(dir, ".git", "objects", hash[:2], hash[2:])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = repo.Lookup(commit.Tree, "lib/x.php")
+	assert.Equal(t, errors.Is(err, ErrMissingObject), true)
+
+	firstCommit, err := repo.Commit(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repo.Diff(firstCommit.Tree, commit.Tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(changes), 2)
+	assert.Equal(t, changes[0].Path, "a.txt")
+	assert.Equal(t, changes[0].Status, StatusAdded)
+	assert.Equal(t, changes[1].Path, "lib")
+	assert.Equal(t, changes[1].Status, StatusUnknown)
+
+	missing, err := repo.MissingParents(commit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, missing, []string{second})
+}
+
+func TestTags(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "index.php", "<?php\n")
+	testutil.Git(t, dir, "add", ".")
+	testutil.Git(t, dir, "commit", "-q", "-m", "first commit")
+	head := testutil.Git(t, dir, "rev-parse", "HEAD")
+	testutil.Git(t, dir, "tag", "-a", "v1.0.0", "-m", "Release 1.0.0\n\nShipped to production")
+	testutil.Git(t, dir, "tag", "lightweight")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(tags), 1)
+	assert.Equal(t, tags[0].Name, "refs/tags/v1.0.0")
+	assert.Equal(t, tags[0].Object, head)
+	assert.Equal(t, tags[0].Tagger.Email, "dev@example.com")
+	assert.Equal(t, tags[0].Message, "Release 1.0.0\n\nShipped to production\n")
+}
+
+func TestPacks(t *testing.T) {
+	tests := []struct {
+		name string
+		pack []string
+	}{
+		{name: "offset deltas", pack: []string{"repack", "-a", "-d", "-q"}},
+		// without --delta-base-offset, deltas name their base by hash, as in packs sent by older servers
+		{name: "ref deltas", pack: []string{"pack-objects", "-q", "--all", "--revs", ".git/objects/pack/pack"}},
+		{name: "version 1 index", pack: []string{"pack-objects", "-q", "--all", "--revs", "--index-version=1", ".git/objects/pack/pack"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "gjtest_packs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = os.RemoveAll(dir) }()
+
+			testutil.Git(t, dir, "init", "-q")
+			content := "<?php\n"
+			for i := 0; i < 20; i++ {
+				content += fmt.Sprintf("echo 'line %d';\n", i)
+				testutil.Write(t, dir, "index.php", content)
+				testutil.Git(t, dir, "add", ".")
+				testutil.Git(t, dir, "commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+			}
+			testutil.Git(t, dir, "tag", "-a", "v1", "-m", "release")
+			testutil.Git(t, dir, "pack-refs", "--all")
+
+			cmd := exec.Command("git", test.pack...)
+			cmd.Dir = dir
+			if test.pack[0] == "pack-objects" {
+				cmd.Stdin = strings.NewReader("")
+			}
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %s: %s: %s", strings.Join(test.pack, " "), err, output)
+			}
+			testutil.Git(t, dir, "prune-packed")
+
+			if testutil.Git(t, dir, "count-objects") != "0 objects, 0 kilobytes" {
+				t.Fatal("objects were not packed")
+			}
+			indexes, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "pack-*.idx"))
+			if err != nil || len(indexes) != 1 {
+				t.Fatalf("expected a single pack: %v", err)
+			}
+			if !strings.Contains(testutil.Git(t, dir, "verify-pack", "-v", indexes[0]), "chain length = ") {
+				t.Fatal("pack does not contain any deltas")
+			}
+
+			repo, err := Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = repo.Close() }()
+
+			for _, line := range strings.Split(testutil.Git(t, dir, "cat-file", "--batch-all-objects", "--batch-check"), "\n") {
+				fields := strings.Fields(line)
+				object, err := repo.Object(fields[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, err := exec.Command("git", "--git-dir", filepath.Join(dir, ".git"), "cat-file", fields[1], fields[0]).Output()
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, string(object.Type), fields[1])
+				assert.Equal(t, string(object.Data), string(expected), fields[0])
+			}
+
+			head := testutil.Git(t, dir, "rev-parse", "HEAD")
+			hash, err := repo.ResolveRevision(head[:7])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, hash, head)
+
+			log, err := repo.Log([]string{head}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, len(log), 20)
+
+			exists, err := repo.Exists(strings.Repeat("0", 40))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, exists, false)
+		})
+	}
+}
+
+func TestOversizedObjects(t *testing.T) {
+
+	// sizes come from the target, so an absurd one must be an error rather than an allocation
+	_, err := inflate(bytes.NewReader(nil), 1<<63)
+	assert.Equal(t, err != nil, true)
+
+	// a delta from a 1 byte base claiming a 2^62 byte result
+	delta := []byte{0x01, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40, 0x01, 'x'}
+	_, err = applyDelta([]byte("a"), delta)
+	assert.Equal(t, err != nil, true)
+
+	// a delta which inserts more than the result size it gives
+	_, err = applyDelta([]byte("a"), []byte{0x01, 0x01, 0x02, 'x', 'y'})
+	assert.Equal(t, err != nil, true)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_gitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	hash := strings.Repeat("ab", 20)
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	_, _ = writer.Write([]byte("blob 99999999999999999\x00hello"))
+	_ = writer.Close()
+	testutil.Write(t, dir, filepath.Join(hash[:2], hash[2:]), compressed.String())
+
+	store := &objectStore{dir: dir, packs: make(map[string]*pack)}
+	_, err = store.readLoose(hash)
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,195 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// objectStore reads objects straight from the objects directory, either loose or from packs, so that reading a
+// recovered repository does not need git to be installed
+type objectStore struct {
+	dir   string
+	packs map[string]*pack
+}
+
+func openObjectStore(dir string) (*objectStore, error) {
+	s := &objectStore{
+		dir:   dir,
+		packs: make(map[string]*pack),
+	}
+	if err := s.loadPacks(); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadPacks opens any pack which has an index and is not yet open, as packs may be added while the
+// repository is being read, e.g. by a refresh
+func (s *objectStore) loadPacks() error {
+	indexes, err := filepath.Glob(filepath.Join(s.dir, "pack", "pack-*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if _, ok := s.packs[index]; ok {
+			continue
+		}
+		p, err := openPack(strings.TrimSuffix(index, ".idx"))
+		if os.IsNotExist(err) {
+			// the index was retrieved but the pack itself was not
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to open %s: %w", filepath.Base(index), err)
+		}
+		s.packs[index] = p
+	}
+	return nil
+}
+
+func (s *objectStore) close() {
+	for _, p := range s.packs {
+		_ = p.close()
+	}
+}
+
+func (s *objectStore) loosePath(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
+
+// read finds an object by its full hash, first as a loose object and then in each pack
+func (s *objectStore) read(hash string) (*Object, error) {
+
+	object, err := s.readLoose(hash)
+	if err == nil || !os.IsNotExist(err) {
+		return object, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, p := range s.packs {
+			if offset, ok := p.find(hash); ok {
+				return p.read(s, hash, offset)
+			}
+		}
+		if err := s.loadPacks(); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrMissingObject, hash)
+}
+
+// readLoose reads a zlib compressed object file, which starts with a header such as "blob 12\x00"
+func (s *objectStore) readLoose(hash string) (*Object, error) {
+
+	compressed, err := ioutil.ReadFile(s.loosePath(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("object %s is corrupt: %w", hash, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	// the header is read first, so that the size it gives is checked before the object is read
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.ReadSlice(0)
+	if err != nil {
+		return nil, fmt.Errorf("object %s is corrupt: no header", hash)
+	}
+	// the slice is only valid until the next read, so it is copied
+	headerText := string(header[:len(header)-1])
+	fields := strings.Fields(headerText)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("object %s is corrupt: malformed header %q", hash, headerText)
+	}
+	size, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("object %s is corrupt: malformed header %q", hash, headerText)
+	}
+	if size > maxObjectSize {
+		return nil, fmt.Errorf("object %s is too large: %d bytes", hash, size)
+	}
+	data, err := readExactly(buffered, size)
+	if err != nil {
+		return nil, fmt.Errorf("object %s is corrupt: size does not match header %q", hash, headerText)
+	}
+	if _, err := buffered.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("object %s is corrupt: size does not match header %q", hash, headerText)
+	}
+
+	return &Object{
+		Hash: hash,
+		Type: ObjectType(fields[0]),
+		Data: data,
+	}, nil
+}
+
+func (s *objectStore) exists(hash string) (bool, error) {
+	if _, err := os.Stat(s.loosePath(hash)); err == nil {
+		return true, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, p := range s.packs {
+			if _, ok := p.find(hash); ok {
+				return true, nil
+			}
+		}
+		if err := s.loadPacks(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// expand finds the full hash of an object named by an abbreviated hash
+func (s *objectStore) expand(prefix string) (string, error) {
+
+	found := make(map[string]bool)
+
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, file := range files {
+		if hash := prefix[:2] + file.Name(); IsHash(hash) && strings.HasPrefix(hash, prefix) {
+			found[hash] = true
+		}
+	}
+
+	if err := s.loadPacks(); err != nil {
+		return "", err
+	}
+	for _, p := range s.packs {
+		for _, hash := range p.withPrefix(prefix) {
+			found[hash] = true
+		}
+	}
+
+	var hashes []string
+	for hash := range found {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	switch len(hashes) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrMissingObject, prefix)
+	case 1:
+		return hashes[0], nil
+	}
+	return "", fmt.Errorf("short object name %s is ambiguous", prefix)
+}
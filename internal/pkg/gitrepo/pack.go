@@ -0,0 +1,406 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pack object types, as stored in the header of each object in a pack
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypes = map[byte]ObjectType{
+	packCommit: TypeCommit,
+	packTree:   TypeTree,
+	packBlob:   TypeBlob,
+	packTag:    TypeTag,
+}
+
+// maxDeltaDepth stops reading a chain of deltas which refer to each other, as git itself never writes chains
+// anywhere near this long
+const maxDeltaDepth = 1000
+
+// maxObjectSize is the largest object which is read. Sizes come from objects downloaded from the target, so they
+// are checked before anything is allocated for them.
+const maxObjectSize = 1 << 30
+
+// maxCachedBases is the total size of delta bases kept in memory, as walking history reads many objects which
+// are deltas of the same base
+const maxCachedBases = 32 * 1024 * 1024
+
+// pack reads objects from a pack file, using its index to find the offset of each object
+type pack struct {
+	name    string
+	file    *os.File
+	index   []byte
+	version int
+	count   int
+	bases   map[int64]*Object
+	cached  int
+}
+
+// openPack opens a pack and its index, where path is the path of both without their extensions
+func openPack(path string) (*pack, error) {
+
+	index, err := ioutil.ReadFile(path + ".idx")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pack{
+		name:    filepath.Base(path),
+		index:   index,
+		version: 1,
+		bases:   make(map[int64]*Object),
+	}
+
+	// version 1 indexes have no header, and start with the fan-out table
+	if len(index) >= 8 && bytes.Equal(index[:4], []byte{0xff, 't', 'O', 'c'}) {
+		if version := binary.BigEndian.Uint32(index[4:8]); version != 2 {
+			return nil, fmt.Errorf("unsupported pack index version %d", version)
+		}
+		p.version = 2
+	}
+
+	if len(index) < p.fanout()+1024 {
+		return nil, fmt.Errorf("pack index is truncated")
+	}
+	p.count = int(binary.BigEndian.Uint32(index[p.fanout()+1020:]))
+	size := 1024 + p.count*24 + 40
+	if p.version == 2 {
+		size = 8 + 1024 + p.count*28 + 40
+	}
+	if len(index) < size {
+		return nil, fmt.Errorf("pack index is truncated")
+	}
+
+	file, err := os.Open(path + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header[:4], []byte("PACK")) {
+		_ = file.Close()
+		return nil, fmt.Errorf("pack is corrupt: bad header")
+	}
+	p.file = file
+
+	return p, nil
+}
+
+func (p *pack) close() error {
+	return p.file.Close()
+}
+
+func (p *pack) fanout() int {
+	if p.version == 2 {
+		return 8
+	}
+	return 0
+}
+
+// bucket returns the range of index entries whose hashes start with the given byte
+func (p *pack) bucket(first byte) (int, int) {
+	start := 0
+	if first > 0 {
+		start = int(binary.BigEndian.Uint32(p.index[p.fanout()+int(first-1)*4:]))
+	}
+	end := int(binary.BigEndian.Uint32(p.index[p.fanout()+int(first)*4:]))
+	if end > p.count || start > end {
+		return 0, 0
+	}
+	return start, end
+}
+
+func (p *pack) hashAt(i int) []byte {
+	if p.version == 2 {
+		return p.index[1032+i*20 : 1032+i*20+20]
+	}
+	return p.index[1024+i*24+4 : 1024+i*24+24]
+}
+
+func (p *pack) offsetAt(i int) int64 {
+	if p.version == 1 {
+		return int64(binary.BigEndian.Uint32(p.index[1024+i*24:]))
+	}
+	offset := binary.BigEndian.Uint32(p.index[1032+p.count*24+i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset)
+	}
+	// offsets beyond 2GB are stored in a separate table of 64 bit offsets
+	large := 1032 + p.count*28 + int(offset&0x7fffffff)*8
+	if large+8 > len(p.index) {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(p.index[large:]))
+}
+
+// find returns the offset of an object in the pack
+func (p *pack) find(hash string) (int64, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 20 {
+		return 0, false
+	}
+	start, end := p.bucket(raw[0])
+	i := start + sort.Search(end-start, func(i int) bool {
+		return bytes.Compare(p.hashAt(start+i), raw) >= 0
+	})
+	if i >= end || !bytes.Equal(p.hashAt(i), raw) {
+		return 0, false
+	}
+	offset := p.offsetAt(i)
+	return offset, offset >= 0
+}
+
+// withPrefix lists the hashes of objects in the pack which start with prefix
+func (p *pack) withPrefix(prefix string) []string {
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+	var hashes []string
+	start, end := p.bucket(first[0])
+	for i := start; i < end; i++ {
+		if hash := hex.EncodeToString(p.hashAt(i)); strings.HasPrefix(hash, prefix) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+func (p *pack) read(s *objectStore, hash string, offset int64) (*Object, error) {
+	object, err := p.object(s, offset, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", hash, p.name, err)
+	}
+	return &Object{
+		Hash: hash,
+		Type: object.Type,
+		Data: object.Data,
+	}, nil
+}
+
+// object reads the object at offset, applying it to its base if it is a delta
+func (p *pack) object(s *objectStore, offset int64, depth int) (*Object, error) {
+
+	if depth > maxDeltaDepth {
+		return nil, fmt.Errorf("delta chain is too long")
+	}
+	if base, ok := p.bases[offset]; ok {
+		return base, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	// the header holds the type and the size of the object, in as many bytes as the size needs
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	kind := (c >> 4) & 7
+	size := uint64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var base *Object
+	switch kind {
+	case packCommit, packTree, packBlob, packTag:
+		data, err := inflate(r, size)
+		if err != nil {
+			return nil, err
+		}
+		object := &Object{Type: packTypes[kind], Data: data}
+		if depth > 0 {
+			p.remember(offset, object)
+		}
+		return object, nil
+	case packOfsDelta:
+		// the base is an earlier object in the same pack, at a distance encoded with an offset added to each
+		// extra byte so that every distance has a single encoding
+		if c, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		if distance <= 0 || distance > offset {
+			return nil, fmt.Errorf("delta at offset %d has an invalid base", offset)
+		}
+		if base, err = p.object(s, offset-distance, depth+1); err != nil {
+			return nil, err
+		}
+	case packRefDelta:
+		// the base is named by its hash, and may be in this pack, in another or a loose object
+		raw := make([]byte, 20)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, err
+		}
+		baseHash := hex.EncodeToString(raw)
+		if baseOffset, ok := p.find(baseHash); ok {
+			base, err = p.object(s, baseOffset, depth+1)
+		} else {
+			base, err = s.read(baseHash)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown object type %d at offset %d", kind, offset)
+	}
+
+	delta, err := inflate(r, size)
+	if err != nil {
+		return nil, err
+	}
+	data, err := applyDelta(base.Data, delta)
+	if err != nil {
+		return nil, fmt.Errorf("delta at offset %d: %w", offset, err)
+	}
+	object := &Object{Type: base.Type, Data: data}
+	if depth > 0 {
+		p.remember(offset, object)
+	}
+	return object, nil
+}
+
+func (p *pack) remember(offset int64, object *Object) {
+	if p.cached+len(object.Data) > maxCachedBases {
+		p.bases = make(map[int64]*Object)
+		p.cached = 0
+	}
+	p.bases[offset] = object
+	p.cached += len(object.Data)
+}
+
+// inflate decompresses an object of the given size, growing the buffer as data arrives rather than trusting the
+// size up front
+func inflate(r io.Reader, size uint64) ([]byte, error) {
+	if size > maxObjectSize {
+		return nil, fmt.Errorf("object size %d is too large", size)
+	}
+	reader, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return readExactly(reader, size)
+}
+
+func readExactly(r io.Reader, size uint64) ([]byte, error) {
+	var data bytes.Buffer
+	if n, err := io.Copy(&data, io.LimitReader(r, int64(size))); err != nil {
+		return nil, err
+	} else if uint64(n) != size {
+		return nil, fmt.Errorf("object is truncated: %w", io.ErrUnexpectedEOF)
+	}
+	return data.Bytes(), nil
+}
+
+// applyDelta rebuilds an object from its base and a delta, which is a list of instructions to either copy a
+// range of the base or insert new data
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+
+	sourceSize, delta, ok := deltaSize(delta)
+	if !ok || sourceSize != uint64(len(base)) {
+		return nil, fmt.Errorf("base size does not match")
+	}
+	targetSize, delta, ok := deltaSize(delta)
+	if !ok {
+		return nil, fmt.Errorf("delta is truncated")
+	}
+	if targetSize > maxObjectSize {
+		return nil, fmt.Errorf("delta result size %d is too large", targetSize)
+	}
+
+	// a result is usually about the size of its base, so the capacity is not taken from the delta alone, and each
+	// instruction is checked against the target size before it is applied
+	capacity := targetSize
+	if limit := uint64(len(base) + len(delta)); capacity > limit {
+		capacity = limit
+	}
+	result := make([]byte, 0, capacity)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			// the low bits say which bytes of the offset and size follow
+			var offset, size uint64
+			for i := uint(0); i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("delta is truncated")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("delta copies beyond its base")
+			}
+			if uint64(len(result))+size > targetSize {
+				return nil, fmt.Errorf("result size does not match")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case cmd != 0:
+			if int(cmd) > len(delta) {
+				return nil, fmt.Errorf("delta is truncated")
+			}
+			if uint64(len(result))+uint64(cmd) > targetSize {
+				return nil, fmt.Errorf("result size does not match")
+			}
+			result = append(result, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, fmt.Errorf("delta has an invalid instruction")
+		}
+	}
+
+	if uint64(len(result)) != targetSize {
+		return nil, fmt.Errorf("result size does not match")
+	}
+	return result, nil
+}
+
+func deltaSize(delta []byte) (uint64, []byte, bool) {
+	var size uint64
+	for i, shift := 0, uint(0); i < len(delta); i, shift = i+1, shift+7 {
+		size |= uint64(delta[i]&0x7f) << shift
+		if delta[i]&0x80 == 0 {
+			return size, delta[i+1:], true
+		}
+	}
+	return 0, nil, false
+}
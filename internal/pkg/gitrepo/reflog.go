@@ -0,0 +1,83 @@
+package gitrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const zeroHash = "0000000000000000000000000000000000000000"
+
+// ReflogEntry records a ref moving from Old to New. Either may be empty, e.g. when a branch is created.
+type ReflogEntry struct {
+	Ref     string
+	Old     string
+	New     string
+	Who     Signature
+	Message string
+}
+
+// Reflogs reads every reflog below .git/logs. Reflogs often name commits which are no longer reachable from
+// any ref, such as those dropped by a reset or an amend.
+func (r *Repository) Reflogs() ([]ReflogEntry, error) {
+
+	root := filepath.Join(r.gitDir, "logs")
+	var entries []ReflogEntry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, parseReflog(filepath.ToSlash(rel), string(content))...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Ref < entries[j].Ref })
+	return entries, nil
+}
+
+// parseReflog reads lines of "<old> <new> <signature>\t<message>"
+func parseReflog(ref string, content string) []ReflogEntry {
+	var entries []ReflogEntry
+	for _, line := range strings.Split(content, "\n") {
+		message := ""
+		if tab := strings.Index(line, "\t"); tab >= 0 {
+			line, message = line[:tab], line[tab+1:]
+		}
+		if len(line) < 82 || !IsHash(line[:40]) || !IsHash(line[41:81]) {
+			continue
+		}
+		entry := ReflogEntry{
+			Ref:     ref,
+			Old:     line[:40],
+			New:     line[41:81],
+			Who:     parseSignature(line[82:]),
+			Message: message,
+		}
+		if entry.Old == zeroHash {
+			entry.Old = ""
+		}
+		if entry.New == zeroHash {
+			entry.New = ""
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,188 @@
+package gitrepo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+var ErrMissingObject = fmt.Errorf("object is missing")
+var ErrNoRepository = fmt.Errorf("no git repository found")
+var ErrPathNotFound = fmt.Errorf("path does not exist")
+
+type ObjectType string
+
+const (
+	TypeCommit ObjectType = "commit"
+	TypeTree   ObjectType = "tree"
+	TypeBlob   ObjectType = "blob"
+	TypeTag    ObjectType = "tag"
+)
+
+type Object struct {
+	Hash string
+	Type ObjectType
+	Data []byte
+}
+
+// Repository reads objects from a recovered repository. Recovered repositories are usually incomplete,
+// so every read may fail with ErrMissingObject and callers are expected to carry on without the object.
+type Repository struct {
+	gitDir  string
+	mu      sync.Mutex
+	objects *objectStore
+}
+
+// Open starts reading the repository in dir, which may be either the .git directory or the directory containing it
+func Open(dir string) (*Repository, error) {
+
+	gitDir := filepath.Join(dir, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		gitDir = dir
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "objects")); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoRepository, dir)
+	}
+
+	objects, err := openObjectStore(filepath.Join(gitDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		gitDir:  gitDir,
+		objects: objects,
+	}, nil
+}
+
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.objects.close()
+	return nil
+}
+
+// GitDir returns the path of the .git directory
+func (r *Repository) GitDir() string {
+	return r.gitDir
+}
+
+// Object reads a single object by its full hash
+func (r *Repository) Object(hash string) (*Object, error) {
+
+	if !IsHash(hash) {
+		return nil, fmt.Errorf("invalid object hash %q", hash)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.objects.read(hash)
+}
+
+// Exists reports whether an object is present, without reading it
+func (r *Repository) Exists(hash string) (bool, error) {
+
+	if !IsHash(hash) {
+		return false, fmt.Errorf("invalid object hash %q", hash)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.objects.exists(hash)
+}
+
+// expand finds the full hash of an object named by an abbreviated hash
+func (r *Repository) expand(prefix string) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.objects.expand(prefix)
+}
+
+// Refs lists the refs recorded in the repository, including loose and packed refs
+func (r *Repository) Refs() ([]gitjacker.Ref, error) {
+	return gitjacker.ReadLocalRefs(r.gitDir)
+}
+
+func (r *Repository) typedObject(hash string, expected ObjectType) (*Object, error) {
+	object, err := r.Object(hash)
+	if err != nil {
+		return nil, err
+	}
+	if object.Type != expected {
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, object.Type, expected)
+	}
+	return object, nil
+}
+
+// Blob reads the content of a file
+func (r *Repository) Blob(hash string) ([]byte, error) {
+	object, err := r.typedObject(hash, TypeBlob)
+	if err != nil {
+		return nil, err
+	}
+	return object.Data, nil
+}
+
+// Head resolves HEAD to a commit hash
+func (r *Repository) Head() (string, error) {
+	return r.Resolve("HEAD")
+}
+
+// Resolve follows a ref, such as HEAD or refs/heads/master, to the hash it points at
+func (r *Repository) Resolve(ref string) (string, error) {
+
+	for depth := 0; depth < 10; depth++ {
+		if IsHash(ref) {
+			return ref, nil
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(r.gitDir, filepath.FromSlash(filepath.Clean("/"+ref))))
+		if err != nil {
+			hash, ok := r.packedRef(ref)
+			if !ok {
+				return "", fmt.Errorf("cannot resolve %s", ref)
+			}
+			return hash, nil
+		}
+
+		value := strings.TrimSpace(string(content))
+		ref = strings.TrimPrefix(value, "ref: ")
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic refs")
+}
+
+func (r *Repository) packedRef(ref string) (string, bool) {
+	content, err := ioutil.ReadFile(filepath.Join(r.gitDir, "packed-refs"))
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == ref && IsHash(fields[0]) {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+func IsHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResolveRevision finds the commit named by a revision such as HEAD, master, v1.0 or an abbreviated hash,
+// optionally followed by ~n and ^n to step back through parents. Annotated tags are peeled to their target.
+func (r *Repository) ResolveRevision(rev string) (string, error) {
+
+	name, parents, err := splitRevision(rev)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := r.resolveName(name)
+	if err != nil {
+		return "", err
+	}
+	if hash, err = r.peel(hash); err != nil {
+		return "", err
+	}
+
+	for _, parent := range parents {
+		if parent < 0 {
+			continue
+		}
+		commit, err := r.Commit(hash)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve %s: %w", rev, err)
+		}
+		if parent >= len(commit.Parents) {
+			return "", fmt.Errorf("cannot resolve %s: commit %s has %d parents", rev, hash, len(commit.Parents))
+		}
+		hash = commit.Parents[parent]
+	}
+	return hash, nil
+}
+
+// splitRevision separates a name from its ~n and ^n suffixes, returning the index of the parent to follow for
+// each step back, or -1 where ^0 refers to the commit itself
+func splitRevision(rev string) (string, []int, error) {
+
+	end := strings.IndexAny(rev, "~^")
+	if end < 0 {
+		return rev, nil, nil
+	}
+	name, suffix := rev[:end], rev[end:]
+
+	var parents []int
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		count := 1
+		if digits > 0 {
+			n, err := strconv.Atoi(suffix[:digits])
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid revision %q", rev)
+			}
+			count = n
+			suffix = suffix[digits:]
+		}
+		switch op {
+		case '~':
+			for i := 0; i < count; i++ {
+				parents = append(parents, 0)
+			}
+		case '^':
+			parents = append(parents, count-1)
+		default:
+			return "", nil, fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+	return name, parents, nil
+}
+
+// resolveName looks for a ref in the same order as git, then falls back to an abbreviated hash
+func (r *Repository) resolveName(name string) (string, error) {
+
+	if name == "" || name == "@" {
+		name = "HEAD"
+	}
+	if IsHash(name) {
+		return name, nil
+	}
+
+	var candidates []string
+	if strings.ToUpper(name) == name || strings.HasPrefix(name, "refs/") {
+		candidates = append(candidates, name)
+	}
+	candidates = append(candidates,
+		"refs/"+name,
+		"refs/tags/"+name,
+		"refs/heads/"+name,
+		"refs/remotes/"+name,
+		"refs/remotes/"+name+"/HEAD",
+	)
+	for _, candidate := range candidates {
+		if hash, err := r.Resolve(candidate); err == nil {
+			return hash, nil
+		}
+	}
+
+	if len(name) >= 4 && len(name) < 40 && strings.Trim(name, "0123456789abcdef") == "" {
+		return r.expand(name)
+	}
+
+	return "", fmt.Errorf("unknown revision %q", name)
+}
+
+// peel follows annotated tags to the object they point at. A missing tag cannot be followed, so it is returned
+// as it is and reported as missing when read.
+func (r *Repository) peel(hash string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		object, err := r.Object(hash)
+		if errors.Is(err, ErrMissingObject) {
+			return hash, nil
+		} else if err != nil {
+			return "", err
+		}
+		if object.Type != TypeTag {
+			return hash, nil
+		}
+		target := ""
+		for _, line := range strings.Split(string(object.Data), "\n") {
+			if strings.HasPrefix(line, "object ") {
+				target = strings.TrimPrefix(line, "object ")
+				break
+			}
+		}
+		if !IsHash(target) {
+			return "", fmt.Errorf("tag %s is malformed", hash)
+		}
+		hash = target
+	}
+	return "", fmt.Errorf("too many levels of tags")
+}
+
+// MissingParents lists the parents of a commit which could not be recovered, where history is cut short
+func (r *Repository) MissingParents(commit *Commit) ([]string, error) {
+	var missing []string
+	for _, parent := range commit.Parents {
+		exists, err := r.Exists(parent)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, parent)
+		}
+	}
+	return missing, nil
+}
+
+// Tips lists every commit named by HEAD, a ref or a reflog entry, with tags peeled. Walking history from all of
+// them reaches every recoverable commit, including those left behind by a reset or only reachable around a gap.
+func (r *Repository) Tips() ([]string, error) {
+
+	var names []string
+	if head, err := r.Head(); err == nil {
+		names = append(names, head)
+	}
+	refs, err := r.Refs()
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		names = append(names, ref.Hash)
+	}
+	reflogs, err := r.Reflogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range reflogs {
+		names = append(names, entry.New, entry.Old)
+	}
+
+	seen := make(map[string]bool)
+	var tips []string
+	for _, name := range names {
+		if !IsHash(name) {
+			continue
+		}
+		hash, err := r.peel(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			tips = append(tips, hash)
+		}
+	}
+	return tips, nil
+}
@@ -0,0 +1,69 @@
+package gitrepo
+
+import (
+	"errors"
+	"strings"
+)
+
+// Tag is an annotated tag
+type Tag struct {
+	Hash string
+	// Name is the name of the ref pointing at the tag, e.g. refs/tags/v1.0.0
+	Name    string
+	Object  string
+	Tagger  Signature
+	Message string
+}
+
+// Tags reads the annotated tags named by refs. Lightweight tags, which have no message, and tags which could not
+// be recovered are left out.
+func (r *Repository) Tags() ([]*Tag, error) {
+	refs, err := r.Refs()
+	if err != nil {
+		return nil, err
+	}
+	var tags []*Tag
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref.Name, "refs/tags/") {
+			continue
+		}
+		object, err := r.Object(ref.Hash)
+		if errors.Is(err, ErrMissingObject) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if object.Type != TypeTag {
+			continue
+		}
+		tag := parseTag(ref.Hash, object.Data)
+		tag.Name = ref.Name
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func parseTag(hash string, data []byte) *Tag {
+	tag := &Tag{Hash: hash}
+	content := string(data)
+
+	headers := content
+	if i := strings.Index(content, "\n\n"); i >= 0 {
+		headers = content[:i]
+		tag.Message = content[i+2:]
+	}
+
+	for _, line := range strings.Split(headers, "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "object":
+			tag.Object = parts[1]
+		case "tagger":
+			tag.Tagger = parseSignature(parts[1])
+		}
+	}
+	return tag
+}
@@ -0,0 +1,123 @@
+package gitrepo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type TreeEntry struct {
+	Name string
+	Mode string
+	Hash string
+}
+
+func (e TreeEntry) IsDir() bool {
+	return e.Mode == "40000"
+}
+
+// IsSubmodule reports whether the entry is a commit in another repository
+func (e TreeEntry) IsSubmodule() bool {
+	return e.Mode == "160000"
+}
+
+func (r *Repository) Tree(hash string) ([]TreeEntry, error) {
+	object, err := r.typedObject(hash, TypeTree)
+	if err != nil {
+		return nil, err
+	}
+	return parseTree(hash, object.Data)
+}
+
+// parseTree reads the binary tree format, a sequence of "<mode> <name>\0<20 byte hash>" entries
+func parseTree(hash string, data []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		null := bytes.IndexByte(data, 0)
+		if space < 0 || null < space || len(data) < null+21 {
+			return entries, fmt.Errorf("tree %s is malformed", hash)
+		}
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:space]),
+			Name: string(data[space+1 : null]),
+			Hash: hex.EncodeToString(data[null+1 : null+21]),
+		})
+		data = data[null+21:]
+	}
+	return entries, nil
+}
+
+// File is a file within a tree, where Path is relative to the root of the tree
+type File struct {
+	Path string
+	Mode string
+	Hash string
+}
+
+// Files lists every file below a tree, sorted by path. Directories which could not be read are listed
+// separately, as their contents are unknown.
+func (r *Repository) Files(tree string) ([]File, []string, error) {
+	var files []File
+	var missing []string
+	if err := r.walk(tree, "", &files, &missing); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	sort.Strings(missing)
+	return files, missing, nil
+}
+
+func (r *Repository) walk(tree string, dir string, files *[]File, missing *[]string) error {
+	entries, err := r.Tree(tree)
+	if errors.Is(err, ErrMissingObject) {
+		*missing = append(*missing, dir)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name)
+		if entry.IsDir() {
+			if err := r.walk(entry.Hash, entryPath, files, missing); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, File{Path: entryPath, Mode: entry.Mode, Hash: entry.Hash})
+	}
+	return nil
+}
+
+// Lookup finds the entry at a path within a tree, where an empty path is the tree itself. ErrMissingObject
+// is returned when a directory on the way could not be recovered, and ErrPathNotFound when nothing is there.
+func (r *Repository) Lookup(tree string, filePath string) (TreeEntry, error) {
+	entry := TreeEntry{Mode: "40000", Hash: tree}
+	for _, name := range strings.Split(path.Clean("/"+filePath), "/") {
+		if name == "" {
+			continue
+		}
+		if !entry.IsDir() {
+			return TreeEntry{}, fmt.Errorf("%w: %s", ErrPathNotFound, filePath)
+		}
+		entries, err := r.Tree(entry.Hash)
+		if err != nil {
+			return TreeEntry{}, err
+		}
+		found := false
+		for _, child := range entries {
+			if child.Name == name {
+				entry, found = child, true
+				break
+			}
+		}
+		if !found {
+			return TreeEntry{}, fmt.Errorf("%w: %s", ErrPathNotFound, filePath)
+		}
+	}
+	return entry, nil
+}
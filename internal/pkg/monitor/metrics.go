@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(state TargetState) float64
+}
+
+var metrics = []metric{
+	{"gitjacker_target_exposed", "Whether the target currently exposes its .git directory.", "gauge", func(s TargetState) float64 {
+		return boolValue(s.Exposure == ExposureExposed)
+	}},
+	{"gitjacker_target_objects_found", "Objects retrieved from the target during the last successful check.", "gauge", func(s TargetState) float64 {
+		return float64(s.FoundObjects)
+	}},
+	{"gitjacker_target_objects_missing", "Objects which could not be retrieved during the last successful check.", "gauge", func(s TargetState) float64 {
+		return float64(s.MissingObjects)
+	}},
+	{"gitjacker_target_refs", "Refs discovered during the last successful check.", "gauge", func(s TargetState) float64 {
+		return float64(s.Refs)
+	}},
+	{"gitjacker_target_last_check_timestamp_seconds", "Time of the last check of the target.", "gauge", func(s TargetState) float64 {
+		return float64(s.LastChecked.Unix())
+	}},
+	{"gitjacker_target_checks_total", "Checks performed against the target.", "counter", func(s TargetState) float64 {
+		return float64(s.Checks)
+	}},
+	{"gitjacker_target_check_errors_total", "Checks against the target which failed.", "counter", func(s TargetState) float64 {
+		return float64(s.Errors)
+	}},
+	{"gitjacker_target_new_commits_total", "New commits seen on the target since monitoring began.", "counter", func(s TargetState) float64 {
+		return float64(s.NewCommits)
+	}},
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WriteMetrics writes per-target status in the Prometheus text exposition format
+func (m *Monitor) WriteMetrics(w io.Writer) error {
+	states := m.States()
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind); err != nil {
+			return err
+		}
+		for _, state := range states {
+			if _, err := fmt.Fprintf(w, "%s{target=\"%s\"} %g\n", metric.name, escapeLabel(state.Target), metric.value(state)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/metrics" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = m.WriteMetrics(w)
+}
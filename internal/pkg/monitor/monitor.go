@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/sirupsen/logrus"
+)
+
+type Exposure string
+
+const (
+	ExposureUnknown    Exposure = "unknown"
+	ExposureExposed    Exposure = "exposed"
+	ExposureNotExposed Exposure = "not-exposed"
+	ExposureError      Exposure = "error"
+)
+
+type TransitionKind string
+
+const (
+	TransitionNewlyExposed TransitionKind = "newly-exposed"
+	TransitionFixed        TransitionKind = "fixed"
+	TransitionNewCommits   TransitionKind = "new-commits"
+)
+
+// Transition is a change in a target's state between two checks
+type Transition struct {
+	Target     string
+	Kind       TransitionKind
+	Time       time.Time
+	NewCommits []string
+	Summary    *gitjacker.Summary
+}
+
+// TargetState is everything recorded about a target across checks
+type TargetState struct {
+	Target         string    `json:"target"`
+	Exposure       Exposure  `json:"exposure"`
+	Status         string    `json:"status"`
+	FoundObjects   int       `json:"found_objects"`
+	MissingObjects int       `json:"missing_objects"`
+	Refs           int       `json:"refs"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastChecked    time.Time `json:"last_checked"`
+	LastChanged    time.Time `json:"last_changed"`
+	Checks         int       `json:"checks"`
+	Errors         int       `json:"errors"`
+	NewCommits     int       `json:"new_commits"`
+}
+
+// CheckFunc retrieves a target into a directory, returning gitjacker.ErrNotVulnerable when it is not exposed
+type CheckFunc func(ctx context.Context, target *url.URL, dir string) (*gitjacker.Summary, error)
+
+type Monitor struct {
+	targets      []*url.URL
+	dataDir      string
+	interval     time.Duration
+	check        CheckFunc
+	options      []gitjacker.Option
+	mu           sync.Mutex
+	states       map[string]*TargetState
+	OnTransition func(Transition)
+	// OnCheck is called after every check which found the target exposed
+	OnCheck func(target *url.URL, started time.Time, summary *gitjacker.Summary)
+}
+
+// New creates a monitor which keeps state in dataDir, passing opts to every retrieval
+func New(targets []*url.URL, dataDir string, interval time.Duration, opts ...gitjacker.Option) (*Monitor, error) {
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Monitor{
+		targets:  targets,
+		dataDir:  dataDir,
+		interval: interval,
+		options:  opts,
+		states:   make(map[string]*TargetState),
+	}
+	m.check = m.retrieve
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// retrieve refreshes a previous retrieval where possible, so only new objects are fetched
+func (m *Monitor) retrieve(ctx context.Context, target *url.URL, dir string) (*gitjacker.Summary, error) {
+	if retriever, err := gitjacker.NewRefresh(dir, m.options...); err == nil {
+		return retriever.Run(ctx)
+	}
+	return gitjacker.New(target, dir, m.options...).Run(ctx)
+}
+
+// ReadTargets parses a list of target URLs, one per line, ignoring blank lines and # comments
+func ReadTargets(path string) ([]*url.URL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var targets []*url.URL
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "/.git/"), "/.git")
+		u, err := url.Parse(line)
+		if err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("invalid target url %q", line)
+		}
+		targets = append(targets, u)
+	}
+	return targets, scanner.Err()
+}
+
+func (m *Monitor) statePath() string {
+	return filepath.Join(m.dataDir, "monitor.json")
+}
+
+func (m *Monitor) load() error {
+	data, err := ioutil.ReadFile(m.statePath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var states []*TargetState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("failed to parse monitor state: %w", err)
+	}
+	for _, state := range states {
+		m.states[state.Target] = state
+	}
+	return nil
+}
+
+func (m *Monitor) save() error {
+	data, err := json.MarshalIndent(m.States(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.statePath(), data, 0640)
+}
+
+// States returns a copy of the current state of every monitored target
+func (m *Monitor) States() []TargetState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var states []TargetState
+	for _, target := range m.targets {
+		if state, ok := m.states[target.String()]; ok {
+			states = append(states, *state)
+		}
+	}
+	return states
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *Monitor) targetDir(target *url.URL) string {
+	return filepath.Join(m.dataDir, "targets", strings.Trim(unsafeChars.ReplaceAllString(target.Host+target.Path, "_"), "_"))
+}
+
+// Run checks every target immediately and then once per interval, until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) CheckAll(ctx context.Context) {
+	for _, target := range m.targets {
+		if ctx.Err() != nil {
+			return
+		}
+		m.Check(ctx, target)
+	}
+	if err := m.save(); err != nil {
+		logrus.Debugf("Failed to save monitor state: %s", err)
+	}
+}
+
+func (m *Monitor) Check(ctx context.Context, target *url.URL) {
+
+	started := time.Now()
+	summary, err := m.check(ctx, target, m.targetDir(target))
+
+	m.mu.Lock()
+	state, ok := m.states[target.String()]
+	if !ok {
+		state = &TargetState{
+			Target:   target.String(),
+			Exposure: ExposureUnknown,
+		}
+		m.states[target.String()] = state
+	}
+
+	now := time.Now().UTC()
+	previous := state.Exposure
+	state.LastChecked = now
+	state.Checks++
+	state.LastError = ""
+
+	var transitions []Transition
+	switch {
+	case errors.Is(err, gitjacker.ErrNotVulnerable):
+		state.Exposure = ExposureNotExposed
+		state.Status = ""
+		if previous == ExposureExposed {
+			transitions = append(transitions, Transition{Target: state.Target, Kind: TransitionFixed, Time: now})
+		}
+	case err != nil:
+		// leave the exposure unchanged, as a failed check says nothing about the target
+		state.Errors++
+		state.LastError = err.Error()
+		logrus.Debugf("Check of %s failed: %s", state.Target, err)
+	default:
+		state.Exposure = ExposureExposed
+		state.Status = summary.Status.String()
+		state.FoundObjects = len(summary.FoundObjects)
+		state.MissingObjects = len(summary.MissingObjects)
+		state.Refs = len(summary.Refs)
+		if previous != ExposureExposed {
+			transitions = append(transitions, Transition{Target: state.Target, Kind: TransitionNewlyExposed, Time: now, Summary: summary})
+		} else if len(summary.NewCommits) > 0 {
+			state.NewCommits += len(summary.NewCommits)
+			transitions = append(transitions, Transition{Target: state.Target, Kind: TransitionNewCommits, Time: now, NewCommits: summary.NewCommits, Summary: summary})
+		}
+	}
+
+	if len(transitions) > 0 {
+		state.LastChanged = now
+	}
+	m.mu.Unlock()
+
+	if err == nil && m.OnCheck != nil {
+		m.OnCheck(target, started, summary)
+	}
+
+	for _, transition := range transitions {
+		if m.OnTransition != nil {
+			m.OnTransition(transition)
+		}
+	}
+}
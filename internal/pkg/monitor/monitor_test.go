@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestTransitions(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	target, err := url.Parse("http://victim.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New([]*url.URL{target}, dataDir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []struct {
+		summary *gitjacker.Summary
+		err     error
+	}{
+		{err: gitjacker.ErrNotVulnerable},
+		{summary: &gitjacker.Summary{Status: gitjacker.StatusSuccess, FoundObjects: []string{"a", "b"}}},
+		{summary: &gitjacker.Summary{Status: gitjacker.StatusSuccess, FoundObjects: []string{"a", "b", "c"}, NewCommits: []string{"c"}}},
+		{err: context.DeadlineExceeded},
+		{err: gitjacker.ErrNotVulnerable},
+	}
+
+	m.check = func(_ context.Context, _ *url.URL, _ string) (*gitjacker.Summary, error) {
+		result := results[0]
+		results = results[1:]
+		return result.summary, result.err
+	}
+
+	var kinds []TransitionKind
+	m.OnTransition = func(transition Transition) {
+		kinds = append(kinds, transition.Kind)
+	}
+
+	for i := 0; i < 3; i++ {
+		m.CheckAll(context.Background())
+	}
+
+	assert.Equal(t, kinds, []TransitionKind{TransitionNewlyExposed, TransitionNewCommits})
+
+	metrics := &bytes.Buffer{}
+	if err := m.WriteMetrics(metrics); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(metrics.String(), `gitjacker_target_exposed{target="http://victim.example/"} 1`), true)
+	assert.Equal(t, strings.Contains(metrics.String(), `gitjacker_target_objects_found{target="http://victim.example/"} 3`), true)
+	assert.Equal(t, strings.Contains(metrics.String(), `gitjacker_target_new_commits_total{target="http://victim.example/"} 1`), true)
+
+	// a failed check must not be mistaken for a fix
+	m.CheckAll(context.Background())
+	assert.Equal(t, m.States()[0].Exposure, ExposureExposed)
+	assert.Equal(t, m.States()[0].Errors, 1)
+
+	m.CheckAll(context.Background())
+	assert.Equal(t, kinds[len(kinds)-1], TransitionFixed)
+
+	// state persists across restarts
+	restarted, err := New([]*url.URL{target}, dataDir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, restarted.States()[0].Exposure, ExposureNotExposed)
+	assert.Equal(t, restarted.States()[0].Checks, 5)
+}
+
+func TestReadTargets(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "gjtest_targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.WriteString("# estate\nhttps://a.example/.git/\n\nhttps://b.example/app/\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	targets, err := ReadTargets(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(targets), 2)
+	assert.Equal(t, targets[0].String(), "https://a.example")
+	assert.Equal(t, targets[1].String(), "https://b.example/app/")
+}
@@ -0,0 +1,194 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// Snapshot is the state of a retrieval at a point in time
+type Snapshot struct {
+	Phase    string
+	Found    int
+	Missing  int
+	Queued   int
+	Bytes    int64
+	Requests int
+	Elapsed  time.Duration
+}
+
+// Rate returns the average number of requests per second
+func (s Snapshot) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Requests) / s.Elapsed.Seconds()
+}
+
+func (s Snapshot) String() string {
+	phase := s.Phase
+	if phase == "" {
+		phase = "starting"
+	}
+	return fmt.Sprintf("%-9s  found %d  missing %d  queued %d  %s  %.1f req/s  %s",
+		phase,
+		s.Found,
+		s.Missing,
+		s.Queued,
+		formatBytes(s.Bytes),
+		s.Rate(),
+		formatElapsed(s.Elapsed),
+	)
+}
+
+// Tracker aggregates retrieval events. Its Handle method can be passed to gitjacker.WithEventHandler.
+type Tracker struct {
+	mu       sync.Mutex
+	started  time.Time
+	phase    string
+	found    int
+	missing  int
+	queued   map[string]bool
+	bytes    int64
+	requests int
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		started: time.Now(),
+		queued:  make(map[string]bool),
+	}
+}
+
+func (t *Tracker) Handle(event gitjacker.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event.Kind {
+	case gitjacker.EventPhase:
+		t.phase = event.Phase
+	case gitjacker.EventObjectQueued:
+		t.queued[event.Hash] = true
+	case gitjacker.EventObjectFound:
+		t.found++
+		delete(t.queued, event.Hash)
+	case gitjacker.EventObjectMissing:
+		t.missing++
+		delete(t.queued, event.Hash)
+	case gitjacker.EventRequest:
+		t.requests++
+		t.bytes += event.Bytes
+	}
+}
+
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Snapshot{
+		Phase:    t.phase,
+		Found:    t.found,
+		Missing:  t.missing,
+		Queued:   len(t.queued),
+		Bytes:    t.bytes,
+		Requests: t.requests,
+		Elapsed:  time.Since(t.started),
+	}
+}
+
+// Display periodically writes the progress of a tracker. On a terminal a single status line is updated in place,
+// otherwise a plain line is written at a longer interval so logs are not flooded.
+type Display struct {
+	tracker  *Tracker
+	w        io.Writer
+	terminal bool
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewDisplay(tracker *Tracker, w io.Writer, terminal bool) *Display {
+	interval := time.Second * 10
+	if terminal {
+		interval = time.Millisecond * 200
+	}
+	return &Display{
+		tracker:  tracker,
+		w:        w,
+		terminal: terminal,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// IsTerminal reports whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (d *Display) Start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.render()
+			}
+		}
+	}()
+}
+
+// Stop ends the display, clearing the status line on a terminal
+func (d *Display) Stop() {
+	close(d.stop)
+	<-d.done
+	if d.terminal {
+		_, _ = fmt.Fprint(d.w, "\x1b[2K\r")
+	}
+}
+
+func (d *Display) render() {
+	line := d.tracker.Snapshot().String()
+	if d.terminal {
+		_, _ = fmt.Fprintf(d.w, "\x1b[2K\r%s", line)
+		return
+	}
+	_, _ = fmt.Fprintf(d.w, "[%s] %s\n", time.Now().Format("15:04:05"), strings.Join(strings.Fields(line), " "))
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func formatElapsed(elapsed time.Duration) string {
+	elapsed = elapsed.Round(time.Second)
+	hours := int(elapsed.Hours())
+	minutes := int(elapsed.Minutes()) % 60
+	seconds := int(elapsed.Seconds()) % 60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
@@ -0,0 +1,60 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	for _, event := range []gitjacker.Event{
+		{Kind: gitjacker.EventPhase, Phase: gitjacker.PhaseTraversal},
+		{Kind: gitjacker.EventRequest, Path: "HEAD", Bytes: 23},
+		{Kind: gitjacker.EventRequest, Path: "objects/aa/bb"},
+		{Kind: gitjacker.EventObjectQueued, Hash: "a"},
+		{Kind: gitjacker.EventObjectQueued, Hash: "b"},
+		{Kind: gitjacker.EventObjectQueued, Hash: "c"},
+		{Kind: gitjacker.EventObjectFound, Hash: "a"},
+		{Kind: gitjacker.EventObjectMissing, Hash: "b"},
+	} {
+		tracker.Handle(event)
+	}
+
+	snapshot := tracker.Snapshot()
+	assert.Equal(t, snapshot.Phase, gitjacker.PhaseTraversal)
+	assert.Equal(t, snapshot.Found, 1)
+	assert.Equal(t, snapshot.Missing, 1)
+	assert.Equal(t, snapshot.Queued, 1)
+	assert.Equal(t, snapshot.Bytes, int64(23))
+	assert.Equal(t, snapshot.Requests, 2)
+}
+
+func TestSnapshotString(t *testing.T) {
+	snapshot := Snapshot{
+		Phase:    gitjacker.PhasePacks,
+		Found:    10,
+		Missing:  2,
+		Queued:   3,
+		Bytes:    1536,
+		Requests: 20,
+		Elapsed:  time.Second * 4,
+	}
+	assert.Equal(t, strings.Join(strings.Fields(snapshot.String()), " "), "packs found 10 missing 2 queued 3 1.5 KiB 5.0 req/s 00:04")
+}
+
+func TestPlainDisplay(t *testing.T) {
+	output := &bytes.Buffer{}
+	display := NewDisplay(NewTracker(), output, false)
+	display.render()
+	display.terminal = true
+	display.render()
+
+	lines := strings.Split(output.String(), "\n")
+	assert.Equal(t, strings.Contains(lines[0], "starting found 0"), true)
+	assert.Equal(t, strings.HasPrefix(lines[1], "\x1b[2K\r"), true)
+}
@@ -0,0 +1,193 @@
+package report
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Rule describes a kind of finding
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+	Severity    Severity
+}
+
+var (
+	RuleExposedRepository = Rule{
+		ID:          "GJ001",
+		Name:        "ExposedGitDirectory",
+		Description: "The .git directory is publicly accessible, allowing the repository to be downloaded",
+		Severity:    SeverityError,
+	}
+	RuleGithubToken = Rule{
+		ID:          "GJ002",
+		Name:        "GithubTokenInConfig",
+		Description: "A GitHub token is stored in the exposed git config",
+		Severity:    SeverityError,
+	}
+	RuleRemoteCredentials = Rule{
+		ID:          "GJ003",
+		Name:        "CredentialsInRemoteURL",
+		Description: "A remote URL in the exposed git config contains credentials",
+		Severity:    SeverityError,
+	}
+	RuleIdentityDisclosure = Rule{
+		ID:          "GJ004",
+		Name:        "IdentityDisclosure",
+		Description: "The exposed git config discloses the identity of a developer",
+		Severity:    SeverityNote,
+	}
+	RuleSecret = Rule{
+		ID:          "GJ005",
+		Name:        "SecretInRepository",
+		Description: "A secret was found in a recovered file or its history",
+		Severity:    SeverityError,
+	}
+	RuleConfigCredentials = Rule{
+		ID:          "GJ006",
+		Name:        "CredentialsInConfiguration",
+		Description: "Connection credentials were found in a recovered configuration file or its history",
+		Severity:    SeverityError,
+	}
+	RuleSensitiveFile = Rule{
+		ID:          "GJ007",
+		Name:        "SensitiveFile",
+		Description: "A sensitive file such as a private key, database dump or archive was recovered",
+		Severity:    SeverityError,
+	}
+)
+
+// sensitiveFileErrorScore is the lowest artifact score reported as an error rather than a warning, so that
+// files such as certificates and archives, which may hold nothing of interest, stand out less
+const sensitiveFileErrorScore = 70
+
+var rules = []Rule{
+	RuleExposedRepository,
+	RuleGithubToken,
+	RuleRemoteCredentials,
+	RuleIdentityDisclosure,
+	RuleSecret,
+	RuleConfigCredentials,
+	RuleSensitiveFile,
+}
+
+// Finding is a single sensitive discovery. Location is the URL or path of the file it was found in.
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Location string   `json:"location"`
+	// key distinguishes findings of the same rule at the same location, e.g. the name of a remote
+	key string
+}
+
+func newFinding(rule Rule, location string, key string, format string, args ...interface{}) Finding {
+	return Finding{
+		RuleID:   rule.ID,
+		Severity: rule.Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Location: location,
+		key:      key,
+	}
+}
+
+func findings(report *Report) []Finding {
+
+	var target string
+	if len(report.Targets) > 0 {
+		target = report.Targets[0]
+	}
+	config := gitLocation(target, "config")
+
+	found := []Finding{
+		newFinding(RuleExposedRepository, gitLocation(target, ""), "",
+			"The .git directory of %s is exposed: %d objects were retrieved and %d are missing",
+			orUnknown(target), len(report.Objects.Found), len(report.Objects.Missing)),
+	}
+
+	// config secrets come from the same parser as the summary and the browsers, and are grouped by rule so that
+	// findings stay in rule order whatever order the config lists them in
+	var remoteCredentials, configSecrets []Finding
+	for _, secret := range report.ConfigSecrets {
+		switch {
+		case secret.Kind == gitjacker.ConfigSecretGithubToken:
+			found = append(found, newFinding(RuleGithubToken, config, secret.Username,
+				"A GitHub token for %s is stored in the git config", orUnknown(secret.Username)))
+		case secret.Kind == gitjacker.ConfigSecretURLCredentials && secret.Section == "remote":
+			remoteCredentials = append(remoteCredentials, newFinding(RuleRemoteCredentials, config, secret.Subsection,
+				"The URL of remote %q contains credentials for %s", secret.Subsection, secret.Username))
+		default:
+			configSecrets = append(configSecrets, newFinding(RuleConfigCredentials, config, secret.Section+"."+secret.Subsection+"."+secret.Key,
+				"%s on line %d of the git config", secret.Description, secret.Line))
+		}
+	}
+	found = append(found, remoteCredentials...)
+
+	if len(report.Identities) > 0 {
+		found = append(found, newFinding(RuleIdentityDisclosure, config, strings.Join(report.Identities, "\n"),
+			"The git config identifies %s", strings.Join(report.Identities, ", ")))
+	}
+
+	for _, secret := range report.Secrets {
+		found = append(found, newFinding(RuleSecret, secret.Path, secret.Rule+"\n"+secret.Value,
+			"%s on line %d, %s", secret.Description, secret.Line, secret.History))
+	}
+
+	for _, credential := range report.Credentials {
+		found = append(found, newFinding(RuleConfigCredentials, credential.Path, credential.URL,
+			"Credentials for %s in %s configuration on line %d, %s", credential.URL, credential.Format, credential.Line, credential.History))
+	}
+	found = append(found, configSecrets...)
+
+	for _, artifact := range report.Artifacts {
+		description := artifact.Description
+		if len(artifact.Details) > 0 {
+			description += " (" + strings.Join(artifact.Details, "; ") + ")"
+		}
+		key := artifact.Kind
+		for _, k := range artifact.Keys {
+			key += "\n" + k.Fingerprint
+		}
+		finding := newFinding(RuleSensitiveFile, artifact.Path, key, "%s, %s", description, artifact.History)
+		if artifact.Score < sensitiveFileErrorScore {
+			finding.Severity = SeverityWarning
+		}
+		found = append(found, finding)
+	}
+
+	return found
+}
+
+// gitLocation resolves a file within the .git directory of a target URL or local path
+func gitLocation(target string, path string) string {
+	if u, err := url.Parse(target); err == nil && u.IsAbs() && u.Host != "" {
+		base := *u
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/.git/" + path
+		base.RawQuery = ""
+		base.Fragment = ""
+		return base.String()
+	}
+	if target == "" {
+		return ".git/" + path
+	}
+	return strings.TrimSuffix(target, "/") + "/.git/" + path
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+package report
+
+import (
+	"html"
+	"html/template"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// language describes just enough of a syntax to colour keywords, strings, numbers and comments
+type language struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(s) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	cLike = language{
+		keywords: words(`if else for while do switch case default break continue return goto struct union enum typedef
+			const static extern void int char long short float double unsigned signed sizeof include define`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	languages = map[string]language{
+		"go": {
+			keywords: words(`break case chan const continue default defer else fallthrough for func go goto if import
+				interface map package range return select struct switch type var nil true false`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"php": {
+			keywords: words(`abstract and array as break case catch class clone const continue declare default do echo
+				else elseif empty extends final finally fn for foreach function global if implements include
+				include_once instanceof interface isset list namespace new or print private protected public
+				require require_once return static switch throw trait try unset use var while yield null true false`),
+			lineComments: []string{"//", "#"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"js": {
+			keywords: words(`async await break case catch class const continue debugger default delete do else export
+				extends finally for from function if import in instanceof let new return super switch this throw
+				try typeof var void while yield null undefined true false interface type enum implements`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"py": {
+			keywords: words(`and as assert async await break class continue def del elif else except finally for from
+				global if import in is lambda nonlocal not or pass raise return try while with yield None True False`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"rb": {
+			keywords: words(`alias and begin break case class def defined do else elsif end ensure false for if in
+				module next nil not or redo rescue retry return self super then true undef unless until when while yield`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"java": {
+			keywords: words(`abstract boolean break byte case catch char class const continue default do double else
+				enum extends final finally float for if implements import instanceof int interface long native new
+				package private protected public return short static super switch synchronized this throw throws
+				try void volatile while null true false var string using namespace`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"sh": {
+			keywords:     words(`if then else elif fi case esac for while until do done in function return export local`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"sql": {
+			keywords: words(`select from where insert into values update set delete create table alter drop index
+				primary key foreign references join left right inner outer on and or not null as order by group having
+				limit SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE ALTER DROP INDEX PRIMARY KEY
+				FOREIGN REFERENCES JOIN LEFT RIGHT INNER OUTER ON AND OR NOT NULL AS ORDER BY GROUP HAVING LIMIT`),
+			lineComments: []string{"--"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"markup": {
+			blockComment: [2]string{"<!--", "-->"},
+			quotes:       `"`,
+		},
+		"css": {
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"config": {
+			keywords:     words(`true false null yes no on off`),
+			lineComments: []string{"#", ";"},
+			quotes:       `"'`,
+		},
+		"c": cLike,
+	}
+	extensions = map[string]string{
+		".go": "go", ".php": "php", ".phtml": "php", ".inc": "php",
+		".js": "js", ".jsx": "js", ".ts": "js", ".tsx": "js", ".mjs": "js", ".vue": "js", ".json": "js",
+		".py": "py", ".rb": "rb", ".erb": "rb", ".java": "java", ".kt": "java", ".cs": "java", ".scala": "java",
+		".c": "c", ".h": "c", ".cpp": "c", ".cc": "c", ".hpp": "c", ".rs": "c", ".swift": "c",
+		".sh": "sh", ".bash": "sh", ".zsh": "sh", ".sql": "sql",
+		".html": "markup", ".htm": "markup", ".xml": "markup", ".svg": "markup", ".twig": "markup", ".tpl": "markup",
+		".css": "css", ".scss": "css", ".less": "css",
+		".yml": "config", ".yaml": "config", ".ini": "config", ".toml": "config", ".env": "config", ".conf": "config",
+		".properties": "config", ".cfg": "config",
+	}
+)
+
+func languageOf(filePath string) (language, bool) {
+	name := path.Base(filePath)
+	switch {
+	case name == "Dockerfile" || name == "Makefile" || strings.HasPrefix(name, ".env"):
+		return languages["sh"], true
+	case name == ".htaccess" || name == ".gitignore":
+		return languages["config"], true
+	}
+	lang, ok := languages[extensions[strings.ToLower(path.Ext(name))]]
+	return lang, ok
+}
+
+// Highlight renders source as escaped HTML, with spans marking keywords (k), strings (s), numbers (n) and comments (c)
+func Highlight(filePath string, source string) template.HTML {
+
+	lang, ok := languageOf(filePath)
+	if !ok {
+		return template.HTML(html.EscapeString(source))
+	}
+
+	out := &strings.Builder{}
+	span := func(class string, text string) {
+		out.WriteString(`<span class="` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+
+	for i := 0; i < len(source); {
+		rest := source[i:]
+
+		if open := lang.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := strings.Index(rest[len(open):], lang.blockComment[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(open) + len(lang.blockComment[1])
+			}
+			span("c", rest[:end])
+			i += end
+			continue
+		}
+
+		if isLineComment(lang, rest, i, source) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			span("c", rest[:end])
+			i += end
+			continue
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(lang.quotes, c) >= 0:
+			end := stringEnd(rest)
+			span("s", rest[:end])
+			i += end
+		case c >= '0' && c <= '9' && (i == 0 || !isWordByte(source[i-1])):
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("n", rest[:end])
+			i += end
+		case isWordByte(c):
+			end := 1
+			for end < len(rest) && isWordByte(rest[end]) {
+				end++
+			}
+			if lang.keywords[rest[:end]] {
+				span("k", rest[:end])
+			} else {
+				out.WriteString(html.EscapeString(rest[:end]))
+			}
+			i += end
+		default:
+			out.WriteString(html.EscapeString(rest[:1]))
+			i++
+		}
+	}
+
+	return template.HTML(out.String())
+}
+
+func isLineComment(lang language, rest string, i int, source string) bool {
+	for _, marker := range lang.lineComments {
+		if !strings.HasPrefix(rest, marker) {
+			continue
+		}
+		// avoid treating the # in PHP's $a#b or a URL fragment as a comment
+		if marker == "#" && i > 0 && !unicode.IsSpace(rune(source[i-1])) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// stringEnd finds the end of a quoted string, which is assumed to stop at the end of the line unless it uses backticks
+func stringEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
@@ -0,0 +1,435 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// limits which keep the report small enough to open in a browser
+const (
+	maxPreviewSize  = 256 * 1024
+	maxPreviewTotal = 32 * 1024 * 1024
+	maxCommits      = 500
+	maxDiffCommits  = 100
+)
+
+type htmlPage struct {
+	*Report
+	Tree    []*treeNode
+	Files   []htmlFile
+	Commits []htmlCommit
+	// history holds the hashes of commits in the history section, which can be linked to
+	history map[string]bool
+	// Notes explain anything which could not be included
+	Notes []string
+}
+
+type treeNode struct {
+	Name     string
+	ID       string
+	Missing  bool
+	Children []*treeNode
+}
+
+type htmlFile struct {
+	ID      string
+	Path    string
+	Hash    string
+	Size    int
+	Content template.HTML
+	Reason  string
+}
+
+type htmlCommit struct {
+	*gitrepo.Commit
+	Short   string
+	Changes []htmlChange
+	Reason  string
+}
+
+type htmlChange struct {
+	gitrepo.Change
+	Added   int
+	Deleted int
+}
+
+func writeHTML(w io.Writer, report *Report) error {
+	page := &htmlPage{Report: report}
+	page.load()
+	return htmlTemplate.Execute(w, page)
+}
+
+// load reads the file tree and history of the recovered repository, noting anything which cannot be read
+func (p *htmlPage) load() {
+
+	repo, err := gitrepo.Open(p.OutputDirectory)
+	if err != nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("The recovered repository could not be read: %s", err))
+		return
+	}
+	defer func() { _ = repo.Close() }()
+
+	head, err := repo.Head()
+	if err != nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("HEAD could not be resolved: %s", err))
+		return
+	}
+
+	if commit, err := repo.Commit(head); err != nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("The HEAD commit %s is missing, so no file tree is available.", head))
+	} else if err := p.loadTree(repo, commit.Tree); err != nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("The file tree could not be read: %s", err))
+	}
+
+	if err := p.loadHistory(repo, head); err != nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("The commit history could not be read: %s", err))
+	}
+}
+
+func (p *htmlPage) loadTree(repo *gitrepo.Repository, tree string) error {
+
+	files, missingDirs, err := repo.Files(tree)
+	if err != nil {
+		return err
+	}
+
+	root := &treeNode{}
+	for _, dir := range missingDirs {
+		if dir == "" {
+			p.Notes = append(p.Notes, fmt.Sprintf("The root tree %s is missing, so no file tree is available.", tree))
+			continue
+		}
+		root.child(dir).Missing = true
+	}
+
+	total := 0
+	for i, file := range files {
+		preview := htmlFile{
+			ID:   fmt.Sprintf("f%d", i),
+			Path: file.Path,
+			Hash: file.Hash,
+		}
+		root.child(file.Path).ID = preview.ID
+
+		content, err := repo.Blob(file.Hash)
+		switch {
+		case file.Mode == "160000":
+			preview.Reason = "Submodule at commit " + file.Hash
+		case errors.Is(err, gitrepo.ErrMissingObject):
+			preview.Reason = "This file could not be recovered."
+		case err != nil:
+			preview.Reason = err.Error()
+		case gitrepo.IsBinary(content) || !utf8.Valid(content):
+			preview.Reason = "Binary file, not shown."
+		case len(content) > maxPreviewSize || total+len(content) > maxPreviewTotal:
+			preview.Reason = "File too large to preview."
+		default:
+			preview.Content = Highlight(file.Path, string(content))
+			total += len(content)
+		}
+		preview.Size = len(content)
+		p.Files = append(p.Files, preview)
+	}
+
+	root.sort()
+	p.Tree = root.Children
+	return nil
+}
+
+// child finds or creates the node at a slash separated path below n
+func (n *treeNode) child(path string) *treeNode {
+	node := n
+	if path == "" {
+		return node
+	}
+	for _, name := range strings.Split(path, "/") {
+		var next *treeNode
+		for _, existing := range node.Children {
+			if existing.Name == name {
+				next = existing
+				break
+			}
+		}
+		if next == nil {
+			next = &treeNode{Name: name}
+			node.Children = append(node.Children, next)
+		}
+		node = next
+	}
+	return node
+}
+
+// sort lists directories before files, as most file browsers do
+func (n *treeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		return a.Name < b.Name
+	})
+	for _, child := range n.Children {
+		child.sort()
+	}
+}
+
+// InHistory reports whether a commit is in the history section
+func (p *htmlPage) InHistory(hash string) bool {
+	return p.history[hash]
+}
+
+func (n *treeNode) IsDir() bool {
+	return n.ID == ""
+}
+
+func (p *htmlPage) loadHistory(repo *gitrepo.Repository, head string) error {
+
+	commits, err := repo.Log([]string{head}, maxCommits)
+	if err != nil {
+		return err
+	}
+	if len(commits) == maxCommits {
+		p.Notes = append(p.Notes, fmt.Sprintf("Only the latest %d commits are included.", maxCommits))
+	}
+
+	p.history = make(map[string]bool)
+	for i, commit := range commits {
+		p.history[commit.Hash] = true
+		entry := htmlCommit{Commit: commit, Short: commit.Hash[:8]}
+		if i >= maxDiffCommits {
+			entry.Reason = "Changes are only included for the latest commits."
+			p.Commits = append(p.Commits, entry)
+			continue
+		}
+		changes, err := repo.DiffCommit(commit)
+		if errors.Is(err, gitrepo.ErrMissingObject) {
+			entry.Reason = "The parent commit could not be recovered, so changes are not available."
+		} else if err != nil {
+			entry.Reason = err.Error()
+		}
+		for _, change := range changes {
+			added, deleted := change.Stats()
+			entry.Changes = append(entry.Changes, htmlChange{Change: change, Added: added, Deleted: deleted})
+		}
+		p.Commits = append(p.Commits, entry)
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"op": func(op gitrepo.LineOp) string {
+		switch op {
+		case gitrepo.OpAdd:
+			return "add"
+		case gitrepo.OpDelete:
+			return "del"
+		}
+		return "ctx"
+	},
+	"opchar": func(op gitrepo.LineOp) string { return string(op) },
+	// statusClass is the first word of a status, as "partial success" is styled as partial
+	"statusClass": func(status string) string { return strings.SplitN(status, " ", 2)[0] },
+	"locations":   locations,
+	"listed":      listed,
+	"sourceName":  sourceName,
+	"moreSources": moreSources,
+	"message":     message,
+	"join":        strings.Join,
+	"authors":     authors,
+}).Parse(htmlSource))
+
+func authors(commits []htmlCommit) []string {
+	var list []*gitrepo.Commit
+	for _, commit := range commits {
+		list = append(list, commit.Commit)
+	}
+	return commitAuthors(list)
+}
+
+// commitAuthors lists everyone who authored or committed a change, in order of appearance
+func commitAuthors(commits []*gitrepo.Commit) []string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		for _, signature := range []gitrepo.Signature{commit.Author, commit.Committer} {
+			if name := signature.String(); name != "<>" && !seen[name] {
+				seen[name] = true
+				list = append(list, name)
+			}
+		}
+	}
+	return list
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Gitjacker report: {{join .Targets ", "}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;margin:0;color:#1f2328;background:#f6f8fa}
+header{background:#24292f;color:#fff;padding:1.5em 2em}
+header h1{margin:0 0 .3em;font-size:1.5em;word-break:break-all}
+header p{margin:0;color:#c9d1d9}
+main{padding:1em 2em 3em;max-width:1400px}
+section{background:#fff;border:1px solid #d0d7de;border-radius:6px;padding:1em 1.5em;margin:1.5em 0}
+h2{margin-top:0;font-size:1.2em}
+table{border-collapse:collapse;width:100%}
+td,th{text-align:left;padding:.35em .7em;border-bottom:1px solid #eaeef2;vertical-align:top}
+code,pre,.mono{font-family:ui-monospace,SFMono-Regular,Menlo,Consolas,monospace;font-size:.85em}
+.status-success{color:#1a7f37}.status-partial{color:#9a6700}.status-failure{color:#cf222e}
+.sev{font-weight:bold;text-transform:uppercase;font-size:.75em;padding:.15em .5em;border-radius:1em;color:#fff}
+.sev-error{background:#cf222e}.sev-warning{background:#bf8700}.sev-note{background:#0969da}
+tr.finding-error td{background:#ffebe9}
+.note{color:#57606a;font-style:italic}
+.browser{display:flex;gap:1em;align-items:flex-start}
+.tree{min-width:260px;max-width:35%;max-height:80vh;overflow:auto;border-right:1px solid #eaeef2;padding-right:1em}
+.tree ul{list-style:none;margin:0;padding-left:1em}
+.tree>ul{padding-left:0}
+.tree summary{cursor:pointer}
+.tree a{text-decoration:none;color:#0969da}
+.missing{color:#cf222e}
+.previews{flex:1;min-width:0}
+.preview{display:none}
+.preview:target{display:block}
+.previews .hint{display:block}
+.previews:has(.preview:target) .hint{display:none}
+pre{background:#f6f8fa;padding:1em;overflow:auto;border-radius:6px;max-height:75vh;margin:0}
+.k{color:#cf222e}.s{color:#0a3069}.n{color:#0550ae}.c{color:#6e7781;font-style:italic}
+details.commit{border-bottom:1px solid #eaeef2;padding:.4em 0}
+details.commit>summary{cursor:pointer}
+.hash{color:#57606a}
+.diff{margin:.6em 0 1em}
+.diff h4{margin:.5em 0;font-weight:normal}
+.diff pre{padding:0}
+.diff span{display:block;padding:0 1em;white-space:pre}
+.diff .add{background:#dafbe1}.diff .del{background:#ffebe9}.diff .hunk{background:#ddf4ff;color:#57606a}
+.stat-add{color:#1a7f37}.stat-del{color:#cf222e}
+</style>
+</head>
+<body>
+<header>
+<h1>Gitjacker report: {{join .Targets ", "}}</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}} by {{.Tool.Name}} {{.Tool.Version}}</p>
+</header>
+<main>
+
+<section id="summary">
+<h2>Summary</h2>
+<table>
+<tr><th>Status</th><td class="status-{{statusClass .Status}}">{{.Status}}</td></tr>
+<tr><th>Retrieved objects</th><td>{{len .Objects.Found}}</td></tr>
+<tr><th>Missing objects</th><td>{{len .Objects.Missing}}</td></tr>
+<tr><th>Cached objects</th><td>{{len .Objects.Cached}}</td></tr>
+<tr><th>Pack data listed</th><td>{{.PackInformationAvailable}}</td></tr>
+<tr><th>Smart HTTP</th><td>{{.SmartProtocolAvailable}}</td></tr>
+<tr><th>Repository</th><td>{{or .Repository "n/a"}}</td></tr>
+<tr><th>Remotes</th><td>{{range .Remotes}}<div>{{.Name}}: <code>{{.URL}}</code></div>{{else}}n/a{{end}}</td></tr>
+<tr><th>Branches</th><td>{{range .Branches}}<div>{{.Name}} ({{or .Remote "n/a"}})</div>{{else}}n/a{{end}}</td></tr>
+<tr><th>Refs</th><td>{{range .Refs}}<div>{{.Name}} <code class="hash">{{.Hash}}</code></div>{{else}}n/a{{end}}</td></tr>
+<tr><th>Output directory</th><td><code>{{.OutputDirectory}}</code></td></tr>
+{{if .AnalysisErrors}}<tr><th>Analysis errors</th><td>{{range .AnalysisErrors}}<div>{{.}}</div>{{end}}</td></tr>{{end}}
+</table>
+{{range .Notes}}<p class="note">{{.}}</p>{{end}}
+</section>
+
+<section id="findings">
+<h2>Findings</h2>
+<table>
+<tr><th>Severity</th><th>Rule</th><th>Description</th><th>Location</th></tr>
+{{range .Findings}}<tr class="finding-{{.Severity}}"><td><span class="sev sev-{{.Severity}}">{{.Severity}}</span></td><td>{{.RuleID}}</td><td>{{.Message}}</td><td><code>{{.Location}}</code></td></tr>
+{{end}}</table>
+</section>
+
+<section id="secrets">
+<h2>Secrets</h2>
+{{if .Secrets}}<table>
+<tr><th>Secret</th><th>Location</th><th>Value</th><th>History</th></tr>
+{{range .Secrets}}<tr><td>{{.Description}}</td><td><code>{{.Path}}:{{.Line}}</code></td><td><code>{{.Value}}</code></td><td>{{.History}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No secrets were found.</p>{{end}}
+</section>
+
+<section id="artifacts">
+<h2>Sensitive files</h2>
+{{if .Artifacts}}<table>
+<tr><th>File</th><th>Path</th><th>Details</th><th>History</th></tr>
+{{range .Artifacts}}<tr><td>{{.Description}}</td><td><code>{{.Path}}</code></td><td>{{range .Details}}{{.}}<br>{{end}}</td><td>{{.History}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No sensitive files were found.</p>{{end}}
+</section>
+
+<section id="credentials">
+<h2>Credentials</h2>
+{{if .Credentials}}<table>
+<tr><th>Service</th><th>Location</th><th>Connection</th><th>History</th></tr>
+{{range .Credentials}}<tr><td>{{.Service}} <span class="note">({{.Format}})</span></td><td><code>{{.Path}}:{{.Line}}</code></td><td><code>{{.URL}}</code></td><td>{{.History}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No credentials were found in configuration files.</p>{{end}}
+</section>
+
+<section id="endpoints">
+<h2>Endpoints</h2>
+{{if .Endpoints}}<table>
+<tr><th>Kind</th><th>Endpoint</th><th>Detail</th><th>Found in</th></tr>
+{{range .Endpoints}}<tr><td>{{.Kind}}</td><td><code>{{.Value}}</code></td><td>{{.Detail}}</td><td><code>{{locations .Locations}}</code></td></tr>
+{{end}}</table>{{else}}<p class="note">No endpoints were found.</p>{{end}}
+</section>
+
+<section id="messages">
+<h2>Commit messages</h2>
+{{if .Mentions}}<table>
+<tr><th>Kind</th><th>Mention</th><th>Detail</th><th>Message</th><th>Commits</th></tr>
+{{range .Mentions}}<tr><td>{{.Kind}}</td><td><code>{{.Value}}</code></td><td>{{.Detail}}</td><td>{{message .}}</td><td>{{range $i, $source := listed .Sources}}{{if $i}}, {{end}}{{if $.InHistory $source.Commit}}<a href="#commit-{{$source.Commit}}">{{sourceName $source}}</a>{{else}}{{sourceName $source}}{{end}}{{end}}{{moreSources .Sources}}</td></tr>
+{{end}}</table>{{else}}<p class="note">Nothing of interest was found in commit, tag or reflog messages.</p>{{end}}
+</section>
+
+<section id="identities">
+<h2>Identities</h2>
+{{if or .Identities .GithubToken}}<ul>
+{{range .Identities}}<li>{{.}}</li>{{end}}
+{{with .GithubToken}}<li>GitHub token for {{or .Username "unknown"}}: <code>{{.Token}}</code></li>{{end}}
+</ul>{{else}}<p class="note">No identities were found.</p>{{end}}
+{{with .Commits}}<p>Commit authors:</p><ul>{{range authors .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</section>
+
+<section id="files">
+<h2>Files at HEAD</h2>
+{{if .Tree}}<div class="browser">
+<nav class="tree"><ul>{{template "tree" .Tree}}</ul></nav>
+<div class="previews">
+<p class="note hint">Select a file to preview it.</p>
+{{range .Files}}<div class="preview" id="{{.ID}}">
+<h3 class="mono">{{.Path}}</h3>
+<p class="hash mono">{{.Hash}} &middot; {{.Size}} bytes</p>
+{{if .Reason}}<p class="note">{{.Reason}}</p>{{else}}<pre>{{.Content}}</pre>{{end}}
+</div>
+{{end}}</div>
+</div>{{else}}<p class="note">No files are available.</p>{{end}}
+</section>
+
+<section id="history">
+<h2>History</h2>
+{{range .Commits}}<details class="commit" id="commit-{{.Hash}}">
+<summary><code class="hash">{{.Short}}</code> {{.Subject}} &mdash; {{.Author.Name}}, {{.Author.When.Format "2006-01-02 15:04"}}</summary>
+<p class="mono">commit {{.Hash}}<br>Author: {{.Author}}<br>Date: {{.Author.When.Format "Mon Jan 2 15:04:05 2006 -0700"}}</p>
+<pre>{{.Message}}</pre>
+{{if .Reason}}<p class="note">{{.Reason}}</p>{{end}}
+{{range .Changes}}<div class="diff">
+<h4><code>{{.Path}}</code> {{.Status}} <span class="stat-add">+{{.Added}}</span> <span class="stat-del">-{{.Deleted}}</span></h4>
+{{if .Missing}}<p class="note">Content could not be recovered.</p>{{else if .Binary}}<p class="note">Binary file.</p>{{else if .TooLarge}}<p class="note">File too large to compare.</p>{{else if .Hunks}}<pre>{{range .Hunks}}<span class="hunk">@@ -{{.OldStart}},{{.OldLines}} +{{.NewStart}},{{.NewLines}} @@</span>{{range .Lines}}<span class="{{op .Op}}">{{opchar .Op}}{{.Text}}</span>{{end}}{{end}}</pre>{{end}}
+</div>{{end}}
+</details>
+{{else}}<p class="note">No commits could be recovered.</p>{{end}}
+</section>
+
+</main>
+</body>
+</html>
+{{define "tree"}}{{range .}}{{if .IsDir}}<li><details><summary{{if .Missing}} class="missing" title="This directory could not be recovered"{{end}}>{{.Name}}/</summary><ul>{{template "tree" .Children}}</ul></details></li>{{else}}<li><a href="#{{.ID}}">{{.Name}}</a></li>{{end}}{{end}}{{end}}
+`
@@ -0,0 +1,253 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func writeMarkdown(w io.Writer, report *Report) error {
+
+	md := &strings.Builder{}
+
+	fmt.Fprintf(md, "# Gitjacker report: %s\n\n", escape(strings.Join(report.Targets, ", ")))
+	fmt.Fprintf(md, "Generated %s by %s %s.\n\n", report.GeneratedAt.Format(time.RFC3339), report.Tool.Name, report.Tool.Version)
+
+	md.WriteString("| | |\n|---|---|\n")
+	row(md, "Status", report.Status)
+	row(md, "Retrieved objects", fmt.Sprint(len(report.Objects.Found)))
+	row(md, "Missing objects", fmt.Sprint(len(report.Objects.Missing)))
+	row(md, "Cached objects", fmt.Sprint(len(report.Objects.Cached)))
+	row(md, "Pack data listed", fmt.Sprint(report.PackInformationAvailable))
+	row(md, "Smart HTTP", fmt.Sprint(report.SmartProtocolAvailable))
+	row(md, "Repository", orNA(report.Repository))
+	row(md, "Output directory", report.OutputDirectory)
+	if len(report.AnalysisErrors) > 0 {
+		row(md, "Analysis errors", strings.Join(report.AnalysisErrors, "; "))
+	}
+	md.WriteString("\n")
+
+	md.WriteString("## Findings\n\n| Severity | Rule | Location | Description |\n|---|---|---|---|\n")
+	for _, finding := range report.Findings {
+		fmt.Fprintf(md, "| %s | %s | %s | %s |\n", finding.Severity, finding.RuleID, escape(finding.Location), escape(finding.Message))
+	}
+	md.WriteString("\n")
+
+	md.WriteString("## Secrets\n\n")
+	if len(report.Secrets) == 0 {
+		md.WriteString("No secrets were found.\n\n")
+	} else {
+		md.WriteString("| Secret | Location | Value | History |\n|---|---|---|---|\n")
+		for _, secret := range report.Secrets {
+			fmt.Fprintf(md, "| %s | %s:%d | %s | %s |\n", escape(secret.Description), escape(secret.Path), secret.Line, escape(secret.Value), escape(secret.History))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Sensitive files\n\n")
+	if len(report.Artifacts) == 0 {
+		md.WriteString("No sensitive files were found.\n\n")
+	} else {
+		md.WriteString("| File | Path | Details | History |\n|---|---|---|---|\n")
+		for _, artifact := range report.Artifacts {
+			fmt.Fprintf(md, "| %s | %s | %s | %s |\n", escape(artifact.Description), escape(artifact.Path), escape(strings.Join(artifact.Details, "; ")), escape(artifact.History))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Credentials\n\n")
+	if len(report.Credentials) == 0 {
+		md.WriteString("No credentials were found in configuration files.\n\n")
+	} else {
+		md.WriteString("| Service | Location | Connection | History |\n|---|---|---|---|\n")
+		for _, credential := range report.Credentials {
+			fmt.Fprintf(md, "| %s | %s:%d | %s | %s |\n", escape(credential.Service), escape(credential.Path), credential.Line, escape(credential.URL), escape(credential.History))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Endpoints\n\n")
+	if len(report.Endpoints) == 0 {
+		md.WriteString("No endpoints were found.\n\n")
+	} else {
+		md.WriteString("| Kind | Endpoint | Detail | Found in |\n|---|---|---|---|\n")
+		for _, endpoint := range report.Endpoints {
+			fmt.Fprintf(md, "| %s | %s | %s | %s |\n", endpoint.Kind, escape(endpoint.Value), escape(endpoint.Detail), escape(locations(endpoint.Locations)))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Commit messages\n\n")
+	if len(report.Mentions) == 0 {
+		md.WriteString("Nothing of interest was found in commit, tag or reflog messages.\n\n")
+	} else {
+		md.WriteString("| Kind | Mention | Detail | Message | Commits |\n|---|---|---|---|---|\n")
+		for _, mention := range report.Mentions {
+			fmt.Fprintf(md, "| %s | %s | %s | %s | %s |\n", mention.Kind, escape(mention.Value), escape(mention.Detail), escape(message(mention)), escape(sources(mention.Sources)))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Configuration\n\n")
+	if len(report.Remotes) > 0 {
+		md.WriteString("Remotes:\n\n")
+		for _, remote := range report.Remotes {
+			fmt.Fprintf(md, "- %s: `%s`\n", escape(remote.Name), remote.URL)
+		}
+		md.WriteString("\n")
+	}
+	if len(report.Branches) > 0 {
+		md.WriteString("Branches:\n\n")
+		for _, branch := range report.Branches {
+			fmt.Fprintf(md, "- %s (%s)\n", escape(branch.Name), escape(orNA(branch.Remote)))
+		}
+		md.WriteString("\n")
+	}
+	if report.User != (User{}) {
+		md.WriteString("User:\n\n")
+		if report.User.Name != "" {
+			fmt.Fprintf(md, "- Name: %s\n", escape(report.User.Name))
+		}
+		if report.User.Username != "" {
+			fmt.Fprintf(md, "- Username: %s\n", escape(report.User.Username))
+		}
+		if report.User.Email != "" {
+			fmt.Fprintf(md, "- Email: %s\n", escape(report.User.Email))
+		}
+		md.WriteString("\n")
+	}
+	if report.GithubToken != nil {
+		fmt.Fprintf(md, "GitHub token: %s:`%s`\n\n", escape(report.GithubToken.Username), report.GithubToken.Token)
+	}
+	if len(report.Remotes) == 0 && len(report.Branches) == 0 && report.User == (User{}) && report.GithubToken == nil {
+		md.WriteString("No configuration was recovered.\n\n")
+	}
+
+	md.WriteString("## Refs\n\n")
+	if len(report.Refs) == 0 {
+		md.WriteString("No refs were discovered.\n\n")
+	} else {
+		md.WriteString("| Ref | Commit |\n|---|---|\n")
+		for _, ref := range report.Refs {
+			fmt.Fprintf(md, "| %s | `%s` |\n", escape(ref.Name), ref.Hash)
+		}
+		md.WriteString("\n")
+	}
+
+	if len(report.RefUpdates) > 0 || len(report.NewCommits) > 0 {
+		md.WriteString("## Changes since the previous run\n\n")
+		for _, update := range report.RefUpdates {
+			fmt.Fprintf(md, "- %s: `%s` -> `%s`\n", escape(update.Name), orNA(update.OldHash), orNA(update.NewHash))
+		}
+		for _, hash := range report.NewCommits {
+			fmt.Fprintf(md, "- new commit `%s`\n", hash)
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("## Objects\n\n")
+	objectList(md, "Missing", report.Objects.Missing)
+	objectList(md, "Retrieved", report.Objects.Found)
+	objectList(md, "Cached", report.Objects.Cached)
+
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+func row(md *strings.Builder, name string, value string) {
+	fmt.Fprintf(md, "| %s | %s |\n", name, escape(value))
+}
+
+// objectList collapses long lists of hashes so the report stays readable when rendered
+func objectList(md *strings.Builder, name string, hashes []string) {
+	fmt.Fprintf(md, "<details>\n<summary>%s objects (%d)</summary>\n\n", name, len(hashes))
+	for _, hash := range hashes {
+		fmt.Fprintf(md, "- `%s`\n", hash)
+	}
+	md.WriteString("\n</details>\n\n")
+}
+
+// locations lists where something was found, e.g. "src/api.js:12 and 3 more"
+func locations(occurrences []Occurrence) string {
+	if len(occurrences) == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("%s:%d", occurrences[0].Path, occurrences[0].Line)
+	if len(occurrences) > 1 {
+		s += fmt.Sprintf(" and %d more", len(occurrences)-1)
+	}
+	return s
+}
+
+// maxSources is how many messages are listed for a mention before the rest are counted
+const maxSources = 5
+
+// sources lists the commits a mention was found in, e.g. "1a2b3c4d, 5e6f7a8b (tag v1.0) and 3 more". Messages
+// which could not be tied to a commit are named by where they were found.
+func sources(sources []MessageSource) string {
+	var names []string
+	for _, source := range listed(sources) {
+		names = append(names, sourceName(source))
+	}
+	return strings.Join(names, ", ") + moreSources(sources)
+}
+
+func listed(sources []MessageSource) []MessageSource {
+	if len(sources) > maxSources {
+		return sources[:maxSources]
+	}
+	return sources
+}
+
+func moreSources(sources []MessageSource) string {
+	if len(sources) <= maxSources {
+		return ""
+	}
+	return fmt.Sprintf(" and %d more", len(sources)-maxSources)
+}
+
+func sourceName(source MessageSource) string {
+	name := source.Type
+	if source.Commit != "" {
+		name = shortHash(source.Commit)
+	}
+	switch source.Type {
+	case "tag":
+		name += " (tag " + strings.TrimPrefix(source.Ref, "refs/tags/") + ")"
+	case "reflog":
+		name += " (reflog " + source.Ref + ")"
+	case "COMMIT_EDITMSG":
+		if source.Commit != "" {
+			name += " (COMMIT_EDITMSG)"
+		}
+	}
+	return name
+}
+
+// message is the line a mention was first found on, unless the mention is the whole line
+func message(mention Mention) string {
+	if len(mention.Sources) == 0 || mention.Sources[0].Line == mention.Value {
+		return ""
+	}
+	return mention.Sources[0].Line
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// escape stops values from breaking table cells or being rendered as markup
+func escape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "<", "&lt;", ">", "&gt;", "*", "\\*", "_", "\\_", "`", "\\`").Replace(s)
+}
+
+func orNA(s string) string {
+	if s == "" {
+		return "n/a"
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// Template renders a report through a user supplied text/template
+type Template struct {
+	template *template.Template
+}
+
+// TemplateData is the data model given to templates. All fields of Report are available directly, e.g.
+// {{ .Status }}, along with the commits and files of the recovered repository, which are only read if used.
+type TemplateData struct {
+	*Report
+	loaded  bool
+	commits []*gitrepo.Commit
+	files   []gitrepo.File
+}
+
+// Commits lists every recovered commit reachable from HEAD, newest first
+func (d *TemplateData) Commits() []*gitrepo.Commit {
+	d.load()
+	return d.commits
+}
+
+// Files lists every file at HEAD, sorted by path
+func (d *TemplateData) Files() []gitrepo.File {
+	d.load()
+	return d.files
+}
+
+// Authors lists everyone who authored or committed a recovered commit
+func (d *TemplateData) Authors() []string {
+	return commitAuthors(d.Commits())
+}
+
+// load reads the recovered repository, leaving commits and files empty if it cannot be read
+func (d *TemplateData) load() {
+	if d.loaded {
+		return
+	}
+	d.loaded = true
+
+	repo, err := gitrepo.Open(d.OutputDirectory)
+	if err != nil {
+		return
+	}
+	defer func() { _ = repo.Close() }()
+
+	head, err := repo.Head()
+	if err != nil {
+		return
+	}
+	d.commits, _ = repo.Log([]string{head}, 0)
+	if commit, err := repo.Commit(head); err == nil {
+		d.files, _, _ = repo.Files(commit.Tree)
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"contains": func(substr string, s string) bool {
+		return strings.Contains(s, substr)
+	},
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"default": func(fallback string, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"short": func(hash string) string {
+		if len(hash) > 8 {
+			return hash[:8]
+		}
+		return hash
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"redact": func(secret string) string {
+		if len(secret) <= 4 {
+			return strings.Repeat("*", len(secret))
+		}
+		return secret[:4] + strings.Repeat("*", len(secret)-4)
+	},
+	"severity": func(severity string, findings []Finding) []Finding {
+		var matched []Finding
+		for _, finding := range findings {
+			if string(finding.Severity) == severity {
+				matched = append(matched, finding)
+			}
+		}
+		return matched
+	},
+}
+
+// ParseTemplate parses a report template, where name is used in error messages
+func ParseTemplate(name string, source string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report template: %w", err)
+	}
+	return &Template{template: tmpl}, nil
+}
+
+func (t *Template) Execute(w io.Writer, report *Report) error {
+	return t.template.Execute(w, &TemplateData{Report: report})
+}
@@ -0,0 +1,83 @@
+package results
+
+import (
+	"sort"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// Diff describes what changed between two runs
+type Diff struct {
+	From              string                `json:"from"`
+	To                string                `json:"to"`
+	StatusFrom        string                `json:"status_from"`
+	StatusTo          string                `json:"status_to"`
+	RefUpdates        []gitjacker.RefUpdate `json:"ref_updates"`
+	IdentitiesAdded   []string              `json:"identities_added"`
+	IdentitiesRemoved []string              `json:"identities_removed"`
+	ObjectsAdded      []string              `json:"objects_added"`
+	ObjectsRemoved    []string              `json:"objects_removed"`
+}
+
+func Compare(from *Record, to *Record) Diff {
+
+	diff := Diff{
+		From:       from.ID,
+		To:         to.ID,
+		StatusFrom: from.Status,
+		StatusTo:   to.Status,
+	}
+
+	oldRefs := make(map[string]string)
+	for _, ref := range from.Refs {
+		oldRefs[ref.Name] = ref.Hash
+	}
+	newRefs := make(map[string]string)
+	for _, ref := range to.Refs {
+		newRefs[ref.Name] = ref.Hash
+		if oldRefs[ref.Name] != ref.Hash {
+			diff.RefUpdates = append(diff.RefUpdates, gitjacker.RefUpdate{Name: ref.Name, OldHash: oldRefs[ref.Name], NewHash: ref.Hash})
+		}
+	}
+	for _, ref := range from.Refs {
+		if _, ok := newRefs[ref.Name]; !ok {
+			diff.RefUpdates = append(diff.RefUpdates, gitjacker.RefUpdate{Name: ref.Name, OldHash: ref.Hash})
+		}
+	}
+	sort.Slice(diff.RefUpdates, func(i, j int) bool {
+		return diff.RefUpdates[i].Name < diff.RefUpdates[j].Name
+	})
+
+	diff.IdentitiesAdded, diff.IdentitiesRemoved = difference(from.Identities, to.Identities)
+
+	if from.Summary != nil && to.Summary != nil {
+		diff.ObjectsAdded, diff.ObjectsRemoved = difference(from.Summary.FoundObjects, to.Summary.FoundObjects)
+	}
+
+	return diff
+}
+
+// difference returns the sorted values only in b, and those only in a
+func difference(a []string, b []string) ([]string, []string) {
+	inA := make(map[string]bool)
+	for _, value := range a {
+		inA[value] = true
+	}
+	inB := make(map[string]bool)
+	var added []string
+	for _, value := range b {
+		inB[value] = true
+		if !inA[value] {
+			added = append(added, value)
+		}
+	}
+	var removed []string
+	for _, value := range a {
+		if !inB[value] {
+			removed = append(removed, value)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
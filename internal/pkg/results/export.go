@@ -0,0 +1,63 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// Export writes records in the given format. CSV contains the indexed fields only.
+func Export(w io.Writer, records []Record, format Format) error {
+	switch format {
+	case FormatJSON:
+		if records == nil {
+			records = []Record{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"id", "kind", "targets", "started_at", "finished_at", "status", "found_objects", "missing_objects", "new_commits", "refs", "identities", "output_dir", "findings"})
+		for _, record := range records {
+			_ = writer.Write([]string{
+				record.ID,
+				string(record.Kind),
+				strings.Join(record.Targets, " "),
+				record.StartedAt.Format(time.RFC3339),
+				record.FinishedAt.Format(time.RFC3339),
+				record.Status,
+				strconv.Itoa(record.FoundObjects),
+				strconv.Itoa(record.MissingObjects),
+				strconv.Itoa(record.NewCommits),
+				strconv.Itoa(len(record.Refs)),
+				strings.Join(record.Identities, "; "),
+				record.OutputDir,
+				strings.Join(record.FindingNames(), "; "),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
@@ -0,0 +1,290 @@
+package results
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+type Kind string
+
+const (
+	KindRetrieve Kind = "retrieve"
+	KindRefresh  Kind = "refresh"
+	KindAnalyze  Kind = "analyze"
+	KindMonitor  Kind = "monitor"
+	KindServe    Kind = "serve"
+)
+
+var ErrNotFound = fmt.Errorf("no matching result was found")
+var ErrAmbiguous = fmt.Errorf("result id is ambiguous")
+
+// Record is a single saved run. The summary is only populated by Get, as listings are served from the index.
+type Record struct {
+	ID             string          `json:"id"`
+	Kind           Kind            `json:"kind"`
+	Targets        []string        `json:"targets"`
+	StartedAt      time.Time       `json:"started_at"`
+	FinishedAt     time.Time       `json:"finished_at"`
+	OutputDir      string          `json:"output_dir"`
+	Status         string          `json:"status"`
+	FoundObjects   int             `json:"found_objects"`
+	MissingObjects int             `json:"missing_objects"`
+	NewCommits     int             `json:"new_commits"`
+	Refs           []gitjacker.Ref `json:"refs"`
+	Identities     []string        `json:"identities,omitempty"`
+	// Findings counts what analysis found: secrets by rule, e.g. "aws-access-key-id", credentials by service,
+	// e.g. "credential:mysql", and sensitive files by kind, e.g. "file:kubeconfig"
+	Findings map[string]int     `json:"findings,omitempty"`
+	Summary  *gitjacker.Summary `json:"summary,omitempty"`
+}
+
+// NewRecord describes a run from its summary
+func NewRecord(kind Kind, targets []string, started time.Time, summary *gitjacker.Summary) Record {
+	return Record{
+		Kind:           kind,
+		Targets:        targets,
+		StartedAt:      started.UTC(),
+		FinishedAt:     time.Now().UTC(),
+		OutputDir:      summary.OutputDirectory,
+		Status:         summary.Status.String(),
+		FoundObjects:   len(summary.FoundObjects),
+		MissingObjects: len(summary.MissingObjects),
+		NewCommits:     len(summary.NewCommits),
+		Refs:           summary.Refs,
+		Identities:     summary.Identities(),
+		Findings:       findings(summary),
+		Summary:        summary,
+	}
+}
+
+func findings(summary *gitjacker.Summary) map[string]int {
+	counts := make(map[string]int)
+	for _, secret := range summary.Analysis.Secrets {
+		counts[secret.Rule]++
+	}
+	for _, credential := range summary.Analysis.Credentials {
+		counts["credential:"+credential.Service]++
+	}
+	for _, artifact := range summary.Analysis.Artifacts {
+		counts["file:"+artifact.Kind]++
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+// FindingNames lists the findings of a record with their counts, e.g. "aws-access-key-id=2"
+func (r Record) FindingNames() []string {
+	var names []string
+	for name, count := range r.Findings {
+		names = append(names, fmt.Sprintf("%s=%d", name, count))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DB is a local store of past runs: an append-only index for fast queries, plus one file per run holding its full summary
+type DB struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "records"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to open results database: %w", err)
+	}
+	return &DB{
+		dir: dir,
+	}, nil
+}
+
+func (db *DB) indexPath() string {
+	return filepath.Join(db.dir, "index.ndjson")
+}
+
+func (db *DB) recordPath(id string) string {
+	return filepath.Join(db.dir, "records", id+".json")
+}
+
+// Save stores a record, assigning it an id which sorts by time
+func (db *DB) Save(record Record) (Record, error) {
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return record, err
+	}
+	record.ID = record.FinishedAt.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return record, err
+	}
+	if err := ioutil.WriteFile(db.recordPath(record.ID), data, 0640); err != nil {
+		return record, err
+	}
+
+	entry := record
+	entry.Summary = nil
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return record, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// a single append of a whole line keeps the index consistent when several processes share it
+	f, err := os.OpenFile(db.indexPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return record, err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write(append(line, '\n'))
+	return record, err
+}
+
+// List returns the records matching filter, newest first
+func (db *DB) List(filter Filter) ([]Record, error) {
+
+	f, err := os.Open(db.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// skip lines torn by a crash rather than losing the whole history
+			continue
+		}
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].FinishedAt.After(records[j].FinishedAt)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+// Get loads a full record by id or unique id prefix
+func (db *DB) Get(id string) (*Record, error) {
+
+	files, err := ioutil.ReadDir(filepath.Join(db.dir, "records"))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".json")
+		if name == id {
+			matches = []string{name}
+			break
+		}
+		if strings.HasPrefix(name, id) {
+			matches = append(matches, name)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: %s matches %d results", ErrAmbiguous, id, len(matches))
+	}
+
+	data, err := ioutil.ReadFile(db.recordPath(matches[0]))
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse result %s: %w", matches[0], err)
+	}
+	return &record, nil
+}
+
+// Filter selects records. Empty fields match everything, and string fields match case-insensitive substrings.
+type Filter struct {
+	Target   string
+	Kind     Kind
+	Status   string
+	Identity string
+	// Finding matches records with a finding whose name contains this text, e.g. "aws"
+	Finding string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+func (f Filter) Match(record Record) bool {
+	if f.Kind != "" && record.Kind != f.Kind {
+		return false
+	}
+	if f.Status != "" && !strings.EqualFold(record.Status, f.Status) {
+		return false
+	}
+	if !f.Since.IsZero() && record.FinishedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.FinishedAt.After(f.Until) {
+		return false
+	}
+	if f.Target != "" && !containsFold(record.Targets, f.Target) {
+		return false
+	}
+	if f.Identity != "" && !containsFold(record.Identities, f.Identity) {
+		return false
+	}
+	if f.Finding != "" && !hasFinding(record, f.Finding) {
+		return false
+	}
+	return true
+}
+
+func hasFinding(record Record, substr string) bool {
+	for name, count := range record.Findings {
+		if count > 0 && containsFold([]string{name}, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, substr string) bool {
+	substr = strings.ToLower(substr)
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), substr) {
+			return true
+		}
+	}
+	return false
+}
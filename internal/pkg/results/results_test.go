@@ -0,0 +1,151 @@
+package results
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/magiconair/properties/assert"
+)
+
+func openTestDB(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() { _ = os.RemoveAll(dir) }
+}
+
+func TestSaveListAndGet(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	first := &gitjacker.Summary{
+		Status:       gitjacker.StatusSuccess,
+		FoundObjects: []string{"a", "b"},
+		Refs:         []gitjacker.Ref{{Name: "refs/heads/master", Hash: "1111111111111111111111111111111111111111"}},
+	}
+	first.Config.User.Email = "dev@victim.example"
+	first.Analysis.Secrets = []exposed.Secret{{Rule: "aws-access-key-id"}, {Rule: "aws-access-key-id"}}
+	first.Analysis.Credentials = []exposed.Credential{{Service: "mysql"}}
+
+	second := &gitjacker.Summary{
+		Status:         gitjacker.StatusPartialSuccess,
+		FoundObjects:   []string{"b", "c"},
+		MissingObjects: []string{"d"},
+		Refs: []gitjacker.Ref{
+			{Name: "refs/heads/master", Hash: "2222222222222222222222222222222222222222"},
+			{Name: "refs/heads/staging", Hash: "3333333333333333333333333333333333333333"},
+		},
+	}
+
+	older := NewRecord(KindRetrieve, []string{"http://victim.example/"}, time.Now().Add(-time.Hour), first)
+	older.FinishedAt = older.FinishedAt.Add(-time.Hour)
+	saved, err := db.Save(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Save(NewRecord(KindRefresh, []string{"http://victim.example/"}, time.Now(), second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Save(NewRecord(KindRetrieve, []string{"http://other.example/"}, time.Now(), second)); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := db.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(all), 3)
+	assert.Equal(t, all[2].ID, saved.ID)
+	assert.Equal(t, all[0].Summary == nil, true)
+
+	victim, err := db.List(Filter{Target: "VICTIM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(victim), 2)
+
+	identified, err := db.List(Filter{Identity: "dev@"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(identified), 1)
+
+	leaked, err := db.List(Filter{Finding: "AWS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(leaked), 1)
+	assert.Equal(t, leaked[0].Findings, map[string]int{"aws-access-key-id": 2, "credential:mysql": 1})
+
+	none, err := db.List(Filter{Finding: "file:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(none), 0)
+
+	recent, err := db.List(Filter{Since: time.Now().Add(-time.Minute), Kind: KindRetrieve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(recent), 1)
+	assert.Equal(t, recent[0].Targets, []string{"http://other.example/"})
+
+	from, err := db.Get(saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, from.Summary.FoundObjects, []string{"a", "b"})
+
+	to, err := db.Get(victim[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := Compare(from, to)
+	assert.Equal(t, diff.StatusFrom, "success")
+	assert.Equal(t, diff.StatusTo, "partial success")
+	assert.Equal(t, len(diff.RefUpdates), 2)
+	assert.Equal(t, diff.RefUpdates[0].OldHash, "1111111111111111111111111111111111111111")
+	assert.Equal(t, diff.RefUpdates[1].OldHash, "")
+	assert.Equal(t, diff.IdentitiesRemoved, []string{"<dev@victim.example>"})
+	assert.Equal(t, diff.ObjectsAdded, []string{"c"})
+	assert.Equal(t, diff.ObjectsRemoved, []string{"a"})
+
+	_, err = db.Get("nope")
+	assert.Equal(t, errors.Is(err, ErrNotFound), true)
+}
+
+func TestExport(t *testing.T) {
+	records := []Record{
+		{ID: "1", Kind: KindRetrieve, Targets: []string{"http://victim.example/"}, Status: "success", FoundObjects: 3},
+		{ID: "2", Kind: KindMonitor, Targets: []string{"http://other.example/"}, Status: "failure"},
+	}
+
+	csv := &bytes.Buffer{}
+	if err := Export(csv, records, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+	assert.Equal(t, strings.HasPrefix(lines[1], "1,retrieve,http://victim.example/,"), true)
+
+	ndjson := &bytes.Buffer{}
+	if err := Export(ndjson, records, FormatNDJSON); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Count(ndjson.String(), "\n"), 2)
+
+	assert.Equal(t, Export(&bytes.Buffer{}, records, "xml") != nil, true)
+}
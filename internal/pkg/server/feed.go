@@ -0,0 +1,84 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+// maxFeedEvents is how many of a job's most recent events are kept for replay, as a large repository produces
+// an event for every object
+const maxFeedEvents = 10000
+
+// feedRetention is how long the events of a finished job are kept, so that followers can read the last of them
+var feedRetention = time.Minute
+
+// feed records the events of a job so late subscribers can replay them before following live progress. Events
+// are only held in memory, so they are not replayed after a restart.
+type feed struct {
+	mu     sync.Mutex
+	events []gitjacker.Event
+	// dropped counts the events discarded from the start of events, so that offsets count every event published
+	dropped int
+	closed  bool
+	changed chan struct{}
+}
+
+func newFeed(closed bool) *feed {
+	return &feed{
+		closed:  closed,
+		changed: make(chan struct{}),
+	}
+}
+
+func (f *feed) publish(event gitjacker.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.events = append(f.events, event)
+	if len(f.events) > maxFeedEvents {
+		// drop the oldest half at once, rather than copying the buffer for every event
+		excess := len(f.events) - maxFeedEvents/2
+		f.events = append([]gitjacker.Event(nil), f.events[excess:]...)
+		f.dropped += excess
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *feed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	close(f.changed)
+	time.AfterFunc(feedRetention, f.release)
+}
+
+// release drops the events of a finished job
+func (f *feed) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dropped += len(f.events)
+	f.events = nil
+}
+
+// since returns events from offset onwards, the offset following them, whether the feed is closed, and a channel
+// closed on the next change. Events which have been dropped are skipped.
+func (f *feed) since(offset int) ([]gitjacker.Event, int, bool, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offset < f.dropped {
+		offset = f.dropped
+	}
+	var events []gitjacker.Event
+	if offset-f.dropped < len(f.events) {
+		events = append(events, f.events[offset-f.dropped:]...)
+	}
+	return events, f.dropped + len(f.events), f.closed, f.changed
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServeHTTP implements the REST API:
+//
+//	POST /jobs                 submit a JobRequest
+//	GET  /jobs                 list jobs
+//	GET  /jobs/{id}            get a job
+//	GET  /jobs/{id}/events     stream progress events as newline-delimited JSON until the job finishes
+//	GET  /jobs/{id}/summary    get the summary of a finished job
+//	GET  /jobs/{id}/artifact   download the retrieved repository as a .tar.gz
+//
+// Events are held in memory: the most recent events of a running job are replayed to new subscribers, and those
+// of a finished job for a minute after it finishes. They are not replayed after the server restarts.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSONResponse(w, http.StatusOK, s.Jobs())
+		case http.MethodPost:
+			s.handleSubmit(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	job, ok := s.Job(parts[1])
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	if len(parts) == 2 {
+		writeJSONResponse(w, http.StatusOK, job)
+		return
+	}
+
+	switch parts[2] {
+	case "events":
+		s.handleEvents(w, r, job.ID)
+	case "summary":
+		if !job.finished() {
+			writeError(w, http.StatusConflict, "job has not finished")
+			return
+		}
+		if job.Summary == nil {
+			writeError(w, http.StatusNotFound, "job did not produce a summary: "+job.Error)
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, job.Summary)
+	case "artifact":
+		if job.Status != JobSucceeded {
+			writeError(w, http.StatusConflict, "job has not succeeded")
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+".tar.gz"))
+		if err := writeArchive(w, s.store.OutputDir(job.ID), job.ID); err != nil {
+			logrus.Errorf("Failed to archive job %s: %s", job.ID, err)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var request JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	job, err := s.Submit(request)
+	if errors.Is(err, ErrInvalidRequest) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrQueueFull) {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSONResponse(w, http.StatusCreated, job)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, id string) {
+
+	s.mu.Lock()
+	feed := s.feeds[id]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	offset := 0
+	for {
+		events, next, closed, changed := feed.since(offset)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+		offset = next
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSONResponse(w, status, apiError{Error: message})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeArchive streams dir as a gzipped tarball with every entry beneath prefix
+func writeArchive(w io.Writer, dir string, prefix string) error {
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, relative))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(archive, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
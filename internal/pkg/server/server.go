@@ -0,0 +1,347 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	// DataDir holds the job store and the output directory of every job
+	DataDir string
+	// CacheDir is an optional shared object cache for jobs which request it
+	CacheDir string
+	// Workers is the number of jobs run concurrently
+	Workers int
+	// MaxQueued is the number of jobs which may wait to run before new jobs are refused, 1024 by default
+	MaxQueued int
+	// Options are passed to every retrieval, before the options of the job itself
+	Options []gitjacker.Option
+	// Analysers are run over the repository of every job which does not disable analysis
+	Analysers []gitjacker.Analyser
+	// OnComplete is called with every job which finishes successfully
+	OnComplete func(job Job)
+}
+
+// pollInterval is how often idle workers look for queued jobs, in case a submission did not wake them
+const pollInterval = time.Second
+
+// Server queues retrieval jobs, runs them in the background and exposes them over a REST API. Queued jobs are
+// only held in the store, which workers poll, so a submission never waits for a worker.
+type Server struct {
+	config Config
+	store  *Store
+	cache  *gitjacker.ObjectCache
+	wake   chan struct{}
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+	feeds map[string]*feed
+}
+
+func New(config Config) (*Server, error) {
+
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.MaxQueued < 1 {
+		config.MaxQueued = 1024
+	}
+
+	store, err := NewStore(config.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		config: config,
+		store:  store,
+		wake:   make(chan struct{}, 1),
+		jobs:   make(map[string]*Job),
+		feeds:  make(map[string]*feed),
+	}
+
+	if config.CacheDir != "" {
+		if s.cache, err = gitjacker.NewObjectCache(config.CacheDir); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		// jobs interrupted by a restart are started again from scratch
+		if job.Status == JobRunning {
+			job.Status = JobQueued
+			job.StartedAt = nil
+			job.Progress = Progress{}
+			if err := store.Save(job); err != nil {
+				return nil, err
+			}
+		}
+		s.jobs[job.ID] = job
+		s.order = append(s.order, job.ID)
+		s.feeds[job.ID] = newFeed(job.finished())
+	}
+
+	return s, nil
+}
+
+// Run processes queued jobs until the context is cancelled
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if id, ok := s.claim(); ok {
+					s.runJob(ctx, id)
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.wake:
+				case <-time.After(pollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+var ErrInvalidRequest = fmt.Errorf("invalid job request")
+var ErrQueueFull = fmt.Errorf("too many jobs are queued")
+
+// claim marks the oldest queued job as running, so that no other worker takes it
+func (s *Server) claim() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.order {
+		job := s.jobs[id]
+		if job.Status != JobQueued {
+			continue
+		}
+		started := time.Now().UTC()
+		job.Status = JobRunning
+		job.StartedAt = &started
+		if err := s.store.Save(job); err != nil {
+			logrus.Errorf("Failed to save job %s: %s", id, err)
+		}
+		return id, true
+	}
+	return "", false
+}
+
+// queued counts the jobs waiting for a worker
+func (s *Server) queued() int {
+	var count int
+	for _, job := range s.jobs {
+		if job.Status == JobQueued {
+			count++
+		}
+	}
+	return count
+}
+
+// Submit validates and queues a new job
+func (s *Server) Submit(request JobRequest) (*Job, error) {
+
+	if len(request.Targets) == 0 {
+		return nil, fmt.Errorf("%w: at least one target is required", ErrInvalidRequest)
+	}
+	for _, target := range request.Targets {
+		if _, err := parseTarget(target); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := request.options(); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Request:   request,
+		Status:    JobQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	if s.queued() >= s.config.MaxQueued {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d jobs are waiting to run", ErrQueueFull, s.config.MaxQueued)
+	}
+	if err := s.store.Save(job); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.jobs[id] = job
+	s.order = append(s.order, id)
+	s.feeds[id] = newFeed(false)
+	snapshot := *job
+	s.mu.Unlock()
+
+	// wake an idle worker, unless one has already been woken
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return &snapshot, nil
+}
+
+// Jobs returns a snapshot of every job, oldest first
+func (s *Server) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, *s.jobs[id])
+	}
+	return jobs
+}
+
+// Job returns a snapshot of a single job
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (s *Server) update(id string, fn func(job *Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	fn(job)
+	if err := s.store.Save(job); err != nil {
+		logrus.Errorf("Failed to save job %s: %s", id, err)
+	}
+}
+
+func (s *Server) runJob(ctx context.Context, id string) {
+
+	s.mu.Lock()
+	job := s.jobs[id]
+	request := job.Request
+	feed := s.feeds[id]
+	s.mu.Unlock()
+
+	summary, err := s.retrieve(ctx, id, request, func(event gitjacker.Event) {
+		feed.publish(event)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch event.Kind {
+		case gitjacker.EventPhase:
+			job.Progress.Phase = event.Phase
+		case gitjacker.EventObjectFound:
+			job.Progress.FoundObjects++
+		case gitjacker.EventObjectMissing:
+			job.Progress.MissingObjects++
+		}
+	})
+
+	if ctx.Err() != nil {
+		// leave the job running, so it is queued again when the server restarts
+		feed.close()
+		return
+	}
+
+	finished := time.Now().UTC()
+	s.update(id, func(job *Job) {
+		job.FinishedAt = &finished
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = JobSucceeded
+		job.Summary = summary
+		job.Progress.FoundObjects = len(summary.FoundObjects)
+		job.Progress.MissingObjects = len(summary.MissingObjects)
+	})
+	feed.close()
+
+	if err == nil && s.config.OnComplete != nil {
+		if snapshot, ok := s.Job(id); ok {
+			s.config.OnComplete(snapshot)
+		}
+	}
+}
+
+func (s *Server) retrieve(ctx context.Context, id string, request JobRequest, handler gitjacker.EventHandler) (*gitjacker.Summary, error) {
+
+	jobOpts, err := request.options()
+	if err != nil {
+		return nil, err
+	}
+	// the job's options come last, so that they override the server's
+	baseOpts := append(append([]gitjacker.Option{}, s.config.Options...), jobOpts...)
+
+	var fetchers []gitjacker.Fetcher
+	for _, target := range request.Targets {
+		u, err := parseTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		fetchers = append(fetchers, gitjacker.NewTargetFetcher(u, baseOpts...))
+	}
+
+	var fetcher gitjacker.Fetcher = fetchers[0]
+	if len(fetchers) > 1 {
+		fetcher = gitjacker.NewMultiFetcher(fetchers...)
+	}
+
+	opts := append([]gitjacker.Option{gitjacker.WithEventHandler(handler)}, baseOpts...)
+	if request.analyse() {
+		for _, analyser := range s.config.Analysers {
+			opts = append(opts, gitjacker.WithAnalyser(analyser))
+		}
+	}
+	if request.Cache && s.cache != nil {
+		opts = append(opts, gitjacker.WithCache(s.cache))
+	}
+
+	return gitjacker.NewWithFetcher(fetcher, s.store.OutputDir(id), opts...).Run(ctx)
+}
+
+func parseTarget(rawURL string) (*url.URL, error) {
+	rawURL = strings.TrimSuffix(rawURL, "/.git/")
+	rawURL = strings.TrimSuffix(rawURL, "/.git")
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid target %q: %s", ErrInvalidRequest, rawURL, err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%w: invalid target %q: must be absolute", ErrInvalidRequest, rawURL)
+	}
+	return u, nil
+}
+
+func newJobID() (string, error) {
+	buffer := make([]byte, 8)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/testutil"
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+	"github.com/magiconair/properties/assert"
+)
+
+// newExposedSite creates a repository with a single commit and serves its working directory, .git included
+func newExposedSite(t *testing.T) (*httptest.Server, string) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.Git(t, dir, "init", "-q")
+	testutil.Write(t, dir, "hello.php", "<?php\necho 'hello';\n")
+	testutil.Commit(t, dir, "first commit")
+	return httptest.NewServer(http.FileServer(http.Dir(dir))), dir
+}
+
+func TestJobLifecycle(t *testing.T) {
+	site, siteDir := newExposedSite(t)
+	defer site.Close()
+	defer func() { _ = os.RemoveAll(siteDir) }()
+
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	s, err := New(Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	api := httptest.NewServer(s)
+	defer api.Close()
+
+	body, _ := json.Marshal(JobRequest{Targets: []string{site.URL}})
+	resp, err := http.Post(api.URL+"/jobs", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusCreated)
+
+	// the event stream ends when the job finishes
+	resp, err = http.Get(api.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []gitjacker.Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event gitjacker.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, len(events) > 0, true)
+	assert.Equal(t, events[len(events)-1].Phase, gitjacker.PhaseComplete)
+
+	finished, ok := s.Job(job.ID)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, finished.Status, JobSucceeded)
+
+	resp, err = http.Get(api.URL + "/jobs/" + job.ID + "/summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary gitjacker.Summary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, summary.Status, gitjacker.StatusSuccess)
+	assert.Equal(t, finished.Progress.FoundObjects, len(summary.FoundObjects))
+
+	resp, err = http.Get(api.URL + "/jobs/" + job.ID + "/artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(gz)
+	var content []byte
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == job.ID+"/hello.php" {
+			content, _ = ioutil.ReadAll(archive)
+		}
+	}
+	assert.Equal(t, string(content), "<?php\necho 'hello';\n")
+}
+
+func TestInterruptedJobsAreRequeued(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	store, err := NewStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now().UTC()
+	for _, job := range []*Job{
+		{ID: "a", Status: JobSucceeded, CreatedAt: started.Add(-time.Minute), Request: JobRequest{Targets: []string{"http://a.example"}}},
+		{ID: "b", Status: JobRunning, CreatedAt: started, StartedAt: &started, Request: JobRequest{Targets: []string{"http://b.example"}}},
+	} {
+		if err := store.Save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := New(Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := s.Jobs()
+	assert.Equal(t, len(jobs), 2)
+	assert.Equal(t, jobs[0].Status, JobSucceeded)
+	assert.Equal(t, jobs[1].Status, JobQueued)
+	assert.Equal(t, s.queued(), 1)
+}
+
+func TestSubmitRefusesWhenQueueIsFull(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	// no workers are running, so nothing leaves the queue
+	s, err := New(Config{DataDir: dataDir, MaxQueued: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := httptest.NewServer(s)
+	defer api.Close()
+
+	var statuses []int
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(api.URL+"/jobs", "application/json", bytes.NewReader([]byte(`{"targets":["http://victim.example"]}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	assert.Equal(t, statuses, []int{http.StatusCreated, http.StatusServiceUnavailable})
+	assert.Equal(t, len(s.Jobs()), 1)
+
+	// queued jobs are only held in the store, so they are picked up after a restart
+	restarted, err := New(Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, restarted.queued(), 1)
+}
+
+func TestSubmitRejectsInvalidTargets(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	s, err := New(Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := httptest.NewServer(s)
+	defer api.Close()
+
+	resp, err := http.Post(api.URL+"/jobs", "application/json", bytes.NewReader([]byte(`{"targets":["not-a-url"]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, len(s.Jobs()), 0)
+}
+
+func TestSubmitRejectsInvalidOptions(t *testing.T) {
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	s, err := New(Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range []JobRequest{
+		{Targets: []string{"http://victim.example"}, Timeout: "soon"},
+		{Targets: []string{"http://victim.example"}, Timeout: "-1s"},
+		{Targets: []string{"http://victim.example"}, Timeout: "48h"},
+		{Targets: []string{"http://victim.example"}, Proxy: "127.0.0.1:8080"},
+		{Targets: []string{"http://victim.example"}, Proxy: "ftp://127.0.0.1"},
+	} {
+		_, err := s.Submit(request)
+		assert.Equal(t, errors.Is(err, ErrInvalidRequest), true, fmt.Sprint(request))
+	}
+	assert.Equal(t, len(s.Jobs()), 0)
+
+	_, err = s.Submit(JobRequest{Targets: []string{"http://victim.example"}, Timeout: "30s", Proxy: "socks5://127.0.0.1:9050"})
+	assert.Equal(t, err, nil)
+}
+
+func TestJobsCanDisableAnalysis(t *testing.T) {
+	site, siteDir := newExposedSite(t)
+	defer site.Close()
+	defer func() { _ = os.RemoveAll(siteDir) }()
+
+	dataDir, err := ioutil.TempDir(os.TempDir(), "gjtest_serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	var mu sync.Mutex
+	analysed := 0
+	s, err := New(Config{DataDir: dataDir, Analysers: []gitjacker.Analyser{
+		func(ctx context.Context, outputDir string, summary *gitjacker.Summary) error {
+			mu.Lock()
+			defer mu.Unlock()
+			analysed++
+			return nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	disabled := false
+	for _, request := range []JobRequest{
+		{Targets: []string{site.URL}, Analysis: &disabled},
+		{Targets: []string{site.URL}, Timeout: "5s"},
+	} {
+		job, err := s.Submit(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(time.Second * 10); ; {
+			if current, _ := s.Job(job.ID); current.finished() {
+				assert.Equal(t, current.Status, JobSucceeded)
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s did not finish", job.ID)
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, analysed, 1)
+}
+
+func TestFeedIsBounded(t *testing.T) {
+	defer func(retention time.Duration) { feedRetention = retention }(feedRetention)
+	feedRetention = time.Millisecond * 10
+
+	f := newFeed(false)
+	for i := 0; i < maxFeedEvents*2; i++ {
+		f.publish(gitjacker.Event{Kind: gitjacker.EventObjectFound, Hash: fmt.Sprint(i)})
+	}
+
+	events, next, closed, _ := f.since(0)
+	assert.Equal(t, len(events) <= maxFeedEvents, true)
+	assert.Equal(t, events[len(events)-1].Hash, fmt.Sprint(maxFeedEvents*2-1))
+	assert.Equal(t, next, maxFeedEvents*2)
+	assert.Equal(t, closed, false)
+
+	f.close()
+	time.Sleep(time.Millisecond * 100)
+
+	events, next, closed, _ = f.since(0)
+	assert.Equal(t, len(events), 0)
+	assert.Equal(t, next, maxFeedEvents*2)
+	assert.Equal(t, closed, true)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liamg/gitjacker/pkg/gitjacker"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// JobRequest is the body accepted when submitting a job
+type JobRequest struct {
+	// Targets is the website followed by any mirrors serving the same repository
+	Targets []string `json:"targets"`
+	// Cache enables the server's shared object cache, if one is configured
+	Cache bool `json:"cache"`
+	// Timeout overrides the server's timeout for each HTTP request, as a duration such as "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// Proxy sends the job's requests through an HTTP or SOCKS5 proxy, instead of the server's proxy
+	Proxy string `json:"proxy,omitempty"`
+	// Analysis runs the server's analysers over the retrieved repository, and is enabled unless set to false
+	Analysis *bool `json:"analysis,omitempty"`
+}
+
+// maxJobTimeout is the longest request timeout a job may ask for
+const maxJobTimeout = time.Hour
+
+// options validates the retrieval options of a job, returning them ready to pass to the retriever
+func (r JobRequest) options() ([]gitjacker.Option, error) {
+	var opts []gitjacker.Option
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil || timeout <= 0 || timeout > maxJobTimeout {
+			return nil, fmt.Errorf("%w: invalid timeout %q: must be a duration of up to %s", ErrInvalidRequest, r.Timeout, maxJobTimeout)
+		}
+		opts = append(opts, gitjacker.WithTimeout(timeout))
+	}
+	if r.Proxy != "" {
+		proxy, err := url.Parse(r.Proxy)
+		if err != nil || !proxy.IsAbs() || proxy.Host == "" {
+			return nil, fmt.Errorf("%w: invalid proxy %q", ErrInvalidRequest, r.Proxy)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("%w: invalid proxy %q: scheme must be http, https or socks5", ErrInvalidRequest, r.Proxy)
+		}
+		opts = append(opts, gitjacker.WithProxy(proxy))
+	}
+	return opts, nil
+}
+
+// analyse reports whether the job's repository should be analysed
+func (r JobRequest) analyse() bool {
+	return r.Analysis == nil || *r.Analysis
+}
+
+// Progress is a running count of what a job has retrieved so far
+type Progress struct {
+	Phase          string `json:"phase"`
+	FoundObjects   int    `json:"found_objects"`
+	MissingObjects int    `json:"missing_objects"`
+}
+
+type Job struct {
+	ID         string             `json:"id"`
+	Request    JobRequest         `json:"request"`
+	Status     JobStatus          `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Progress   Progress           `json:"progress"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Summary    *gitjacker.Summary `json:"-"`
+}
+
+func (j *Job) finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+// Store persists jobs as one JSON file each, alongside their summaries and output directories
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store: %w", err)
+	}
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+func (s *Store) jobPath(id string) string {
+	return filepath.Join(s.dir, "jobs", id+".json")
+}
+
+func (s *Store) summaryPath(id string) string {
+	return filepath.Join(s.dir, "jobs", id+".summary.json")
+}
+
+// OutputDir is where the repository for a job is retrieved to
+func (s *Store) OutputDir(id string) string {
+	return filepath.Join(s.dir, "output", id)
+}
+
+func (s *Store) Save(job *Job) error {
+	if err := writeJSON(s.jobPath(job.ID), job); err != nil {
+		return err
+	}
+	if job.Summary != nil {
+		return writeJSON(s.summaryPath(job.ID), job.Summary)
+	}
+	return nil
+}
+
+// Load returns every stored job, oldest first
+func (s *Store) Load() ([]*Job, error) {
+
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, "jobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".summary.json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, "jobs", name))
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("failed to parse job %s: %w", name, err)
+		}
+		if data, err := ioutil.ReadFile(s.summaryPath(job.ID)); err == nil {
+			var summary gitjacker.Summary
+			if err := json.Unmarshal(data, &summary); err == nil {
+				job.Summary = &summary
+			}
+		}
+		jobs = append(jobs, &job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// writeJSON replaces a file atomically so a crash never leaves a truncated record
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
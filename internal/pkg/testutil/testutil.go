@@ -0,0 +1,56 @@
+// Package testutil builds git repositories and retrieval summaries for tests, so that every package's tests commit as
+// the same author and with the same clock
+package testutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Author and Email identify the author and committer of every commit made by Git
+const (
+	Author = "Dev"
+	Email  = "dev@example.com"
+)
+
+// clock is the unix time of the last git command, advanced a minute by each one so that history is ordered
+var clock int64 = 1600000000
+
+// Git runs git in dir and returns its trimmed output, failing the test if it fails
+func Git(t *testing.T, dir string, args ...string) string {
+	date := fmt.Sprintf("%d +0000", atomic.AddInt64(&clock, 60))
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+Author, "GIT_AUTHOR_EMAIL="+Email, "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME="+Author, "GIT_COMMITTER_EMAIL="+Email, "GIT_COMMITTER_DATE="+date,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %s", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Write writes a file within dir, creating its parent directories
+func Write(t *testing.T, dir string, name string, content string) {
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Commit commits every change in dir and returns the hash of the new commit
+func Commit(t *testing.T, dir string, message string) string {
+	Git(t, dir, "add", "-A")
+	Git(t, dir, "commit", "-q", "-m", message)
+	return Git(t, dir, "rev-parse", "HEAD")
+}
@@ -0,0 +1,100 @@
+package webui
+
+import (
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// graphRow is a commit in the commit graph, drawn with one column per line of history
+type graphRow struct {
+	*gitrepo.Commit
+	Graph string
+	// MissingParents could not be recovered, so the line of history ends here
+	MissingParents []string
+}
+
+// buildGraph assigns commits, newest first, to columns so that each commit sits below its children
+func buildGraph(commits []*gitrepo.Commit, repo *gitrepo.Repository) ([]graphRow, error) {
+
+	// lanes holds the commit expected next in each column, or "" for a free column
+	var lanes []string
+	var rows []graphRow
+
+	for _, commit := range commits {
+		column := -1
+		for i, expected := range lanes {
+			if expected != commit.Hash {
+				continue
+			}
+			if column < 0 {
+				column = i
+			} else {
+				// several children meet at this commit, so the other columns end here
+				lanes[i] = ""
+			}
+		}
+		if column < 0 {
+			column = freeLane(&lanes)
+		}
+
+		cells := make([]string, len(lanes))
+		for i, expected := range lanes {
+			switch {
+			case i == column:
+				cells[i] = "●"
+			case expected != "":
+				cells[i] = "│"
+			default:
+				cells[i] = " "
+			}
+		}
+
+		row := graphRow{Commit: commit, Graph: strings.Join(cells, " ")}
+		lanes[column] = ""
+		for i, parent := range commit.Parents {
+			exists, err := repo.Exists(parent)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				row.MissingParents = append(row.MissingParents, parent)
+				continue
+			}
+			if contains(lanes, parent) {
+				continue
+			}
+			if i == 0 || lanes[column] == "" {
+				lanes[column] = parent
+				continue
+			}
+			lanes[freeLane(&lanes)] = parent
+		}
+
+		for len(lanes) > 0 && lanes[len(lanes)-1] == "" {
+			lanes = lanes[:len(lanes)-1]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// freeLane finds an unused column, adding one if there are none
+func freeLane(lanes *[]string) int {
+	for i, expected := range *lanes {
+		if expected == "" {
+			return i
+		}
+	}
+	*lanes = append(*lanes, "")
+	return len(*lanes) - 1
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
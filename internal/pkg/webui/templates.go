@@ -0,0 +1,242 @@
+package webui
+
+import (
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+)
+
+// CommitCount is the number of recovered commits
+func (t *Target) CommitCount() int {
+	_ = t.load()
+	return len(t.graph)
+}
+
+// Findings lists everything worth looking at in the target
+func (t *Target) Findings() []Finding {
+	_ = t.load()
+	return t.findings
+}
+
+// RefsAt lists the refs pointing at a commit
+func (t *Target) RefsAt(hash string) []string {
+	return t.refs[hash]
+}
+
+var funcs = template.FuncMap{
+	"short": func(hash string) string {
+		if len(hash) > 8 {
+			return hash[:8]
+		}
+		return hash
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+	"op": func(op gitrepo.LineOp) string {
+		switch op {
+		case gitrepo.OpAdd:
+			return "add"
+		case gitrepo.OpDelete:
+			return "del"
+		}
+		return "ctx"
+	},
+	"opchar": func(op gitrepo.LineOp) string { return string(op) },
+	"join":   strings.Join,
+	"inc":    func(i int) int { return i + 1 },
+}
+
+var pages = map[string]*template.Template{}
+
+func init() {
+	for name, source := range map[string]string{
+		"index":    indexSource,
+		"findings": findingsSource,
+		"overview": overviewSource,
+		"commits":  commitsSource,
+		"commit":   commitSource,
+		"tree":     treeSource,
+		"history":  historySource,
+		"config":   configSource,
+		"error":    errorSource,
+	} {
+		pages[name] = template.Must(template.Must(template.New(name).Funcs(funcs).Parse(layoutSource)).Parse(source))
+	}
+}
+
+const layoutSource = `{{define "layout"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{template "title" .}} - gitjacker</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;margin:0;color:#1f2328;background:#f6f8fa}
+body>header{background:#24292f;padding:.8em 2em;display:flex;gap:1.5em;align-items:baseline}
+body>header a{color:#fff;text-decoration:none}
+body>header .brand{font-weight:bold;font-size:1.2em}
+main{padding:1em 2em 3em;max-width:1400px}
+section{background:#fff;border:1px solid #d0d7de;border-radius:6px;padding:1em 1.5em;margin:1.5em 0}
+h1{font-size:1.4em;word-break:break-all}
+h2{margin-top:0;font-size:1.2em}
+a{color:#0969da}
+table{border-collapse:collapse;width:100%}
+td,th{text-align:left;padding:.3em .7em;border-bottom:1px solid #eaeef2;vertical-align:top}
+code,pre,.mono,.graph{font-family:ui-monospace,SFMono-Regular,Menlo,Consolas,monospace;font-size:.85em}
+nav.tabs{margin:.5em 0 1em}
+nav.tabs a{margin-right:1em}
+.note{color:#57606a;font-style:italic}
+.missing,.missing a{color:#cf222e}
+.hash{color:#57606a}
+.ref{background:#ddf4ff;color:#0969da;border-radius:1em;padding:0 .5em;font-size:.8em;margin-right:.3em}
+.graph{white-space:pre;color:#8250df;width:1%}
+.code{display:flex;background:#f6f8fa;border-radius:6px;overflow:auto;max-height:80vh}
+.code pre{margin:0;padding:1em .5em}
+.gutter{text-align:right;color:#8c959f;user-select:none;border-right:1px solid #d0d7de}
+.gutter a{color:inherit;text-decoration:none}
+.gutter a:target{background:#fff8c5;color:#1f2328}
+.k{color:#cf222e}.s{color:#0a3069}.n{color:#0550ae}.c{color:#6e7781;font-style:italic}
+.diff{margin:.6em 0 1em}
+.diff h4{margin:.5em 0;font-weight:normal}
+.diff pre{padding:0;background:#f6f8fa;overflow:auto}
+.diff span{display:block;padding:0 1em;white-space:pre}
+.diff .add{background:#dafbe1}.diff .del{background:#ffebe9}.diff .hunk{background:#ddf4ff;color:#57606a}
+.stat-add{color:#1a7f37}.stat-del{color:#cf222e}
+table.blame td{border:0;padding:0 .7em;white-space:pre}
+table.blame tr.first td{border-top:1px solid #eaeef2}
+table.blame td.line{text-align:right;color:#8c959f}
+tr.marked td{background:#fff8c5}
+</style>
+</head>
+<body>
+<header><a class="brand" href="/">gitjacker</a><a href="/findings">Findings</a></header>
+<main>
+{{template "content" .}}
+</main>
+</body>
+</html>
+{{end}}
+{{define "nav"}}<h1>{{.Name}}</h1>
+<nav class="tabs"><a href="/r/{{.ID}}">Overview</a><a href="/r/{{.ID}}/tree/HEAD/">Files</a><a href="/r/{{.ID}}/commits">Commits</a><a href="/r/{{.ID}}/config">Config</a></nav>{{end}}
+{{define "refs"}}{{range .}}<span class="ref">{{.}}</span>{{end}}{{end}}
+{{define "findingTable"}}<table>
+<tr><th>Target</th><th>Finding</th><th>Location</th></tr>
+{{range .}}<tr><td><a href="/r/{{.Target.ID}}">{{.Target.Name}}</a></td><td>{{.Description}}</td><td><a href="{{.Link}}"><code>{{.Path}}{{if .Line}}:{{.Line}}{{end}}</code></a></td></tr>
+{{else}}<tr><td colspan="3" class="note">Nothing was found.</td></tr>
+{{end}}</table>{{end}}`
+
+const indexSource = `{{define "title"}}Recovered repositories{{end}}
+{{define "content"}}<h1>Recovered repositories</h1>
+<section>
+<table>
+<tr><th>Target</th><th>Commits</th><th>Findings</th><th>Directory</th></tr>
+{{range .Targets}}<tr><td><a href="/r/{{.ID}}">{{.Name}}</a></td><td>{{.CommitCount}}</td><td>{{len .Findings}}</td><td><code>{{.Dir}}</code></td></tr>
+{{end}}</table>
+</section>{{end}}`
+
+const findingsSource = `{{define "title"}}Findings{{end}}
+{{define "content"}}<h1>Findings</h1>
+<section>{{template "findingTable" .}}</section>{{end}}`
+
+const overviewSource = `{{define "title"}}{{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2>Summary</h2>
+<table>
+<tr><th>Directory</th><td><code>{{.Dir}}</code></td></tr>
+<tr><th>Recovered commits</th><td><a href="/r/{{.ID}}/commits">{{.Commits}}</a></td></tr>
+{{if .Note}}<tr><th>Files at HEAD</th><td class="note">{{.Note}}</td></tr>
+{{else}}<tr><th>Files at HEAD</th><td><a href="/r/{{.ID}}/tree/HEAD/">{{.Files}}</a>{{if .Missing}}, <span class="missing">{{.Missing}} not recovered</span>{{end}}</td></tr>
+{{if .MissingDirs}}<tr><th>Directories not recovered</th><td class="missing">{{range .MissingDirs}}<div><code>{{if .}}{{.}}/{{else}}(root){{end}}</code></div>{{end}}</td></tr>{{end}}
+{{end}}<tr><th>Refs</th><td>{{range .Refs}}<div><a href="/r/{{$.ID}}/commit/{{.Hash}}">{{.Name}}</a> <code class="hash">{{short .Hash}}</code></div>{{else}}<span class="note">none</span>{{end}}</td></tr>
+</table>
+</section>
+<section>
+<h2>Findings</h2>
+{{template "findingTable" .Findings}}
+</section>{{end}}`
+
+const commitsSource = `{{define "title"}}Commits - {{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2>Commits</h2>
+<p class="note">All recovered history, including commits only named in reflogs. Lines end where a parent could not be recovered.</p>
+<table>
+{{range .Rows}}<tr><td class="graph">{{.Graph}}</td><td><a href="/r/{{$.ID}}/commit/{{.Hash}}"><code>{{short .Hash}}</code></a></td><td>{{template "refs" ($.RefsAt .Hash)}}{{.Subject}}{{range .MissingParents}} <span class="missing mono" title="This parent could not be recovered">parent {{short .}} not recovered</span>{{end}}</td><td>{{.Author.Name}}</td><td class="mono">{{date .Author.When}}</td></tr>
+{{else}}<tr><td class="note">No commits were recovered.</td></tr>
+{{end}}</table>
+<p>{{if .Previous}}<a href="?page={{.Previous}}">Newer</a> {{end}}{{if .Next}}<a href="?page={{.Next}}">Older</a>{{end}}</p>
+</section>{{end}}`
+
+const commitSource = `{{define "title"}}{{short .Commit.Hash}} - {{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2>{{template "refs" .Refs}}{{.Commit.Subject}}</h2>
+<table>
+<tr><th>Commit</th><td><code>{{.Commit.Hash}}</code> <a href="/r/{{.ID}}/tree/{{.Commit.Hash}}/">Browse files</a></td></tr>
+<tr><th>Author</th><td>{{.Commit.Author}} <span class="mono">{{date .Commit.Author.When}}</span></td></tr>
+<tr><th>Committer</th><td>{{.Commit.Committer}} <span class="mono">{{date .Commit.Committer.When}}</span></td></tr>
+<tr><th>Parents</th><td>{{range .Parents}}<div>{{if .Missing}}<code class="missing" title="This commit could not be recovered">{{.Hash}} (not recovered)</code>{{else}}<a href="/r/{{$.ID}}/commit/{{.Hash}}"><code>{{.Hash}}</code></a>{{end}}</div>{{else}}<span class="note">none</span>{{end}}</td></tr>
+</table>
+<pre>{{.Commit.Message}}</pre>
+</section>
+<section>
+<h2>Changes</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>{{end}}
+{{range .Changes}}<div class="diff">
+{{if eq .Status "unknown"}}<h4 class="missing"><code>{{.Path}}/</code> could not be recovered in one of the commits, so changes within it are unknown</h4>
+{{else}}<h4><a href="/r/{{$.ID}}/history/{{$.Commit.Hash}}/{{.Path}}"><code>{{.Path}}</code></a> {{.Status}} <span class="stat-add">+{{.Added}}</span> <span class="stat-del">-{{.Deleted}}</span></h4>
+{{if .Missing}}<p class="note missing">Content could not be recovered.</p>{{else if .Binary}}<p class="note">Binary file.</p>{{else if .TooLarge}}<p class="note">File too large to compare.</p>{{else if .Hunks}}<pre>{{range .Hunks}}<span class="hunk">@@ -{{.OldStart}},{{.OldLines}} +{{.NewStart}},{{.NewLines}} @@</span>{{range .Lines}}<span class="{{op .Op}}">{{opchar .Op}}{{.Text}}</span>{{end}}{{end}}</pre>{{end}}
+{{end}}</div>
+{{end}}</section>{{end}}`
+
+const treeSource = `{{define "title"}}{{if .Path}}{{.Path}}{{else}}Files{{end}} - {{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2><a href="/r/{{.ID}}/tree/{{.Commit.Hash}}/">{{.Name}}</a>{{range .Crumbs}} / <a href="/r/{{$.ID}}/tree/{{$.Commit.Hash}}/{{.Path}}">{{.Name}}</a>{{end}}</h2>
+<p class="note">At commit <a href="/r/{{.ID}}/commit/{{.Commit.Hash}}"><code>{{short .Commit.Hash}}</code></a> {{.Commit.Subject}}</p>
+{{with .File}}
+<p><code class="hash">{{.Hash}}</code>{{if .Size}} &middot; {{.Size}} bytes{{end}} &middot; <a href="/r/{{$.ID}}/history/{{$.Commit.Hash}}/{{$.Path}}">History and blame</a>{{if .Size}} &middot; <a href="/r/{{$.ID}}/raw/{{$.Commit.Hash}}/{{$.Path}}">Download</a>{{end}}</p>
+{{if .Note}}<p class="note">{{.Note}}</p>{{else}}<div class="code"><pre class="gutter">{{range .Lines}}<a id="L{{.}}" href="#L{{.}}">{{.}}</a>
+{{end}}</pre><pre>{{.Content}}</pre></div>{{end}}
+{{else}}
+<table>
+{{range .Entries}}<tr{{if .Missing}} class="missing" title="This could not be recovered"{{end}}><td><a href="/r/{{$.ID}}/tree/{{$.Commit.Hash}}/{{.Path}}">{{.Name}}{{if .Dir}}/{{end}}</a>{{if .Missing}} (not recovered){{end}}</td></tr>
+{{else}}<tr><td class="note">This directory is empty.</td></tr>
+{{end}}</table>
+{{end}}
+</section>{{end}}`
+
+const historySource = `{{define "title"}}History of {{.Path}} - {{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2>History of <a href="/r/{{.ID}}/tree/{{.Commit.Hash}}/{{.Path}}"><code>{{.Path}}</code></a></h2>
+<table>
+<tr><th>Commit</th><th>Change</th><th>Author</th><th>Date</th><th>Content</th></tr>
+{{range .Versions}}<tr><td><a href="/r/{{$.ID}}/commit/{{.Commit.Hash}}"><code>{{short .Commit.Hash}}</code></a> {{.Commit.Subject}}</td><td>{{if eq .Status "unknown"}}<span class="note" title="The parent commit could not be recovered for comparison">present</span>{{else}}{{.Status}}{{end}}</td><td>{{.Commit.Author.Name}}</td><td class="mono">{{date .Commit.Author.When}}</td><td>{{if .Recovered}}<a href="/r/{{$.ID}}/tree/{{.Commit.Hash}}/{{$.Path}}">view</a>{{else if .Hash}}<span class="missing">not recovered</span>{{end}}</td></tr>
+{{else}}<tr><td colspan="5" class="note">No recovered commit changed this file.</td></tr>
+{{end}}</table>
+</section>
+<section>
+<h2>Blame</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>{{else}}<p class="note">Lines marked ^ were already present in the oldest version which could be recovered, so they may be older.</p>
+<table class="blame">
+{{range .Blame}}<tr{{if .First}} class="first"{{end}}><td>{{if .First}}<a href="/r/{{$.ID}}/commit/{{.Commit.Hash}}"><code>{{if .Boundary}}^{{end}}{{short .Commit.Hash}}</code></a> {{.Commit.Author.Name}}{{end}}</td><td class="line mono">{{.Number}}</td><td class="mono">{{.Content}}</td></tr>
+{{end}}</table>{{end}}
+</section>{{end}}`
+
+const configSource = `{{define "title"}}Config - {{.Name}}{{end}}
+{{define "content"}}{{template "nav" .Target}}
+<section>
+<h2><code>.git/config</code></h2>
+<table class="blame">
+{{range $i, $line := .Lines}}{{$number := inc $i}}{{$mark := index $.Marks $number}}<tr{{if $mark}} class="marked" title="{{$mark}}"{{end}}><td class="line mono" id="L{{$number}}">{{$number}}</td><td class="mono">{{$line}}</td><td>{{$mark}}</td></tr>
+{{end}}</table>
+</section>{{end}}`
+
+const errorSource = `{{define "title"}}Error{{end}}
+{{define "content"}}<h1>Sorry</h1>
+<section><p>{{.}}</p></section>{{end}}`
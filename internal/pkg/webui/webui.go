@@ -0,0 +1,642 @@
+package webui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/liamg/gitjacker/internal/pkg/analysis"
+	"github.com/liamg/gitjacker/internal/pkg/gitrepo"
+	"github.com/liamg/gitjacker/internal/pkg/report"
+	"github.com/sirupsen/logrus"
+)
+
+// limits which keep pages quick to render and open
+const (
+	commitsPerPage = 200
+	maxFileSize    = 1024 * 1024
+)
+
+// UI serves a set of recovered repositories as a read-only website
+type UI struct {
+	Targets []*Target
+	byID    map[string]*Target
+}
+
+// Target is one recovered repository
+type Target struct {
+	ID   string
+	Name string
+	Dir  string
+
+	repo *gitrepo.Repository
+
+	once     sync.Once
+	loadErr  error
+	graph    []graphRow
+	refs     map[string][]string
+	findings []Finding
+}
+
+// Finding is something worth looking at in a target, shown in the findings panel
+type Finding struct {
+	Target      *Target
+	Path        string
+	Line        int
+	Description string
+	// Commit is the commit the finding was seen in, or empty for HEAD
+	Commit string
+}
+
+// Link points at the location of a finding
+func (f Finding) Link() string {
+	if f.Path == ".git/config" {
+		return fmt.Sprintf("/r/%s/config#L%d", f.Target.ID, f.Line)
+	}
+	rev := "HEAD"
+	if f.Commit != "" {
+		rev = f.Commit
+	}
+	return fmt.Sprintf("/r/%s/tree/%s/%s#L%d", f.Target.ID, rev, f.Path, f.Line)
+}
+
+var unsafeID = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// New opens every directory, each of which may be either a .git directory or the directory containing it
+func New(dirs []string) (*UI, error) {
+
+	ui := &UI{byID: make(map[string]*Target)}
+	for _, dir := range dirs {
+		repo, err := gitrepo.Open(dir)
+		if err != nil {
+			_ = ui.Close()
+			return nil, err
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			abs = dir
+		}
+		name := filepath.Base(abs)
+		if name == ".git" {
+			name = filepath.Base(filepath.Dir(abs))
+		}
+
+		id := unsafeID.ReplaceAllString(name, "-")
+		for n := 2; ui.byID[id] != nil; n++ {
+			id = fmt.Sprintf("%s-%d", unsafeID.ReplaceAllString(name, "-"), n)
+		}
+
+		target := &Target{ID: id, Name: name, Dir: abs, repo: repo}
+		ui.Targets = append(ui.Targets, target)
+		ui.byID[id] = target
+	}
+	return ui, nil
+}
+
+func (ui *UI) Close() error {
+	for _, target := range ui.Targets {
+		_ = target.repo.Close()
+	}
+	return nil
+}
+
+// load reads the history and findings of a target once, as recovered repositories do not change
+func (t *Target) load() error {
+	t.once.Do(func() {
+		t.refs = make(map[string][]string)
+		refs, err := t.repo.Refs()
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		for _, ref := range refs {
+			if hash, err := t.repo.ResolveRevision(ref.Hash); err == nil {
+				t.refs[hash] = append(t.refs[hash], strings.TrimPrefix(strings.TrimPrefix(ref.Name, "refs/heads/"), "refs/"))
+			}
+		}
+		if head, err := t.repo.Head(); err == nil {
+			t.refs[head] = append([]string{"HEAD"}, t.refs[head]...)
+		}
+
+		tips, err := t.repo.Tips()
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		commits, err := t.repo.Log(tips, 0)
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		if t.graph, err = buildGraph(commits, t.repo); err != nil {
+			t.loadErr = err
+			return
+		}
+
+		config, err := t.repo.Config()
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		for _, secret := range config.Secrets {
+			t.findings = append(t.findings, Finding{Target: t, Path: ".git/config", Line: secret.Line, Description: secret.Description})
+		}
+
+		result, err := analysis.Scan(context.Background(), t.repo)
+		if err != nil {
+			t.loadErr = err
+			return
+		}
+		for _, secret := range result.Secrets {
+			t.findings = append(t.findings, Finding{
+				Target:      t,
+				Path:        secret.Path,
+				Line:        secret.Line,
+				Description: fmt.Sprintf("%s, %s", secret.Description, secret.History()),
+				Commit:      secret.Commit,
+			})
+		}
+		for _, credential := range result.Credentials {
+			t.findings = append(t.findings, Finding{
+				Target:      t,
+				Path:        credential.Path,
+				Line:        credential.Line,
+				Description: fmt.Sprintf("%s credentials %s, %s", credential.Format, credential.String(), credential.History()),
+				Commit:      credential.Commit,
+			})
+		}
+		for _, artifact := range result.Artifacts {
+			t.findings = append(t.findings, Finding{
+				Target:      t,
+				Path:        artifact.Path,
+				Description: fmt.Sprintf("%s, %s", artifact.Description, artifact.History()),
+				Commit:      artifact.Commit,
+			})
+		}
+	})
+	return t.loadErr
+}
+
+// ServeHTTP implements the website:
+//
+//	GET /                                  list of targets
+//	GET /findings                          findings across every target
+//	GET /r/{id}                            overview of a target
+//	GET /r/{id}/commits                    commit graph of all recovered history
+//	GET /r/{id}/commit/{hash}              a commit and its changes
+//	GET /r/{id}/tree/{rev}/{path}          a directory listing or file
+//	GET /r/{id}/history/{rev}/{path}       the history and blame of a file
+//	GET /r/{id}/raw/{rev}/{path}           download a file as it is
+//	GET /r/{id}/config                     the git config
+func (ui *UI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// recovered content is untrusted, so pages may not run scripts or load anything from elsewhere
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 5)
+	switch {
+	case parts[0] == "":
+		ui.render(w, http.StatusOK, "index", ui)
+		return
+	case parts[0] == "findings" && len(parts) == 1:
+		var findings []Finding
+		for _, target := range ui.Targets {
+			if err := target.load(); err != nil {
+				ui.renderError(w, http.StatusInternalServerError, err)
+				return
+			}
+			findings = append(findings, target.findings...)
+		}
+		ui.render(w, http.StatusOK, "findings", findings)
+		return
+	case parts[0] != "r" || len(parts) < 2:
+		ui.renderError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	target, ok := ui.byID[parts[1]]
+	if !ok {
+		ui.renderError(w, http.StatusNotFound, fmt.Errorf("no target called %q", parts[1]))
+		return
+	}
+	if err := target.load(); err != nil {
+		ui.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := ""
+	if len(parts) > 2 {
+		page = parts[2]
+	}
+	rev, filePath := "HEAD", ""
+	if len(parts) > 3 {
+		rev = parts[3]
+	}
+	if len(parts) > 4 {
+		filePath = path.Clean("/" + parts[4])[1:]
+	}
+
+	var err error
+	switch page {
+	case "":
+		err = ui.serveOverview(w, target)
+	case "commits":
+		err = ui.serveCommits(w, r, target)
+	case "commit":
+		err = ui.serveCommit(w, target, rev)
+	case "tree":
+		err = ui.serveTree(w, target, rev, filePath)
+	case "history":
+		err = ui.serveHistory(w, target, rev, filePath)
+	case "raw":
+		err = ui.serveRaw(w, target, rev, filePath)
+	case "config":
+		err = ui.serveConfig(w, target)
+	default:
+		err = errNotFound
+	}
+
+	switch {
+	case errors.Is(err, errNotFound), errors.Is(err, gitrepo.ErrPathNotFound):
+		ui.renderError(w, http.StatusNotFound, err)
+	case errors.Is(err, gitrepo.ErrMissingObject):
+		ui.renderError(w, http.StatusNotFound, fmt.Errorf("this could not be recovered: %w", err))
+	case err != nil:
+		ui.renderError(w, http.StatusInternalServerError, err)
+	}
+}
+
+var errNotFound = fmt.Errorf("not found")
+
+func (ui *UI) render(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pages[name].ExecuteTemplate(w, "layout", data); err != nil {
+		logrus.Errorf("Failed to render %s: %s", name, err)
+	}
+}
+
+func (ui *UI) renderError(w http.ResponseWriter, status int, err error) {
+	ui.render(w, status, "error", err.Error())
+}
+
+type overviewPage struct {
+	*Target
+	Refs        []refLink
+	Commits     int
+	Files       int
+	Missing     int
+	MissingDirs []string
+	Note        string
+}
+
+type refLink struct {
+	Name string
+	Hash string
+}
+
+func (ui *UI) serveOverview(w http.ResponseWriter, target *Target) error {
+
+	page := overviewPage{Target: target, Commits: len(target.graph)}
+	for hash, names := range target.refs {
+		for _, name := range names {
+			page.Refs = append(page.Refs, refLink{Name: name, Hash: hash})
+		}
+	}
+	sort.Slice(page.Refs, func(i, j int) bool { return page.Refs[i].Name < page.Refs[j].Name })
+
+	head, err := target.repo.Head()
+	if err != nil {
+		page.Note = "HEAD could not be resolved."
+	} else if commit, err := target.repo.Commit(head); err != nil {
+		page.Note = fmt.Sprintf("The HEAD commit %s could not be recovered.", head)
+	} else {
+		files, missingDirs, err := target.repo.Files(commit.Tree)
+		if err != nil {
+			return err
+		}
+		page.Files, page.MissingDirs = len(files), missingDirs
+		for _, file := range files {
+			if exists, err := target.repo.Exists(file.Hash); err == nil && !exists && file.Mode != "160000" {
+				page.Missing++
+			}
+		}
+	}
+
+	ui.render(w, http.StatusOK, "overview", page)
+	return nil
+}
+
+type commitsPage struct {
+	*Target
+	Rows     []graphRow
+	Page     int
+	Previous int
+	Next     int
+}
+
+func (ui *UI) serveCommits(w http.ResponseWriter, r *http.Request, target *Target) error {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * commitsPerPage
+	if start > len(target.graph) {
+		start = len(target.graph)
+	}
+	end := start + commitsPerPage
+	if end > len(target.graph) {
+		end = len(target.graph)
+	}
+
+	data := commitsPage{Target: target, Rows: target.graph[start:end], Page: page}
+	if page > 1 {
+		data.Previous = page - 1
+	}
+	if end < len(target.graph) {
+		data.Next = page + 1
+	}
+	ui.render(w, http.StatusOK, "commits", data)
+	return nil
+}
+
+type commitPage struct {
+	*Target
+	Commit  *gitrepo.Commit
+	Refs    []string
+	Parents []parentLink
+	Changes []changeView
+	Note    string
+}
+
+type parentLink struct {
+	Hash    string
+	Missing bool
+}
+
+type changeView struct {
+	gitrepo.Change
+	Added   int
+	Deleted int
+}
+
+func (ui *UI) serveCommit(w http.ResponseWriter, target *Target, rev string) error {
+
+	commit, err := target.commit(rev)
+	if err != nil {
+		return err
+	}
+
+	page := commitPage{Target: target, Commit: commit, Refs: target.refs[commit.Hash]}
+	for _, parent := range commit.Parents {
+		exists, err := target.repo.Exists(parent)
+		if err != nil {
+			return err
+		}
+		page.Parents = append(page.Parents, parentLink{Hash: parent, Missing: !exists})
+	}
+
+	changes, err := target.repo.DiffCommit(commit)
+	if errors.Is(err, gitrepo.ErrMissingObject) {
+		page.Note = "The parent commit could not be recovered, so changes are not available."
+	} else if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		added, deleted := change.Stats()
+		page.Changes = append(page.Changes, changeView{Change: change, Added: added, Deleted: deleted})
+	}
+
+	ui.render(w, http.StatusOK, "commit", page)
+	return nil
+}
+
+// commit resolves a revision to a commit which must have been recovered
+func (t *Target) commit(rev string) (*gitrepo.Commit, error) {
+	hash, err := t.repo.ResolveRevision(rev)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errNotFound, err)
+	}
+	return t.repo.Commit(hash)
+}
+
+type treePage struct {
+	*Target
+	Commit  *gitrepo.Commit
+	Path    string
+	Crumbs  []crumb
+	Entries []entryView
+	File    *fileView
+}
+
+type crumb struct {
+	Name string
+	Path string
+}
+
+type entryView struct {
+	Name    string
+	Path    string
+	Dir     bool
+	Missing bool
+}
+
+type fileView struct {
+	Hash    string
+	Size    int
+	Lines   []int
+	Content template.HTML
+	Note    string
+}
+
+func (ui *UI) serveTree(w http.ResponseWriter, target *Target, rev string, filePath string) error {
+
+	commit, err := target.commit(rev)
+	if err != nil {
+		return err
+	}
+	entry, err := target.repo.Lookup(commit.Tree, filePath)
+	if err != nil {
+		return err
+	}
+
+	page := treePage{Target: target, Commit: commit, Path: filePath}
+	if filePath != "" {
+		for i, name := range strings.Split(filePath, "/") {
+			page.Crumbs = append(page.Crumbs, crumb{Name: name, Path: strings.Join(strings.Split(filePath, "/")[:i+1], "/")})
+		}
+	}
+
+	switch {
+	case entry.IsSubmodule():
+		page.File = &fileView{Hash: entry.Hash, Note: "This is a submodule, pointing at a commit in another repository."}
+	case !entry.IsDir():
+		page.File = &fileView{Hash: entry.Hash}
+		content, err := target.repo.Blob(entry.Hash)
+		page.File.Size = len(content)
+		switch {
+		case errors.Is(err, gitrepo.ErrMissingObject):
+			page.File.Note = "This file could not be recovered at this commit. Its history may include earlier versions which were."
+		case err != nil:
+			return err
+		case gitrepo.IsBinary(content):
+			page.File.Note = "Binary file."
+		case len(content) > maxFileSize:
+			page.File.Note = "This file is too large to show here."
+		default:
+			page.File.Content = report.Highlight(filePath, string(content))
+			for i := range gitrepo.SplitLines(string(content)) {
+				page.File.Lines = append(page.File.Lines, i+1)
+			}
+		}
+	default:
+		entries, err := target.repo.Tree(entry.Hash)
+		if err != nil {
+			return err
+		}
+		for _, child := range entries {
+			exists, err := target.repo.Exists(child.Hash)
+			if err != nil {
+				return err
+			}
+			page.Entries = append(page.Entries, entryView{
+				Name:    child.Name,
+				Path:    path.Join(filePath, child.Name),
+				Dir:     child.IsDir(),
+				Missing: !exists && !child.IsSubmodule(),
+			})
+		}
+		sort.SliceStable(page.Entries, func(i, j int) bool { return page.Entries[i].Dir && !page.Entries[j].Dir })
+	}
+
+	ui.render(w, http.StatusOK, "tree", page)
+	return nil
+}
+
+type historyPage struct {
+	*Target
+	Commit   *gitrepo.Commit
+	Path     string
+	Versions []versionView
+	Blame    []blameView
+	Note     string
+}
+
+type versionView struct {
+	gitrepo.FileVersion
+	Recovered bool
+}
+
+type blameView struct {
+	Number int
+	gitrepo.BlameLine
+	Content template.HTML
+	// First marks the first of a run of lines from the same commit
+	First bool
+}
+
+func (ui *UI) serveHistory(w http.ResponseWriter, target *Target, rev string, filePath string) error {
+
+	commit, err := target.commit(rev)
+	if err != nil {
+		return err
+	}
+
+	versions, err := target.repo.FileHistory(commit.Hash, filePath)
+	if err != nil {
+		return err
+	}
+	page := historyPage{Target: target, Commit: commit, Path: filePath}
+	for _, version := range versions {
+		recovered := false
+		if version.Hash != "" {
+			if recovered, err = target.repo.Exists(version.Hash); err != nil {
+				return err
+			}
+		}
+		page.Versions = append(page.Versions, versionView{FileVersion: version, Recovered: recovered})
+	}
+
+	blame, err := target.repo.Blame(commit.Hash, filePath)
+	switch {
+	case errors.Is(err, gitrepo.ErrMissingObject):
+		page.Note = "The file could not be recovered at this commit, so it cannot be annotated."
+	case errors.Is(err, gitrepo.ErrPathNotFound):
+		page.Note = "The file does not exist at this commit."
+	case err != nil:
+		return err
+	}
+	for i, line := range blame {
+		page.Blame = append(page.Blame, blameView{
+			Number:    i + 1,
+			BlameLine: line,
+			Content:   report.Highlight(filePath, line.Text),
+			First:     i == 0 || blame[i-1].Commit != line.Commit,
+		})
+	}
+
+	ui.render(w, http.StatusOK, "history", page)
+	return nil
+}
+
+func (ui *UI) serveRaw(w http.ResponseWriter, target *Target, rev string, filePath string) error {
+	commit, err := target.commit(rev)
+	if err != nil {
+		return err
+	}
+	entry, err := target.repo.Lookup(commit.Tree, filePath)
+	if err != nil {
+		return err
+	}
+	if entry.IsDir() || entry.IsSubmodule() {
+		return fmt.Errorf("%w: %s is not a file", errNotFound, filePath)
+	}
+	content, err := target.repo.Blob(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	// never let a browser render recovered content as a page on this site
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(filePath)))
+	_, _ = w.Write(content)
+	return nil
+}
+
+type configPage struct {
+	*Target
+	Lines []string
+	Marks map[int]string
+}
+
+func (ui *UI) serveConfig(w http.ResponseWriter, target *Target) error {
+	content, err := ioutil.ReadFile(filepath.Join(target.repo.GitDir(), "config"))
+	if err != nil {
+		return fmt.Errorf("%w: the git config was not recovered", errNotFound)
+	}
+	page := configPage{Target: target, Lines: gitrepo.SplitLines(string(content)), Marks: make(map[int]string)}
+	for _, finding := range target.findings {
+		if finding.Path == ".git/config" {
+			page.Marks[finding.Line] = finding.Description
+		}
+	}
+	ui.render(w, http.StatusOK, "config", page)
+	return nil
+}
@@ -0,0 +1,7 @@
+package gitjacker
+
+import "context"
+
+// Analyser inspects a retrieved repository once retrieval is complete, adding anything it finds to the summary.
+// The repository is usually incomplete, so analysers should make the most of whatever was recovered.
+type Analyser func(ctx context.Context, outputDir string, summary *Summary) error
@@ -0,0 +1,155 @@
+package gitjacker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrCorruptObject = fmt.Errorf("object content does not match its hash")
+
+// ObjectCache is a content-addressed store of loose git objects shared between runs
+type ObjectCache struct {
+	dir string
+}
+
+func NewObjectCache(dir string) (*ObjectCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object cache: %w", err)
+	}
+	return &ObjectCache{
+		dir: dir,
+	}, nil
+}
+
+func (c *ObjectCache) path(hash string) string {
+	return filepath.Join(c.dir, hash[:2], hash[2:])
+}
+
+// Get returns the compressed loose object for hash, discarding any cached copy which fails verification
+func (c *ObjectCache) Get(hash string) ([]byte, bool) {
+
+	if !isHash(hash) {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(c.path(hash))
+	if err != nil {
+		return nil, false
+	}
+
+	if err := verifyObject(hash, data); err != nil {
+		_ = os.Remove(c.path(hash))
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (c *ObjectCache) Put(hash string, data []byte) error {
+
+	if !isHash(hash) {
+		return fmt.Errorf("invalid object hash %q", hash)
+	}
+
+	if err := verifyObject(hash, data); err != nil {
+		return err
+	}
+
+	path := c.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// write via a temporary file so concurrent runs never see a partial object
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// verifyObject checks that a compressed loose object hashes to the expected value
+func verifyObject(hash string, data []byte) error {
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrCorruptObject, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	hasher := sha1.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return fmt.Errorf("%w: %s", ErrCorruptObject, err)
+	}
+
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return fmt.Errorf("%w: %s", ErrCorruptObject, hash)
+	}
+
+	return nil
+}
+
+func (r *Retriever) loosePath(hash string) string {
+	return filepath.Join(r.outputDir, ".git", "objects", hash[:2], hash[2:40])
+}
+
+// restoreCachedObject copies an object from the cache into the output repository
+func (r *Retriever) restoreCachedObject(hash string) bool {
+
+	if r.cache == nil {
+		return false
+	}
+
+	data, ok := r.cache.Get(hash)
+	if !ok {
+		return false
+	}
+
+	path := r.loosePath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false
+	}
+	if err := ioutil.WriteFile(path, data, 0640); err != nil {
+		return false
+	}
+
+	return true
+}
+
+func (r *Retriever) cacheObject(hash string) {
+
+	if r.cache == nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(r.loosePath(hash))
+	if err != nil {
+		return
+	}
+
+	if err := r.cache.Put(hash, data); err != nil {
+		logrus.Debugf("Failed to cache object %s: %s", hash, err)
+	}
+}
@@ -0,0 +1,101 @@
+package gitjacker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestObjectCacheReuse(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	if err := source.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir, err := ioutil.TempDir(os.TempDir(), "gjtest_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+
+	cache, err := NewObjectCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := loadMemoryFiles(t, filepath.Join(source.dir, ".git"))
+
+	var summaries []*Summary
+	for i := 0; i < 2; i++ {
+		outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = os.RemoveAll(outputDir) }()
+
+		summary, err := NewWithFetcher(NewMemoryFetcher(files), outputDir, WithCache(cache)).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, summary.Status, StatusSuccess)
+		summaries = append(summaries, summary)
+	}
+
+	assert.Equal(t, len(summaries[0].CachedObjects), 0)
+	assert.Equal(t, len(summaries[1].CachedObjects), len(summaries[1].FoundObjects))
+	assert.Equal(t, len(summaries[1].FoundObjects), len(summaries[0].FoundObjects))
+}
+
+func TestObjectCacheVerifiesContent(t *testing.T) {
+	cacheDir, err := ioutil.TempDir(os.TempDir(), "gjtest_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(cacheDir) }()
+
+	cache, err := NewObjectCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := &bytes.Buffer{}
+	writer := zlib.NewWriter(compressed)
+	_, _ = writer.Write([]byte("blob 6\x00hello\n"))
+	_ = writer.Close()
+
+	// the hash of "hello\n" as a git blob
+	hash := "ce013625030ba8dba906f756967f9e9ca394464a"
+
+	err = cache.Put("0000000000000000000000000000000000000001", compressed.Bytes())
+	assert.Equal(t, errors.Is(err, ErrCorruptObject), true)
+
+	if err := cache.Put(hash, compressed.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, ok := cache.Get(hash)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, data, compressed.Bytes())
+
+	// tampered entries are discarded on read
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, hash[:2], hash[2:]), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, ok = cache.Get(hash)
+	assert.Equal(t, ok, false)
+}
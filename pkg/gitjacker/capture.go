@@ -0,0 +1,268 @@
+package gitjacker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxWARCRecordSize is the largest WARC record which is read, as the length comes from the capture itself
+const maxWARCRecordSize = 1 << 30
+
+type capturedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// CaptureFetcher answers requests from traffic previously recorded in a WARC or HAR capture
+type CaptureFetcher struct {
+	baseURL   *url.URL
+	source    string
+	responses map[string]*capturedResponse
+}
+
+func newCaptureFetcher(target *url.URL, source string) *CaptureFetcher {
+	return &CaptureFetcher{
+		baseURL:   gitBaseURL(target),
+		source:    source,
+		responses: make(map[string]*capturedResponse),
+	}
+}
+
+// OpenWARC loads all HTTP responses from a WARC file, which may optionally be gzipped
+func OpenWARC(target *url.URL, path string) (*CaptureFetcher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	fetcher := newCaptureFetcher(target, path)
+	if err := fetcher.loadWARC(f); err != nil {
+		return nil, fmt.Errorf("failed to read WARC file %s: %w", path, err)
+	}
+	return fetcher, nil
+}
+
+// OpenHAR loads all HTTP responses from a HAR file exported by a browser or proxy
+func OpenHAR(target *url.URL, path string) (*CaptureFetcher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	fetcher := newCaptureFetcher(target, path)
+	if err := fetcher.loadHAR(f); err != nil {
+		return nil, fmt.Errorf("failed to read HAR file %s: %w", path, err)
+	}
+	return fetcher, nil
+}
+
+func (f *CaptureFetcher) Fetch(_ context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	relative, err := url.Parse(path)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	absolute := f.baseURL.ResolveReference(relative)
+
+	resp, ok := f.responses[captureKey(absolute)]
+	if !ok || resp.status == http.StatusNotFound {
+		return nil, Meta{}, fmt.Errorf("%w: %s is not in capture", ErrNotFound, absolute.String())
+	}
+	if resp.status != http.StatusOK {
+		return nil, Meta{}, fmt.Errorf("unexpected status code for url %s : %d", absolute.String(), resp.status)
+	}
+
+	meta := Meta{
+		Source:      f.source,
+		Size:        int64(len(resp.body)),
+		ContentType: resp.header.Get("Content-Type"),
+		ETag:        resp.header.Get("ETag"),
+	}
+	if modified, err := http.ParseTime(resp.header.Get("Last-Modified")); err == nil {
+		meta.LastModified = modified
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(resp.body)), meta, nil
+}
+
+func (f *CaptureFetcher) add(rawURL string, resp *capturedResponse) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	key := captureKey(u)
+	// prefer successful responses when the same URL was captured more than once
+	if existing, ok := f.responses[key]; ok && existing.status == http.StatusOK && resp.status != http.StatusOK {
+		return
+	}
+	f.responses[key] = resp
+}
+
+// captureKey normalises a URL so captures match regardless of scheme or default port
+func captureKey(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	key := host + u.EscapedPath()
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+func (f *CaptureFetcher) loadWARC(r io.Reader) error {
+
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		buffered = bufio.NewReader(gz)
+	}
+
+	reader := textproto.NewReader(buffered)
+	for {
+		version, err := reader.ReadLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if version == "" {
+			continue
+		}
+		if !strings.HasPrefix(version, "WARC/") {
+			return fmt.Errorf("invalid WARC record header %q", version)
+		}
+
+		header, err := reader.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid WARC record length: %w", err)
+		}
+		if length < 0 || length > maxWARCRecordSize {
+			return fmt.Errorf("invalid WARC record length %d", length)
+		}
+
+		// the buffer grows as the block is read, so a record claiming more than the capture holds allocates nothing
+		var block bytes.Buffer
+		if _, err := io.CopyN(&block, buffered, length); err == io.EOF {
+			return fmt.Errorf("WARC record is truncated: %w", io.ErrUnexpectedEOF)
+		} else if err != nil {
+			return err
+		}
+
+		if header.Get("WARC-Type") != "response" || !strings.HasPrefix(header.Get("Content-Type"), "application/http") {
+			continue
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(&block), nil)
+		if err != nil {
+			continue
+		}
+		body, err := readCapturedBody(resp)
+		if err != nil {
+			continue
+		}
+
+		f.add(header.Get("WARC-Target-URI"), &capturedResponse{
+			status: resp.StatusCode,
+			header: resp.Header,
+			body:   body,
+		})
+	}
+}
+
+func readCapturedBody(resp *http.Response) ([]byte, error) {
+	defer func() { _ = resp.Body.Close() }()
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = gz.Close() }()
+		return ioutil.ReadAll(gz)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method string `json:"method"`
+				URL    string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				Content struct {
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func (f *CaptureFetcher) loadHAR(r io.Reader) error {
+
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return err
+	}
+
+	for _, entry := range har.Log.Entries {
+		if entry.Request.Method != "" && entry.Request.Method != http.MethodGet {
+			continue
+		}
+
+		body := []byte(entry.Response.Content.Text)
+		if entry.Response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+			if err != nil {
+				continue
+			}
+			body = decoded
+		}
+
+		header := make(http.Header)
+		for _, h := range entry.Response.Headers {
+			header.Add(h.Name, h.Value)
+		}
+
+		f.add(entry.Request.URL, &capturedResponse{
+			status: entry.Response.Status,
+			header: header,
+			body:   body,
+		})
+	}
+
+	return nil
+}
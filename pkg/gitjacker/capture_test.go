@@ -0,0 +1,149 @@
+package gitjacker
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func writeHAR(t *testing.T, path string, files map[string][]byte) {
+	type entry struct {
+		Request  map[string]interface{} `json:"request"`
+		Response map[string]interface{} `json:"response"`
+	}
+	var entries []entry
+	for name, content := range files {
+		entries = append(entries, entry{
+			Request: map[string]interface{}{
+				"method": "GET",
+				"url":    "https://victim.example/.git/" + name,
+			},
+			Response: map[string]interface{}{
+				"status": 200,
+				"content": map[string]interface{}{
+					"text":     base64.StdEncoding.EncodeToString(content),
+					"encoding": "base64",
+				},
+			},
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"log": map[string]interface{}{
+			"entries": entries,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeWARC(t *testing.T, path string, files map[string][]byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	for name, content := range files {
+		// each record is a separate gzip member, as produced by most crawlers
+		gz := gzip.NewWriter(f)
+		block := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(content), content)
+		if _, err := fmt.Fprintf(gz, "WARC/1.0\r\nWARC-Type: response\r\nWARC-Target-URI: http://victim.example/.git/%s\r\nContent-Type: application/http; msgtype=response\r\nContent-Length: %d\r\n\r\n%s\r\n\r\n", name, len(block), block); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCaptureRetrieval(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	expectedContent := "<?php\necho 'hello';\n"
+	if err := source.writeFile("hello.php", expectedContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := loadMemoryFiles(t, filepath.Join(source.dir, ".git"))
+
+	captureDir, err := ioutil.TempDir(os.TempDir(), "gjtest_capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(captureDir) }()
+
+	harPath := filepath.Join(captureDir, "capture.har")
+	writeHAR(t, harPath, files)
+	warcPath := filepath.Join(captureDir, "capture.warc.gz")
+	writeWARC(t, warcPath, files)
+
+	target, err := url.Parse("https://victim.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openers := map[string]func() (*CaptureFetcher, error){
+		"har":  func() (*CaptureFetcher, error) { return OpenHAR(target, harPath) },
+		"warc": func() (*CaptureFetcher, error) { return OpenWARC(target, warcPath) },
+	}
+
+	for name, open := range openers {
+		t.Run(name, func(t *testing.T) {
+			fetcher, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = os.RemoveAll(outputDir) }()
+
+			summary, err := NewWithFetcher(fetcher, outputDir).Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, summary.Status, StatusSuccess)
+
+			actual, err := ioutil.ReadFile(filepath.Join(outputDir, "hello.php"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(actual), expectedContent)
+		})
+	}
+}
+
+func TestInvalidWARCRecordLength(t *testing.T) {
+	for _, length := range []string{"-1", "99999999999999", "1024"} {
+		t.Run(length, func(t *testing.T) {
+			record := "WARC/1.0\r\nWARC-Type: response\r\nContent-Length: " + length + "\r\n\r\nHTTP/1.1 200 OK\r\n\r\n"
+			f := newCaptureFetcher(&url.URL{Scheme: "http", Host: "victim.example"}, "capture.warc")
+			err := f.loadWARC(strings.NewReader(record))
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package gitjacker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type Config struct {
+	RepositoryName string
+	Remotes        []Remote
+	Branches       []Branch
+	User           User
+	GithubToken    GithubToken
+	// Secrets lists every credential in the config, including the GitHub token and any passwords in remote URLs
+	Secrets []ConfigSecret
+}
+
+type User struct {
+	Name     string
+	Email    string
+	Username string
+}
+
+type GithubToken struct {
+	Username string
+	Token    string
+}
+
+type Remote struct {
+	Name string
+	URL  string
+}
+
+type Branch struct {
+	Name   string
+	Remote string
+}
+
+// kinds of ConfigSecret
+const (
+	ConfigSecretGithubToken    = "github-token"
+	ConfigSecretURLCredentials = "url-credentials"
+	ConfigSecretValue          = "secret"
+)
+
+// ConfigSecret is a credential in the git config, such as a token or a password in a remote URL
+type ConfigSecret struct {
+	// Kind is one of "github-token", "url-credentials" or "secret"
+	Kind string
+	// Section and Subsection locate the value, e.g. "remote" and "origin" for [remote "origin"]
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	// Username is the account the credential belongs to, where the config names one
+	Username    string
+	Line        int
+	Description string
+}
+
+// ParseConfig reads the remotes, branches, identities and credentials from a git config. Everything which reads
+// a recovered config uses it, so that retrieval summaries, reports and the repository browsers agree.
+func ParseConfig(content []byte) Config {
+
+	var config Config
+	var section, subsection string
+	githubToken := -1
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section, subsection = parseConfigSection(line)
+			name := subsection
+			if name == "" {
+				name = "?"
+			}
+			switch section {
+			case "remote":
+				config.Remotes = append(config.Remotes, Remote{Name: name})
+			case "branch":
+				config.Branches = append(config.Branches, Branch{Name: name})
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+
+		switch {
+		case section == "remote" && key == "url":
+			config.Remotes[len(config.Remotes)-1].URL = value
+			if strings.Contains(value, "/") {
+				name := value[strings.Index(value, "/")+1:]
+				config.RepositoryName = strings.TrimSuffix(name, ".git")
+			}
+		case section == "branch" && key == "remote":
+			config.Branches[len(config.Branches)-1].Remote = value
+		case section == "user" && key == "name":
+			config.User.Name = value
+		case section == "user" && key == "username":
+			config.User.Username = value
+		case section == "user" && key == "email":
+			config.User.Email = value
+		case section == "github" && key == "user":
+			config.GithubToken.Username = value
+			if githubToken >= 0 {
+				config.Secrets[githubToken].Username = value
+			}
+		case section == "github" && key == "token":
+			config.GithubToken.Token = value
+		}
+
+		secret := ConfigSecret{
+			Section:    section,
+			Subsection: subsection,
+			Key:        key,
+			Value:      value,
+			Line:       i + 1,
+		}
+		switch {
+		case section == "github" && key == "token":
+			secret.Kind = ConfigSecretGithubToken
+			secret.Username = config.GithubToken.Username
+			secret.Description = "GitHub token"
+		case key == "url":
+			u, err := url.Parse(value)
+			if err != nil || u.User == nil {
+				continue
+			}
+			if _, hasPassword := u.User.Password(); !hasPassword {
+				continue
+			}
+			secret.Kind = ConfigSecretURLCredentials
+			secret.Username = u.User.Username()
+			secret.Description = fmt.Sprintf("Credentials for %s in the URL of %s", u.User.Username(), configSectionName(section, subsection))
+		case strings.Contains(key, "password") || strings.Contains(key, "token") || strings.Contains(key, "secret"):
+			secret.Kind = ConfigSecretValue
+			secret.Description = fmt.Sprintf("Secret %s in %s", key, configSectionName(section, subsection))
+		default:
+			continue
+		}
+		if secret.Kind == ConfigSecretGithubToken {
+			// github.user may follow the token
+			githubToken = len(config.Secrets)
+		}
+		config.Secrets = append(config.Secrets, secret)
+	}
+
+	return config
+}
+
+// parseConfigSection reads a section header such as [remote "origin"], or the older form [remote.origin]
+func parseConfigSection(line string) (string, string) {
+	header := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+	if i := strings.IndexAny(header, " \t"); i >= 0 {
+		return strings.ToLower(header[:i]), strings.Trim(strings.TrimSpace(header[i:]), `"`)
+	}
+	if i := strings.Index(header, "."); i >= 0 {
+		return strings.ToLower(header[:i]), header[i+1:]
+	}
+	return strings.ToLower(header), ""
+}
+
+func configSectionName(section string, subsection string) string {
+	if subsection == "" {
+		return "[" + section + "]"
+	}
+	return fmt.Sprintf("[%s %q]", section, subsection)
+}
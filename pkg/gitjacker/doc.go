@@ -0,0 +1,18 @@
+// Package gitjacker recovers git repositories from websites which mistakenly serve the contents of their .git directory.
+//
+// A Retriever is created for a target and an output directory, configured with options, and run once:
+//
+//	retriever := gitjacker.New(target, outputDir,
+//		gitjacker.WithTimeout(5*time.Second),
+//		gitjacker.WithEventHandler(func(event gitjacker.Event) {
+//			if event.Kind == gitjacker.EventObjectFound {
+//				log.Println("found", event.Hash)
+//			}
+//		}),
+//	)
+//	summary, err := retriever.Run(ctx)
+//
+// Run stops as soon as ctx is cancelled or its deadline passes. Files can also be retrieved from mirrors, captures
+// or local directories by passing a Fetcher to NewWithFetcher. Analysers given with WithAnalyser run over the
+// retrieved repository at the end of the run, adding what they find, such as secrets, to the summary.
+package gitjacker
@@ -0,0 +1,106 @@
+package gitjacker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventPhase         EventKind = "phase"
+	EventObjectFound   EventKind = "object-found"
+	EventObjectMissing EventKind = "object-missing"
+	EventObjectCorrupt EventKind = "object-corrupt"
+	EventRefFound      EventKind = "ref-found"
+	EventObjectQueued  EventKind = "object-queued"
+	EventRequest       EventKind = "request"
+	EventConfig        EventKind = "config"
+)
+
+const (
+	PhaseSmart     = "smart"
+	PhaseRefs      = "refs"
+	PhaseTraversal = "traversal"
+	PhasePacks     = "packs"
+	PhaseCheckout  = "checkout"
+	PhaseAnalysis  = "analysis"
+	PhaseComplete  = "complete"
+)
+
+// Event reports retrieval progress as it happens
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	Time  time.Time `json:"time"`
+	Phase string    `json:"phase,omitempty"`
+	Hash  string    `json:"hash,omitempty"`
+	Ref   string    `json:"ref,omitempty"`
+	// Path, Status and Bytes describe a request, where Bytes is the size of the body received
+	// and Status is the HTTP status code, if any
+	Path   string `json:"path,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	// Field and Value describe a config field, e.g. "remote.origin.url"
+	Field string `json:"field,omitempty"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type EventHandler func(Event)
+
+func (r *Retriever) emit(ctx context.Context, event Event) {
+	if len(r.handlers) == 0 && len(r.channels) == 0 {
+		return
+	}
+	event.Time = time.Now().UTC()
+	for _, handler := range r.handlers {
+		handler(event)
+	}
+	for _, events := range r.channels {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (r *Retriever) enterPhase(ctx context.Context, phase string) {
+	if r.phase == phase {
+		return
+	}
+	r.phase = phase
+	r.emit(ctx, Event{Kind: EventPhase, Phase: phase})
+}
+
+func (r *Retriever) objectFound(ctx context.Context, hash string) {
+	r.summary.FoundObjects = append(r.summary.FoundObjects, hash)
+	r.emit(ctx, Event{Kind: EventObjectFound, Hash: hash})
+}
+
+// queueObjects reports objects which have been referenced but not yet retrieved
+func (r *Retriever) queueObjects(ctx context.Context, hashes ...string) {
+	for _, hash := range hashes {
+		if isHash(hash) && !r.downloaded[fmt.Sprintf("objects/%s/%s", hash[:2], hash[2:])] {
+			r.emit(ctx, Event{Kind: EventObjectQueued, Hash: hash})
+		}
+	}
+}
+
+// requested reports a completed request, along with its status or failure
+func (r *Retriever) requested(ctx context.Context, path string, meta Meta, size int, err error) {
+	event := Event{Kind: EventRequest, Path: path, Status: meta.StatusCode, Bytes: int64(size)}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		event.Status = statusErr.StatusCode
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.emit(ctx, event)
+}
+
+func (r *Retriever) configField(ctx context.Context, field string, value string) {
+	r.emit(ctx, Event{Kind: EventConfig, Field: field, Value: value})
+}
@@ -0,0 +1,417 @@
+package gitjacker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = fmt.Errorf("file not found")
+
+// StatusError is returned by HTTPFetcher for any response other than 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Sprintf("%s: %s", ErrNotFound, e.URL)
+	case http.StatusNotModified:
+		return fmt.Sprintf("%s: %s", ErrNotModified, e.URL)
+	}
+	return fmt.Sprintf("unexpected status code for url %s : %d", e.URL, e.StatusCode)
+}
+
+// Is allows 404 and 304 responses to be matched with ErrNotFound and ErrNotModified
+func (e *StatusError) Is(target error) bool {
+	return (target == ErrNotFound && e.StatusCode == http.StatusNotFound) ||
+		(target == ErrNotModified && e.StatusCode == http.StatusNotModified)
+}
+
+// Meta describes a file returned by a Fetcher
+type Meta struct {
+	Source       string
+	StatusCode   int
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Fetcher retrieves files relative to the root of a .git directory, e.g. "HEAD" or "objects/pack/"
+type Fetcher interface {
+	Fetch(ctx context.Context, path string) (io.ReadCloser, Meta, error)
+}
+
+type HTTPFetcher struct {
+	baseURL *url.URL
+	client  *http.Client
+}
+
+func NewHTTPFetcher(baseURL *url.URL, client *http.Client) *HTTPFetcher {
+	return &HTTPFetcher{
+		baseURL: baseURL,
+		client:  client,
+	}
+}
+
+func (f *HTTPFetcher) resolve(path string) (*url.URL, error) {
+	relative, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.baseURL.ResolveReference(relative), nil
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, path string) (io.ReadCloser, Meta, error) {
+	return f.FetchIfModified(ctx, path, Meta{})
+}
+
+// FetchIfModified sends a conditional request using the validators from a previous fetch
+func (f *HTTPFetcher) FetchIfModified(ctx context.Context, path string, previous Meta) (io.ReadCloser, Meta, error) {
+
+	absolute, err := f.resolve(path)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, absolute.String(), nil)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	if previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+	if !previous.LastModified.IsZero() {
+		req.Header.Set("If-Modified-Since", previous.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, Meta{}, fmt.Errorf("failed to retrieve %s: %w", absolute.String(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, Meta{}, &StatusError{URL: absolute.String(), StatusCode: resp.StatusCode}
+	}
+
+	meta := Meta{
+		Source:      f.baseURL.String(),
+		StatusCode:  resp.StatusCode,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		meta.LastModified = modified
+	}
+
+	return resp.Body, meta, nil
+}
+
+type DirFetcher struct {
+	dir string
+}
+
+func NewDirFetcher(dir string) *DirFetcher {
+	return &DirFetcher{
+		dir: dir,
+	}
+}
+
+func (f *DirFetcher) Fetch(_ context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	filePath := filepath.Join(f.dir, filepath.FromSlash(filepath.Clean("/"+path)))
+
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, Meta{}, fmt.Errorf("%w: %s", ErrNotFound, filePath)
+	} else if err != nil {
+		return nil, Meta{}, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	meta := Meta{
+		Source:       f.dir,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+
+	if !info.IsDir() {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, Meta{}, err
+		}
+		return file, meta, nil
+	}
+
+	if !strings.HasSuffix(path, "/") {
+		return nil, Meta{}, fmt.Errorf("failed to read %s: is a directory", filePath)
+	}
+
+	// mirrors created by tools such as wget keep the server's own directory listing
+	if listing, err := ioutil.ReadFile(filepath.Join(filePath, "index.html")); err == nil {
+		meta.Size = int64(len(listing))
+		return ioutil.NopCloser(bytes.NewReader(listing)), meta, nil
+	}
+
+	entries, err := ioutil.ReadDir(filePath)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	listing := directoryListing(entries)
+	meta.Size = int64(len(listing))
+	return ioutil.NopCloser(bytes.NewReader(listing)), meta, nil
+}
+
+// MemoryFetcher serves files from memory, keyed by their path relative to the .git directory
+type MemoryFetcher struct {
+	files map[string][]byte
+}
+
+func NewMemoryFetcher(files map[string][]byte) *MemoryFetcher {
+	return &MemoryFetcher{
+		files: files,
+	}
+}
+
+func (f *MemoryFetcher) Fetch(_ context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	meta := Meta{
+		Source: "memory",
+	}
+
+	if content, ok := f.files[path]; ok {
+		meta.Size = int64(len(content))
+		return ioutil.NopCloser(bytes.NewReader(content)), meta, nil
+	}
+
+	if !strings.HasSuffix(path, "/") {
+		return nil, Meta{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+
+	// synthesise a listing of any files below the requested directory
+	var entries []os.FileInfo
+	seen := make(map[string]bool)
+	for name := range f.files {
+		if !strings.HasPrefix(name, path) {
+			continue
+		}
+		child := strings.SplitN(strings.TrimPrefix(name, path), "/", 2)[0]
+		if child == "" || seen[child] {
+			continue
+		}
+		seen[child] = true
+		entries = append(entries, memoryFileInfo(child))
+	}
+	if len(entries) == 0 {
+		return nil, Meta{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+
+	listing := directoryListing(entries)
+	meta.Size = int64(len(listing))
+	return ioutil.NopCloser(bytes.NewReader(listing)), meta, nil
+}
+
+type memoryFileInfo string
+
+func (m memoryFileInfo) Name() string       { return string(m) }
+func (m memoryFileInfo) Size() int64        { return 0 }
+func (m memoryFileInfo) Mode() os.FileMode  { return 0 }
+func (m memoryFileInfo) ModTime() time.Time { return time.Time{} }
+func (m memoryFileInfo) IsDir() bool        { return false }
+func (m memoryFileInfo) Sys() interface{}   { return nil }
+
+func directoryListing(entries []os.FileInfo) []byte {
+	listing := &bytes.Buffer{}
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(listing, "<a href=\"%s\">%s</a>\n", entry.Name(), entry.Name())
+	}
+	return listing.Bytes()
+}
+
+// MultiFetcher queries several sources for the same repository, such as mirrors or load-balanced backends
+type MultiFetcher struct {
+	fetchers []Fetcher
+}
+
+func NewMultiFetcher(fetchers ...Fetcher) *MultiFetcher {
+	return &MultiFetcher{
+		fetchers: fetchers,
+	}
+}
+
+func (m *MultiFetcher) Fetch(ctx context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	if isListing(path) {
+		return m.fetchListing(ctx, path)
+	}
+	if isMerged(path) {
+		return m.fetchMerged(ctx, path)
+	}
+
+	err := fmt.Errorf("%w: %s", ErrNotFound, path)
+	for _, fetcher := range m.fetchers {
+		body, meta, fetchErr := fetcher.Fetch(ctx, path)
+		if fetchErr == nil {
+			return body, meta, nil
+		}
+		// prefer reporting a real failure over a missing file
+		if !errors.Is(fetchErr, ErrNotFound) || errors.Is(err, ErrNotFound) {
+			err = fetchErr
+		}
+	}
+	return nil, Meta{}, err
+}
+
+func (m *MultiFetcher) FetchIfModified(ctx context.Context, path string, previous Meta) (io.ReadCloser, Meta, error) {
+
+	if isListing(path) || isMerged(path) {
+		return m.Fetch(ctx, path)
+	}
+
+	// validators are only meaningful to the source which issued them
+	for _, fetcher := range m.fetchers {
+		conditional, ok := fetcher.(ConditionalFetcher)
+		if !ok || fetcherSource(fetcher) != previous.Source {
+			continue
+		}
+		body, meta, err := conditional.FetchIfModified(ctx, path, previous)
+		if err == nil || errors.Is(err, ErrNotModified) {
+			return body, meta, err
+		}
+		break
+	}
+
+	return m.Fetch(ctx, path)
+}
+
+func fetcherSource(fetcher Fetcher) string {
+	if f, ok := fetcher.(*HTTPFetcher); ok {
+		return f.baseURL.String()
+	}
+	return ""
+}
+
+// fetchListing merges listings from every source, as each backend may hold different packs
+func (m *MultiFetcher) fetchListing(ctx context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	merged := &bytes.Buffer{}
+	var sources []string
+	err := fmt.Errorf("%w: %s", ErrNotFound, path)
+	for _, fetcher := range m.fetchers {
+		body, meta, fetchErr := fetcher.Fetch(ctx, path)
+		if fetchErr != nil {
+			err = fetchErr
+			continue
+		}
+		_, copyErr := io.Copy(merged, body)
+		_ = body.Close()
+		if copyErr != nil {
+			return nil, Meta{}, copyErr
+		}
+		merged.WriteString("\n")
+		sources = append(sources, meta.Source)
+	}
+	if len(sources) == 0 {
+		return nil, Meta{}, err
+	}
+
+	return ioutil.NopCloser(merged), Meta{
+		Source: strings.Join(sources, ","),
+		Size:   int64(merged.Len()),
+	}, nil
+}
+
+// fetchMerged asks every source for a ref or reflog, as backends deployed at different times may disagree on
+// where HEAD and each branch point. Every distinct line is returned, with those of the first source first, so
+// the retriever can keep one version and queue the rest.
+func (m *MultiFetcher) fetchMerged(ctx context.Context, path string) (io.ReadCloser, Meta, error) {
+
+	var records []string
+	seen := make(map[string]bool)
+	var sources []string
+	err := fmt.Errorf("%w: %s", ErrNotFound, path)
+	for _, fetcher := range m.fetchers {
+		body, meta, fetchErr := fetcher.Fetch(ctx, path)
+		if fetchErr != nil {
+			if !errors.Is(fetchErr, ErrNotFound) || errors.Is(err, ErrNotFound) {
+				err = fetchErr
+			}
+			continue
+		}
+		content, readErr := ioutil.ReadAll(body)
+		_ = body.Close()
+		if readErr != nil {
+			return nil, Meta{}, readErr
+		}
+		for _, record := range refRecords(path, string(content)) {
+			if !seen[record] {
+				seen[record] = true
+				records = append(records, record)
+			}
+		}
+		sources = append(sources, meta.Source)
+	}
+	if len(sources) == 0 {
+		return nil, Meta{}, err
+	}
+
+	merged := strings.Join(records, "\n") + "\n"
+	return ioutil.NopCloser(strings.NewReader(merged)), Meta{
+		Source: strings.Join(sources, ","),
+		Size:   int64(len(merged)),
+	}, nil
+}
+
+// refRecords splits a ref file into lines, keeping the peeled hash which follows a tag in packed-refs with the tag
+func refRecords(path string, content string) []string {
+	var records []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if path == "packed-refs" && strings.HasPrefix(line, "^") && len(records) > 0 {
+			records[len(records)-1] += "\n" + line
+			continue
+		}
+		records = append(records, line)
+	}
+	return records
+}
+
+func isMerged(path string) bool {
+	return path == "HEAD" || path == "packed-refs" || strings.HasPrefix(path, "refs/") || strings.HasPrefix(path, "logs/")
+}
+
+func isListing(path string) bool {
+	return strings.HasSuffix(path, "/") || path == "objects/info/packs"
+}
+
+// httpSources lists every HTTP source behind a fetcher, for use with the smart protocol
+func httpSources(fetcher Fetcher) []*HTTPFetcher {
+	switch f := fetcher.(type) {
+	case *HTTPFetcher:
+		return []*HTTPFetcher{f}
+	case *MultiFetcher:
+		var sources []*HTTPFetcher
+		for _, sub := range f.fetchers {
+			sources = append(sources, httpSources(sub)...)
+		}
+		return sources
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package gitjacker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func loadMemoryFiles(t *testing.T, gitDir string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.Walk(gitDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relative)] = content
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestMemoryFetcherRetrieval(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	expectedContent := "<?php\necho 'hello';\n"
+	if err := source.writeFile("hello.php", expectedContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	fetcher := NewMemoryFetcher(loadMemoryFiles(t, filepath.Join(source.dir, ".git")))
+
+	summary, err := NewWithFetcher(fetcher, outputDir).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, summary.Status, StatusSuccess)
+	assert.Equal(t, summary.Config.User.Name, "test")
+
+	actual, err := ioutil.ReadFile(filepath.Join(outputDir, "hello.php"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(actual), expectedContent)
+}
+
+func TestFetchersReportNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for _, fetcher := range []Fetcher{
+		NewDirFetcher(dir),
+		NewMemoryFetcher(map[string][]byte{"HEAD": []byte("ref: refs/heads/master\n")}),
+	} {
+		_, _, err := fetcher.Fetch(context.Background(), "objects/ab/cdef")
+		assert.Equal(t, errors.Is(err, ErrNotFound), true)
+	}
+}
+
+func TestMemoryFetcherListsDirectories(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string][]byte{
+		"objects/pack/pack-5b89658fae4313c1e25d629bfa95f809c77ff949.pack": []byte("PACK"),
+		"objects/pack/pack-5b89658fae4313c1e25d629bfa95f809c77ff949.idx":  []byte("IDX"),
+	})
+
+	body, _, err := fetcher.Fetch(context.Background(), "objects/pack/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = body.Close() }()
+
+	listing, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := packLinkRegex.FindAllStringSubmatch(string(listing), -1)
+	assert.Equal(t, len(matches), 1)
+	assert.Equal(t, matches[0][1], "pack-5b89658fae4313c1e25d629bfa95f809c77ff949.pack")
+}
+
+func TestMultiFetcherMergesSources(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	if err := source.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.writeFile("secret.php", "<?php\necho 'secret';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	hashCmd := exec.Command("git", "rev-parse", "HEAD:secret.php")
+	hashCmd.Dir = source.dir
+	output, err := hashCmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.TrimSpace(string(output))
+
+	// the primary backend is missing an object which only the mirror still holds
+	mirrorFiles := loadMemoryFiles(t, filepath.Join(source.dir, ".git"))
+	mirrorDir, err := ioutil.TempDir(os.TempDir(), "gjtest_mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(mirrorDir) }()
+	for name, content := range mirrorFiles {
+		path := filepath.Join(mirrorDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(source.dir, ".git", "objects", hash[:2], hash[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	primary := filepath.Join(source.dir, ".git")
+	fetcher := NewMultiFetcher(NewDirFetcher(primary), NewDirFetcher(mirrorDir))
+
+	summary, err := NewWithFetcher(fetcher, outputDir).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, summary.Status, StatusSuccess)
+	assert.Equal(t, summary.ObjectSources[hash], mirrorDir)
+
+	headCmd := exec.Command("git", "rev-parse", "HEAD")
+	headCmd.Dir = source.dir
+	output, err = headCmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, summary.ObjectSources[strings.TrimSpace(string(output))], primary)
+}
+
+func TestMultiFetcherMergesRefs(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = source.dir
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	if err := source.writeFile("index.php", "<?php\necho 'v1';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+	branch := run("symbolic-ref", "HEAD")
+	first := run("rev-parse", "HEAD")
+	run("checkout", "-q", "-b", "release")
+	if err := source.writeFile("index.php", "<?php\necho 'release';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("release commit"); err != nil {
+		t.Fatal(err)
+	}
+	release := run("rev-parse", "HEAD")
+	run("checkout", "-q", "-")
+	if err := source.writeFile("index.php", "<?php\necho 'v2';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("second commit"); err != nil {
+		t.Fatal(err)
+	}
+	second := run("rev-parse", "HEAD")
+
+	// the second backend was deployed from another branch, and still has the old version of the first
+	mirrorDir, err := ioutil.TempDir(os.TempDir(), "gjtest_mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(mirrorDir) }()
+	for name, content := range loadMemoryFiles(t, filepath.Join(source.dir, ".git")) {
+		switch name {
+		case "HEAD":
+			content = []byte("ref: refs/heads/release\n")
+		case branch:
+			content = []byte(first + "\n")
+		case "logs/HEAD":
+			content = append(content, []byte(second+" "+first+" test <test@test.com> 1600000000 +0000\treset: rolled back\n")...)
+		}
+		path := filepath.Join(mirrorDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(source.dir, ".git", filepath.FromSlash("refs/heads/release"))); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	fetcher := NewMultiFetcher(NewDirFetcher(filepath.Join(source.dir, ".git")), NewDirFetcher(mirrorDir))
+
+	summary, err := NewWithFetcher(fetcher, outputDir).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, summary.Status, StatusSuccess)
+
+	// the first source's HEAD is kept, and every other tip is retrieved
+	head, err := ioutil.ReadFile(filepath.Join(outputDir, ".git", "HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(head), "ref: "+branch+"\n")
+	assert.Equal(t, summary.Refs, []Ref{
+		{Name: "refs/diverged/" + strings.TrimPrefix(branch, "refs/") + "/" + first[:12], Hash: first},
+		{Name: branch, Hash: second},
+		{Name: "refs/heads/release", Hash: release},
+	})
+	found := strings.Join(summary.FoundObjects, " ")
+	for _, hash := range []string{first, second, release} {
+		assert.Equal(t, strings.Contains(found, hash), true, hash)
+	}
+
+	reflog, err := ioutil.ReadFile(filepath.Join(outputDir, ".git", "logs", "HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(string(reflog), "reset: rolled back"), true)
+}
@@ -0,0 +1,48 @@
+package gitjacker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrNoGitDirectory = fmt.Errorf("no .git directory was found at this path")
+
+// NewLocal creates a retriever which analyses an already downloaded .git directory without any network access
+func NewLocal(sourceDir string, outputDir string, opts ...Option) (*Retriever, error) {
+
+	gitDir, err := findGitDirectory(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	absGitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	absOutputDir, err := filepath.Abs(filepath.Join(outputDir, ".git"))
+	if err != nil {
+		return nil, err
+	}
+	if absGitDir == absOutputDir {
+		return nil, fmt.Errorf("output directory must differ from the directory being analysed")
+	}
+
+	return NewWithFetcher(NewDirFetcher(gitDir), outputDir, opts...), nil
+}
+
+// findGitDirectory accepts either a repository root containing .git or the .git directory itself
+func findGitDirectory(dir string) (string, error) {
+
+	if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+		return filepath.Join(dir, ".git"), nil
+	}
+
+	for _, marker := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoGitDirectory, dir)
+}
@@ -0,0 +1,81 @@
+package gitjacker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestLocalAnalysis(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	expectedContent := "<?php\necho 'hello';\n"
+	if err := source.writeFile("hello.php", expectedContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.writeFile("secret.php", "<?php\necho 'secret';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a partial dump by removing one of the blobs
+	hashCmd := exec.Command("git", "rev-parse", "HEAD:secret.php")
+	hashCmd.Dir = source.dir
+	output, err := hashCmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := strings.TrimSpace(string(output))
+	if err := os.Remove(filepath.Join(source.dir, ".git", "objects", missing[:2], missing[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	retriever, err := NewLocal(source.dir, outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := retriever.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, summary.Status, StatusPartialSuccess)
+	assert.Equal(t, summary.MissingObjects, []string{missing})
+	assert.Equal(t, summary.Config.User.Email, "test@test.com")
+
+	actual, err := ioutil.ReadFile(filepath.Join(outputDir, "hello.php"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(actual), expectedContent)
+}
+
+func TestLocalAnalysisRequiresGitDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	_, err = NewLocal(dir, dir)
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,109 @@
+package gitjacker
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const defaultTimeout = time.Second * 10
+
+type options struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	proxy     func(*http.Request) (*url.URL, error)
+	client    *http.Client
+	cache     *ObjectCache
+	handlers  []EventHandler
+	channels  []chan<- Event
+	analysers []Analyser
+}
+
+// Option configures a Retriever
+type Option func(*options)
+
+// WithTimeout sets the timeout for each HTTP request. The default is 10 seconds. Use a context deadline to limit a whole run.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for HTTP requests. By default certificates are not verified, as exposed hosts are often misconfigured.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithProxy sends all HTTP requests through the given proxy. By default the proxy is taken from the environment.
+func WithProxy(proxy *url.URL) Option {
+	return func(o *options) {
+		o.proxy = http.ProxyURL(proxy)
+	}
+}
+
+// WithHTTPClient uses the given client for all HTTP requests, ignoring WithTimeout, WithTLSConfig and WithProxy
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithCache reuses objects from a shared object cache, and adds retrieved objects to it
+func WithCache(cache *ObjectCache) Option {
+	return func(o *options) {
+		o.cache = cache
+	}
+}
+
+// WithEventHandler calls handler synchronously for every progress event. It may be given more than once.
+func WithEventHandler(handler EventHandler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, handler)
+	}
+}
+
+// WithEvents sends every progress event to events. Sends block until received or the run is cancelled, so the
+// channel must be drained while Run is in progress. The channel is not closed by the Retriever.
+func WithEvents(events chan<- Event) Option {
+	return func(o *options) {
+		o.channels = append(o.channels, events)
+	}
+}
+
+// WithAnalyser runs analyser over the retrieved repository at the end of each run. It may be given more than once.
+func WithAnalyser(analyser Analyser) Option {
+	return func(o *options) {
+		o.analysers = append(o.analysers, analyser)
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		timeout:   defaultTimeout,
+		tlsConfig: &tls.Config{InsecureSkipVerify: true},
+		proxy:     http.ProxyFromEnvironment,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) httpClient() *http.Client {
+
+	if o.client != nil {
+		return o.client
+	}
+
+	customTransport := http.DefaultTransport.(*http.Transport).Clone()
+	customTransport.TLSClientConfig = o.tlsConfig
+	customTransport.Proxy = o.proxy
+
+	return &http.Client{
+		Timeout:   o.timeout,
+		Transport: customTransport,
+	}
+}
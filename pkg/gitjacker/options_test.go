@@ -0,0 +1,247 @@
+package gitjacker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/magiconair/properties/assert"
+)
+
+type countingTransport struct {
+	requests int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOptionsAndEvents(t *testing.T) {
+	server, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = server.Listen(listener) }()
+	defer func() { _ = server.Close() }()
+
+	if err := server.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%v", listener.Addr().(*net.TCPAddr).Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	transport := &countingTransport{}
+	events := make(chan Event)
+	var handled []Event
+
+	done := make(chan []Event)
+	go func() {
+		var received []Event
+		for event := range events {
+			received = append(received, event)
+		}
+		done <- received
+	}()
+
+	retriever := New(target, outputDir,
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithEvents(events),
+		WithEventHandler(func(event Event) {
+			handled = append(handled, event)
+		}),
+	)
+
+	summary, err := retriever.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+	received := <-done
+
+	assert.Equal(t, atomic.LoadInt32(&transport.requests) > 0, true)
+	assert.Equal(t, len(received), len(handled))
+
+	counts := make(map[EventKind]int)
+	statuses := make(map[int]int)
+	for _, event := range received {
+		counts[event.Kind]++
+		if event.Kind == EventRequest {
+			statuses[event.Status]++
+		}
+	}
+	assert.Equal(t, statuses[http.StatusOK] > 0, true)
+	assert.Equal(t, statuses[http.StatusNotFound] > 0, true)
+	assert.Equal(t, statuses[0], 0)
+	assert.Equal(t, counts[EventObjectFound], len(summary.FoundObjects))
+	assert.Equal(t, counts[EventRefFound], len(summary.Refs))
+	assert.Equal(t, received[len(received)-1].Phase, PhaseComplete)
+}
+
+func TestRunCancellation(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	if err := source.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// cancel part way through, once the first object has been found
+	fetcher := NewDirFetcher(filepath.Join(source.dir, ".git"))
+	retriever := NewWithFetcher(fetcher, outputDir, WithEventHandler(func(event Event) {
+		if event.Kind == EventObjectFound {
+			cancel()
+		}
+	}))
+
+	summary, err := retriever.Run(ctx)
+	assert.Equal(t, summary == nil, true)
+	assert.Equal(t, errors.Is(err, context.Canceled), true)
+}
+
+func TestCorruptObjectAndConfigEvents(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	if err := source.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("git", "hash-object", "hello.php")
+	cmd.Dir = source.dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := strings.TrimSpace(string(output))
+
+	// replace the blob with something a misbehaving server might return instead
+	objectPath := filepath.Join(source.dir, ".git", "objects", blob[:2], blob[2:])
+	if err := os.Chmod(objectPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(objectPath, []byte("<html>Not Found</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	var corrupt []string
+	fields := make(map[string]string)
+	fetcher := NewDirFetcher(filepath.Join(source.dir, ".git"))
+	summary, err := NewWithFetcher(fetcher, outputDir, WithEventHandler(func(event Event) {
+		switch event.Kind {
+		case EventObjectCorrupt:
+			corrupt = append(corrupt, event.Hash)
+		case EventConfig:
+			fields[event.Field] = event.Value
+		}
+	})).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, corrupt, []string{blob})
+	assert.Equal(t, summary.MissingObjects, []string{blob})
+	assert.Equal(t, fields["user.email"], "test@test.com")
+	assert.Equal(t, fields["user.name"], "test")
+}
+
+func TestAnalysers(t *testing.T) {
+	source, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = source.Close() }()
+
+	if err := source.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	var phases []string
+	analyser := func(ctx context.Context, dir string, summary *Summary) error {
+		assert.Equal(t, dir, outputDir)
+		assert.Equal(t, summary.Status, StatusSuccess)
+		summary.Analysis.Secrets = append(summary.Analysis.Secrets, exposed.Secret{Rule: "test", Occurrence: exposed.Occurrence{Path: "hello.php"}})
+		return nil
+	}
+	failing := func(ctx context.Context, dir string, summary *Summary) error {
+		return fmt.Errorf("analysis failed")
+	}
+
+	fetcher := NewDirFetcher(filepath.Join(source.dir, ".git"))
+	summary, err := NewWithFetcher(fetcher, outputDir, WithAnalyser(failing), WithAnalyser(analyser), WithEventHandler(func(event Event) {
+		if event.Kind == EventPhase {
+			phases = append(phases, event.Phase)
+		}
+	})).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(summary.Analysis.Secrets), 1)
+	assert.Equal(t, summary.AnalysisErrors, []string{"analysis failed"})
+	assert.Equal(t, phases[len(phases)-2:], []string{PhaseAnalysis, PhaseComplete})
+}
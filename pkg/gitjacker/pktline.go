@@ -0,0 +1,83 @@
+package gitjacker
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type pktType uint
+
+const (
+	pktData pktType = iota
+	pktFlush
+	pktDelim
+	pktResponseEnd
+)
+
+const maxPktLength = 65520
+
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%s", len(line)+4, line)
+}
+
+func readPktLine(r io.Reader) ([]byte, pktType, error) {
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, pktData, err
+	}
+
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, pktData, fmt.Errorf("invalid pkt-line length %q: %w", header, err)
+	}
+
+	switch length {
+	case 0:
+		return nil, pktFlush, nil
+	case 1:
+		return nil, pktDelim, nil
+	case 2:
+		return nil, pktResponseEnd, nil
+	case 3:
+		return nil, pktData, fmt.Errorf("invalid pkt-line length %q", header)
+	}
+
+	if length > maxPktLength {
+		return nil, pktData, fmt.Errorf("pkt-line length %d exceeds maximum", length)
+	}
+
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, pktData, err
+	}
+
+	return payload, pktData, nil
+}
+
+// readSideBand demultiplexes side-band data from r into w until a flush packet is reached
+func readSideBand(r io.Reader, w io.Writer) error {
+	for {
+		payload, kind, err := readPktLine(r)
+		if err != nil {
+			return err
+		}
+		if kind == pktFlush || kind == pktResponseEnd {
+			return nil
+		}
+		if kind != pktData || len(payload) == 0 {
+			continue
+		}
+		switch payload[0] {
+		case 1:
+			if _, err := w.Write(payload[1:]); err != nil {
+				return err
+			}
+		case 2:
+			// progress information
+		case 3:
+			return fmt.Errorf("remote error: %s", payload[1:])
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package gitjacker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Ref struct {
+	Name string
+	Hash string
+}
+
+// ReadLocalRefs lists the refs recorded in a .git directory, including loose and packed refs
+func ReadLocalRefs(gitDir string) ([]Ref, error) {
+
+	found := make(map[string]string)
+
+	if packed, err := ioutil.ReadFile(filepath.Join(gitDir, "packed-refs")); err == nil {
+		for _, line := range strings.Split(string(packed), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+				continue
+			}
+			parts := strings.Fields(line)
+			if len(parts) == 2 && isHash(parts[0]) {
+				found[parts[1]] = parts[0]
+			}
+		}
+	}
+
+	refsDir := filepath.Join(gitDir, "refs")
+	err := filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		hash := strings.TrimSpace(string(content))
+		if !isHash(hash) {
+			return nil
+		}
+		relative, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			return nil
+		}
+		found[filepath.ToSlash(relative)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []Ref
+	for name, hash := range found {
+		refs = append(refs, Ref{Name: name, Hash: hash})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
+
+// looseObjectHash returns the hash of the loose object stored at a path such as "objects/ab/cdef..."
+func looseObjectHash(path string) (string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] != "objects" {
+		return "", false
+	}
+	hash := parts[1] + parts[2]
+	return hash, len(parts[1]) == 2 && isHash(hash)
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// divergedRef names a tip which sources disagreed on, such as refs/diverged/heads/master/5b89658fae43, so that
+// it is kept alongside the version of the ref which was written
+func divergedRef(name string, hash string) string {
+	return "refs/diverged/" + strings.TrimPrefix(name, "refs/") + "/" + hash[:12]
+}
+
+// splitPackedRefs reads packed-refs, which may have been merged from several sources. The first entry for each
+// ref is kept, and entries which disagree with it are returned separately.
+func splitPackedRefs(content []byte) ([]byte, []Ref, []Ref) {
+
+	var headers []string
+	var kept, diverged []Ref
+	entries := make(map[string]string)
+	var name string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			headers = append(headers, line)
+		case strings.HasPrefix(line, "^"):
+			// a peeled tag belongs to the entry above it
+			if name != "" {
+				entries[name] += "\n" + line
+			}
+		default:
+			name = ""
+			parts := strings.Fields(line)
+			if len(parts) != 2 || !isHash(parts[0]) {
+				continue
+			}
+			if _, ok := entries[parts[1]]; ok {
+				if existing := strings.Fields(entries[parts[1]])[0]; existing != parts[0] {
+					diverged = append(diverged, Ref{Name: parts[1], Hash: parts[0]})
+				}
+				continue
+			}
+			name = parts[1]
+			entries[name] = line
+			kept = append(kept, Ref{Name: name, Hash: parts[0]})
+		}
+	}
+
+	if len(diverged) == 0 && len(headers) <= 1 {
+		return content, kept, nil
+	}
+
+	// entries are written sorted, as git expects them to be
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Name < kept[j].Name
+	})
+	var lines []string
+	if len(headers) > 0 {
+		lines = append(lines, headers[0])
+	}
+	for _, ref := range kept {
+		lines = append(lines, entries[ref.Name])
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), kept, diverged
+}
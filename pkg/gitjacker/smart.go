@@ -0,0 +1,379 @@
+package gitjacker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrSmartUnavailable = fmt.Errorf("smart http protocol is not available")
+
+const (
+	smartAdvertisementType = "application/x-git-upload-pack-advertisement"
+	smartRequestType       = "application/x-git-upload-pack-request"
+	smartResultType        = "application/x-git-upload-pack-result"
+	zeroHash               = "0000000000000000000000000000000000000000"
+)
+
+type advertisement struct {
+	version      int
+	refs         []Ref
+	symrefs      map[string]string
+	capabilities map[string]string
+}
+
+func (a *advertisement) supports(capability string) bool {
+	_, ok := a.capabilities[capability]
+	return ok
+}
+
+// retrieveSmart fetches a pack of all advertised refs when git-upload-pack is exposed over HTTP
+func (r *Retriever) retrieveSmart(ctx context.Context, source *HTTPFetcher) error {
+
+	adv, err := source.discoverSmartRefs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if adv.version == 2 {
+		if err := source.listSmartRefsV2(ctx, adv); err != nil {
+			return err
+		}
+	}
+
+	var wants []string
+	seen := make(map[string]bool)
+	for _, ref := range adv.refs {
+		if ref.Hash == zeroHash || seen[ref.Hash] {
+			continue
+		}
+		seen[ref.Hash] = true
+		wants = append(wants, ref.Hash)
+	}
+	if len(wants) == 0 {
+		return fmt.Errorf("%w: no refs advertised", ErrSmartUnavailable)
+	}
+
+	if err := r.initRepository(adv); err != nil {
+		return err
+	}
+
+	// objects from a previous run need not be sent again
+	haves := r.previousHeads(ctx)
+
+	pack := &bytes.Buffer{}
+	if adv.version == 2 {
+		err = source.fetchSmartPackV2(ctx, wants, haves, pack)
+	} else {
+		err = source.fetchSmartPackV0(ctx, adv, wants, haves, pack)
+	}
+	if err != nil {
+		r.requested(ctx, "git-upload-pack", Meta{}, 0, err)
+		return err
+	}
+
+	logrus.Debugf("Retrieved %d byte pack over smart HTTP (protocol v%d).", pack.Len(), adv.version)
+	r.requested(ctx, "git-upload-pack", Meta{StatusCode: http.StatusOK}, pack.Len(), nil)
+
+	if err := r.unpackObjects(ctx, pack, source.baseURL.String()); err != nil {
+		return fmt.Errorf("failed to unpack smart HTTP pack: %w", err)
+	}
+
+	for _, ref := range adv.refs {
+		if ref.Name == "HEAD" || ref.Hash == zeroHash {
+			continue
+		}
+		if err := r.writeRef(ref); err != nil {
+			logrus.Debugf("Failed to write ref %s: %s", ref.Name, err)
+		}
+	}
+
+	for _, hash := range wants {
+		if _, err := r.downloadObject(ctx, hash); err != nil {
+			logrus.Debugf("Object %s is missing from smart HTTP pack.", hash)
+		}
+	}
+
+	return nil
+}
+
+func (f *HTTPFetcher) smartRequest(ctx context.Context, method string, service string, body io.Reader) (*http.Response, error) {
+
+	absolute, err := f.resolve(service)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, absolute.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Git-Protocol", "version=2")
+	if body != nil {
+		req.Header.Set("Content-Type", smartRequestType)
+		req.Header.Set("Accept", smartResultType)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s: %w", absolute.String(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: unexpected status code for url %s : %d", ErrSmartUnavailable, absolute.String(), resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (f *HTTPFetcher) discoverSmartRefs(ctx context.Context) (*advertisement, error) {
+
+	resp, err := f.smartRequest(ctx, http.MethodGet, "info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.Header.Get("Content-Type") != smartAdvertisementType {
+		return nil, fmt.Errorf("%w: unexpected content type %q", ErrSmartUnavailable, resp.Header.Get("Content-Type"))
+	}
+
+	return parseAdvertisement(bufio.NewReader(resp.Body))
+}
+
+func parseAdvertisement(r io.Reader) (*advertisement, error) {
+
+	adv := &advertisement{
+		symrefs:      make(map[string]string),
+		capabilities: make(map[string]string),
+	}
+
+	first := true
+	for {
+		payload, kind, err := readPktLine(r)
+		if err == io.EOF {
+			return adv, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if kind != pktData {
+			if kind == pktFlush && !first {
+				return adv, nil
+			}
+			continue
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if strings.HasPrefix(line, "# service=") {
+			continue
+		}
+
+		if first {
+			first = false
+			if line == "version 2" {
+				adv.version = 2
+				continue
+			}
+		}
+
+		if adv.version == 2 {
+			// v2 advertises one capability per line
+			key, val := line, ""
+			if i := strings.Index(line, "="); i >= 0 {
+				key, val = line[:i], line[i+1:]
+			}
+			adv.capabilities[key] = val
+			continue
+		}
+
+		if i := strings.IndexByte(line, 0); i >= 0 {
+			for _, capability := range strings.Fields(line[i+1:]) {
+				key, val := capability, ""
+				if j := strings.Index(capability, "="); j >= 0 {
+					key, val = capability[:j], capability[j+1:]
+				}
+				if key == "symref" {
+					if parts := strings.SplitN(val, ":", 2); len(parts) == 2 {
+						adv.symrefs[parts[0]] = parts[1]
+					}
+				}
+				adv.capabilities[key] = val
+			}
+			line = line[:i]
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 2 || strings.HasSuffix(parts[1], "^{}") || parts[1] == "capabilities^{}" {
+			continue
+		}
+		adv.refs = append(adv.refs, Ref{Name: parts[1], Hash: parts[0]})
+	}
+}
+
+func (f *HTTPFetcher) listSmartRefsV2(ctx context.Context, adv *advertisement) error {
+
+	request := pktLine("command=ls-refs\n") + "0001" + pktLine("peel\n") + pktLine("symrefs\n") + "0000"
+
+	resp, err := f.smartRequest(ctx, http.MethodPost, "git-upload-pack", strings.NewReader(request))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body := bufio.NewReader(resp.Body)
+	for {
+		payload, kind, err := readPktLine(body)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if kind != pktData {
+			return nil
+		}
+		parts := strings.Fields(strings.TrimSuffix(string(payload), "\n"))
+		if len(parts) < 2 {
+			continue
+		}
+		adv.refs = append(adv.refs, Ref{Name: parts[1], Hash: parts[0]})
+		for _, attribute := range parts[2:] {
+			if strings.HasPrefix(attribute, "symref-target:") {
+				adv.symrefs[parts[1]] = strings.TrimPrefix(attribute, "symref-target:")
+			}
+		}
+	}
+}
+
+func (f *HTTPFetcher) fetchSmartPackV0(ctx context.Context, adv *advertisement, wants []string, haves []string, w io.Writer) error {
+
+	var capabilities []string
+	sideBand := ""
+	for _, capability := range []string{"side-band-64k", "side-band"} {
+		if adv.supports(capability) {
+			sideBand = capability
+			break
+		}
+	}
+	if sideBand != "" {
+		capabilities = append(capabilities, sideBand)
+	}
+	if adv.supports("ofs-delta") {
+		capabilities = append(capabilities, "ofs-delta")
+	}
+
+	request := &strings.Builder{}
+	for i, hash := range wants {
+		line := "want " + hash
+		if i == 0 && len(capabilities) > 0 {
+			line += " " + strings.Join(capabilities, " ")
+		}
+		request.WriteString(pktLine(line + "\n"))
+	}
+	request.WriteString("0000")
+	for _, hash := range haves {
+		request.WriteString(pktLine("have " + hash + "\n"))
+	}
+	request.WriteString(pktLine("done\n"))
+
+	resp, err := f.smartRequest(ctx, http.MethodPost, "git-upload-pack", strings.NewReader(request.String()))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body := bufio.NewReader(resp.Body)
+
+	// the server acknowledges any common objects, or the lack of them, before sending the pack
+	payload, _, err := readPktLine(body)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(payload), "NAK") && !strings.HasPrefix(string(payload), "ACK") {
+		return fmt.Errorf("unexpected upload-pack response: %q", payload)
+	}
+
+	if sideBand == "" {
+		_, err := io.Copy(w, body)
+		return err
+	}
+
+	return readSideBand(body, w)
+}
+
+func (f *HTTPFetcher) fetchSmartPackV2(ctx context.Context, wants []string, haves []string, w io.Writer) error {
+
+	request := &strings.Builder{}
+	request.WriteString(pktLine("command=fetch\n"))
+	request.WriteString("0001")
+	request.WriteString(pktLine("ofs-delta\n"))
+	for _, hash := range wants {
+		request.WriteString(pktLine("want " + hash + "\n"))
+	}
+	for _, hash := range haves {
+		request.WriteString(pktLine("have " + hash + "\n"))
+	}
+	request.WriteString(pktLine("done\n"))
+	request.WriteString("0000")
+
+	resp, err := f.smartRequest(ctx, http.MethodPost, "git-upload-pack", strings.NewReader(request.String()))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body := bufio.NewReader(resp.Body)
+	for {
+		payload, kind, err := readPktLine(body)
+		if err != nil {
+			return err
+		}
+		if kind != pktData {
+			continue
+		}
+		// skip any sections which precede the pack itself
+		if strings.TrimSuffix(string(payload), "\n") == "packfile" {
+			return readSideBand(body, w)
+		}
+	}
+}
+
+// initRepository prepares the output directory so git can unpack objects into it before any dumb retrieval
+func (r *Retriever) initRepository(adv *advertisement) error {
+
+	gitDir := filepath.Join(r.outputDir, ".git")
+	for _, dir := range []string{"objects", "refs"} {
+		if err := os.MkdirAll(filepath.Join(gitDir, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	headPath := filepath.Join(gitDir, "HEAD")
+	if _, err := os.Stat(headPath); err == nil {
+		return nil
+	}
+
+	head := "refs/heads/master"
+	if target, ok := adv.symrefs["HEAD"]; ok {
+		head = target
+	}
+	return ioutil.WriteFile(headPath, []byte(fmt.Sprintf("ref: %s\n", head)), 0640)
+}
+
+func (r *Retriever) writeRef(ref Ref) error {
+	if !strings.HasPrefix(ref.Name, "refs/") {
+		return fmt.Errorf("invalid ref name %q", ref.Name)
+	}
+	path := filepath.Join(r.outputDir, ".git", filepath.FromSlash(filepath.Clean("/"+ref.Name)))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(ref.Hash+"\n"), 0640)
+}
@@ -0,0 +1,139 @@
+package gitjacker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func newSmartServer(t *testing.T, allowV2 bool) (*vulnerableServer, net.Listener) {
+
+	server, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &cgi.Handler{
+		Path:   filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Root:   "/",
+		Stderr: ioutil.Discard,
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + server.dir,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+
+	server.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !allowV2 {
+			req.Header.Del("Git-Protocol")
+		}
+		backend.ServeHTTP(w, req)
+	})
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = server.Listen(listener) }()
+
+	return server, listener
+}
+
+func TestSmartRetrieval(t *testing.T) {
+
+	for _, allowV2 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("v2=%t", allowV2), func(t *testing.T) {
+
+			server, listener := newSmartServer(t, allowV2)
+			defer func() { _ = server.Close() }()
+
+			expectedContent := "<?php\necho 'hello';\n"
+			if err := server.writeFile("hello.php", expectedContent); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := server.commit("first commit"); err != nil {
+				t.Fatal(err)
+			}
+
+			tagCmd := exec.Command("git", "tag", "-a", "v1.0.0", "-m", "release")
+			tagCmd.Dir = server.dir
+			if err := tagCmd.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			// pack everything so the dumb protocol cannot see any objects
+			gcCmd := exec.Command("git", "gc", "--quiet")
+			gcCmd.Dir = server.dir
+			if err := gcCmd.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			target, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%v", listener.Addr().(*net.TCPAddr).Port))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = os.RemoveAll(outputDir) }()
+
+			summary, err := New(target, outputDir).Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, summary.SmartProtocolAvailable, true)
+			assert.Equal(t, summary.Status, StatusSuccess)
+			assert.Equal(t, len(summary.MissingObjects), 0)
+			assert.Equal(t, len(summary.Refs), 2)
+
+			actual, err := ioutil.ReadFile(filepath.Join(outputDir, "hello.php"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, string(actual), expectedContent)
+		})
+	}
+}
+
+func TestParseAdvertisement(t *testing.T) {
+
+	raw := pktLine("# service=git-upload-pack\n") + "0000" +
+		pktLine("8a6f2b5c9c1d0e4f3a2b1c0d9e8f7a6b5c4d3e2f HEAD\x00multi_ack side-band-64k ofs-delta symref=HEAD:refs/heads/main\n") +
+		pktLine("8a6f2b5c9c1d0e4f3a2b1c0d9e8f7a6b5c4d3e2f refs/heads/main\n") +
+		pktLine("1111111111111111111111111111111111111111 refs/tags/v1\n") +
+		pktLine("8a6f2b5c9c1d0e4f3a2b1c0d9e8f7a6b5c4d3e2f refs/tags/v1^{}\n") +
+		"0000"
+
+	adv, err := parseAdvertisement(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, adv.version, 0)
+	assert.Equal(t, len(adv.refs), 3)
+	assert.Equal(t, adv.symrefs["HEAD"], "refs/heads/main")
+	assert.Equal(t, adv.supports("side-band-64k"), true)
+	assert.Equal(t, adv.supports("thin-pack"), false)
+}
@@ -0,0 +1,265 @@
+package gitjacker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrNotModified = fmt.Errorf("file has not been modified")
+var ErrNoState = fmt.Errorf("no gitjacker state was found in this directory")
+
+// ConditionalFetcher is implemented by fetchers which can skip files unchanged since a previous fetch
+type ConditionalFetcher interface {
+	FetchIfModified(ctx context.Context, path string, previous Meta) (io.ReadCloser, Meta, error)
+}
+
+// State records what a run retrieved, so the output directory can be refreshed later
+type State struct {
+	Targets    []string        `json:"targets"`
+	Refs       []Ref           `json:"refs"`
+	Validators map[string]Meta `json:"validators"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type RefUpdate struct {
+	Name    string
+	OldHash string
+	NewHash string
+}
+
+func statePath(outputDir string) string {
+	return filepath.Join(outputDir, ".git", "gitjacker", "state.json")
+}
+
+func LoadState(outputDir string) (*State, error) {
+	data, err := ioutil.ReadFile(statePath(outputDir))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNoState, outputDir)
+	} else if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state for %s: %w", outputDir, err)
+	}
+	return &state, nil
+}
+
+func (r *Retriever) saveState() error {
+
+	sources := httpSources(r.fetcher)
+	if len(sources) == 0 {
+		// there is nothing to refresh from without a live target
+		return nil
+	}
+
+	state := State{
+		Refs:       r.summary.Refs,
+		Validators: r.validators,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	for _, source := range sources {
+		state.Targets = append(state.Targets, source.baseURL.String())
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := statePath(r.outputDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0640)
+}
+
+// NewRefresh creates a retriever which updates a previously recovered output directory from its original targets
+func NewRefresh(outputDir string, opts ...Option) (*Retriever, error) {
+
+	state, err := LoadState(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Targets) == 0 {
+		return nil, fmt.Errorf("%w: no targets recorded", ErrNoState)
+	}
+
+	client := newOptions(opts).httpClient()
+
+	var fetchers []Fetcher
+	for _, target := range state.Targets {
+		baseURL, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target in state: %w", err)
+		}
+		fetchers = append(fetchers, NewHTTPFetcher(baseURL, client))
+	}
+
+	var fetcher Fetcher = fetchers[0]
+	if len(fetchers) > 1 {
+		fetcher = NewMultiFetcher(fetchers...)
+	}
+
+	r := NewWithFetcher(fetcher, outputDir, opts...)
+	r.previous = state
+	return r, nil
+}
+
+// fetchConditionally avoids downloading files which have not changed since the previous run
+func (r *Retriever) fetchConditionally(ctx context.Context, path string) ([]byte, Meta, bool, error) {
+
+	if r.previous == nil {
+		return nil, Meta{}, false, nil
+	}
+
+	previous, ok := r.previous.Validators[path]
+	if !ok {
+		return nil, Meta{}, false, nil
+	}
+
+	conditional, ok := r.fetcher.(ConditionalFetcher)
+	if !ok {
+		return nil, Meta{}, false, nil
+	}
+
+	body, meta, err := conditional.FetchIfModified(ctx, path, previous)
+	if errors.Is(err, ErrNotModified) {
+		content, err := ioutil.ReadFile(filepath.Join(r.outputDir, ".git", filepath.FromSlash(filepath.Clean("/"+path))))
+		if err != nil {
+			// the local copy is gone, so fall back to a full request
+			return nil, Meta{}, false, nil
+		}
+		logrus.Debugf("File %s has not been modified since the previous run.", path)
+		unchanged := previous
+		unchanged.StatusCode = http.StatusNotModified
+		r.requested(ctx, path, unchanged, 0, nil)
+		return content, previous, true, nil
+	} else if err != nil {
+		r.requested(ctx, path, Meta{}, 0, err)
+		return nil, Meta{}, true, err
+	}
+	defer func() { _ = body.Close() }()
+
+	content, err := ioutil.ReadAll(body)
+	r.requested(ctx, path, meta, len(content), err)
+	return content, meta, true, err
+}
+
+func (r *Retriever) recordValidator(path string, meta Meta) {
+	if strings.HasPrefix(path, "objects/") && path != "objects/info/packs" {
+		return
+	}
+	if meta.ETag == "" && meta.LastModified.IsZero() {
+		return
+	}
+	r.validators[path] = meta
+}
+
+// compareRefs records which refs moved since the previous run and which commits are new
+func (r *Retriever) compareRefs(ctx context.Context) {
+
+	if r.previous == nil {
+		return
+	}
+
+	previous := make(map[string]string)
+	var oldHeads []string
+	for _, ref := range r.previous.Refs {
+		previous[ref.Name] = ref.Hash
+		oldHeads = append(oldHeads, ref.Hash)
+	}
+
+	current := make(map[string]string)
+	var newHeads []string
+	for _, ref := range r.summary.Refs {
+		current[ref.Name] = ref.Hash
+		if previous[ref.Name] != ref.Hash {
+			r.summary.RefUpdates = append(r.summary.RefUpdates, RefUpdate{
+				Name:    ref.Name,
+				OldHash: previous[ref.Name],
+				NewHash: ref.Hash,
+			})
+			newHeads = append(newHeads, ref.Hash)
+		}
+	}
+	for _, ref := range r.previous.Refs {
+		if _, ok := current[ref.Name]; !ok {
+			r.summary.RefUpdates = append(r.summary.RefUpdates, RefUpdate{
+				Name:    ref.Name,
+				OldHash: ref.Hash,
+			})
+		}
+	}
+	sort.Slice(r.summary.RefUpdates, func(i, j int) bool {
+		return r.summary.RefUpdates[i].Name < r.summary.RefUpdates[j].Name
+	})
+
+	known := make(map[string]bool)
+	for _, hash := range r.reachableCommits(ctx, oldHeads, nil) {
+		known[hash] = true
+	}
+	r.summary.NewCommits = r.reachableCommits(ctx, newHeads, known)
+}
+
+// reachableCommits walks history from heads, tolerating missing objects and stopping at any commit in stop
+func (r *Retriever) reachableCommits(ctx context.Context, heads []string, stop map[string]bool) []string {
+
+	var commits []string
+	seen := make(map[string]bool)
+	queue := append([]string{}, heads...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] || stop[hash] {
+			continue
+		}
+		objectType, err := r.getObjectType(ctx, hash)
+		if err != nil {
+			continue
+		}
+		if objectType == GitTagFile {
+			if target, err := r.readTag(ctx, hash); err == nil {
+				queue = append(queue, target)
+			}
+			continue
+		}
+		if objectType != GitCommitFile {
+			continue
+		}
+		seen[hash] = true
+		commits = append(commits, hash)
+		commit, err := r.readCommit(ctx, hash)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return commits
+}
+
+func (r *Retriever) previousHeads(ctx context.Context) []string {
+	if r.previous == nil {
+		return nil
+	}
+	var heads []string
+	for _, ref := range r.previous.Refs {
+		if r.hasObject(ctx, ref.Hash) {
+			heads = append(heads, ref.Hash)
+		}
+	}
+	return heads
+}
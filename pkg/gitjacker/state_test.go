@@ -0,0 +1,194 @@
+package gitjacker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestRefresh(t *testing.T) {
+	server, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = server.Listen(listener) }()
+	defer func() { _ = server.Close() }()
+
+	if err := server.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%v", listener.Addr().(*net.TCPAddr).Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	if _, err := New(target, outputDir).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadState(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(state.Targets), 1)
+	assert.Equal(t, len(state.Refs), 1)
+
+	if err := server.writeFile("world.php", "<?php\necho 'world';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.commit("second commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	// make sure the change is visible to If-Modified-Since, which only has second precision
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(server.dir, ".git", "refs", "heads", "master"), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	var head *Event
+	retriever, err := NewRefresh(outputDir, WithEventHandler(func(event Event) {
+		if event.Kind == EventRequest && event.Path == "HEAD" {
+			head = &event
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := retriever.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HEAD is unchanged, so it is reported as a successful request which was not modified
+	assert.Equal(t, head != nil, true)
+	assert.Equal(t, head.Status, http.StatusNotModified)
+	assert.Equal(t, head.Error, "")
+
+	assert.Equal(t, summary.Status, StatusSuccess)
+	assert.Equal(t, len(summary.RefUpdates), 1)
+	assert.Equal(t, summary.RefUpdates[0].Name, "refs/heads/master")
+	assert.Equal(t, summary.RefUpdates[0].OldHash, state.Refs[0].Hash)
+	assert.Equal(t, len(summary.NewCommits), 1)
+	assert.Equal(t, summary.NewCommits[0], summary.RefUpdates[0].NewHash)
+
+	if _, err := os.Stat(filepath.Join(outputDir, "world.php")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshRequiresState(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gjtest_empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	_, err = NewRefresh(dir)
+	assert.Equal(t, errors.Is(err, ErrNoState), true)
+}
+
+func TestRefreshSkipsRetrievedPacks(t *testing.T) {
+	server, err := newVulnerableServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = server.Listen(listener) }()
+	defer func() { _ = server.Close() }()
+
+	if err := server.writeFile("hello.php", "<?php\necho 'hello';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.commit("first commit"); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "repack", "-a", "-d", "-q")
+	cmd.Dir = server.dir
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// the tip is left loose, as retrieval starts from it before looking for packs
+	if err := server.writeFile("world.php", "<?php\necho 'world';\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.commit("second commit"); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command("git", "update-server-info")
+	cmd.Dir = server.dir
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%v", listener.Addr().(*net.TCPAddr).Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "gjtest_out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(outputDir) }()
+
+	packRequests := func(requests *int) Option {
+		return WithEventHandler(func(event Event) {
+			if event.Kind == EventRequest && strings.HasSuffix(event.Path, ".pack") {
+				*requests++
+			}
+		})
+	}
+
+	var first int
+	if _, err := New(target, outputDir, packRequests(&first)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first, 1)
+
+	var second int
+	retriever, err := NewRefresh(outputDir, packRequests(&second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := retriever.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, second, 0)
+	assert.Equal(t, summary.Status, StatusSuccess)
+}